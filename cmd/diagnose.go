@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	dev "k8s-dev/pkg/k8s"
+	"os"
+)
+
+func runDiagnose(args []string) error {
+	var (
+		cf        clientFlags
+		namespace string
+		format    string
+	)
+	fs := flag.NewFlagSet("diagnose", flag.ExitOnError)
+	cf.register(fs)
+	fs.StringVar(&namespace, "n", dev.DefaultNamespace, "命名空间")
+	fs.StringVar(&format, "o", dev.FormatText, "输出格式：text|json")
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: diagnose [flags] <pod>")
+	}
+
+	client, err := cf.client()
+	if err != nil {
+		return err
+	}
+	diagnosis, err := dev.DiagnosePod(context.Background(), client, namespace, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	switch format {
+	case dev.FormatJSON:
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(diagnosis)
+	case dev.FormatText:
+		return dev.WriteDiagnosis(os.Stdout, diagnosis)
+	default:
+		return fmt.Errorf("unsupported output format %q", format)
+	}
+}
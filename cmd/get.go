@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	dev "k8s-dev/pkg/k8s"
+	"k8s-dev/pkg/k8s/printers"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/restmapper"
+	"os"
+	"strings"
+)
+
+func runGet(args []string) error {
+	var (
+		cf            clientFlags
+		namespace     string
+		allNamespaces bool
+		selector      string
+		format        string
+		noHeaders     bool
+	)
+	fs := flag.NewFlagSet("get", flag.ExitOnError)
+	cf.register(fs)
+	fs.StringVar(&namespace, "n", dev.DefaultNamespace, "命名空间")
+	fs.BoolVar(&allNamespaces, "A", false, "查询所有命名空间")
+	fs.StringVar(&selector, "l", "", "标签选择器")
+	fs.StringVar(&format, "o", "", "输出格式：table|wide|json|yaml|name|custom-columns=|jsonpath=|go-template=")
+	fs.BoolVar(&noHeaders, "no-headers", false, "表格不输出表头")
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: get [flags] <resource>")
+	}
+	if allNamespaces {
+		namespace = metaV1.NamespaceAll
+	}
+
+	printer, err := printers.New(format)
+	if err != nil {
+		return err
+	}
+	config, err := cf.config()
+	if err != nil {
+		return err
+	}
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return err
+	}
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(discoveryClient))
+	gvr, err := mapper.ResourceFor(schema.ParseGroupResource(strings.ToLower(fs.Arg(0))).WithVersion(""))
+	if err != nil {
+		return err
+	}
+	namespace, namespaced, err := printers.ResourceNamespace(mapper, gvr, namespace)
+	if err != nil {
+		return err
+	}
+	switch p := printer.(type) {
+	case *printers.TablePrinter:
+		p.NoHeaders = noHeaders
+		p.WithNamespace = allNamespaces && namespaced
+	case *printers.CustomColumnsPrinter:
+		p.NoHeaders = noHeaders
+	}
+	opts := metaV1.ListOptions{LabelSelector: selector}
+
+	var obj runtime.Object
+	if _, ok := printer.(*printers.TablePrinter); ok {
+		clientSet, err := kubernetes.NewForConfig(config)
+		if err != nil {
+			return err
+		}
+		obj, err = printers.GetTable(context.Background(), clientSet.CoreV1().RESTClient(), gvr, namespace, opts)
+		if err != nil {
+			return err
+		}
+	} else {
+		dynamicClient, err := dynamic.NewForConfig(config)
+		if err != nil {
+			return err
+		}
+		var resource dynamic.ResourceInterface = dynamicClient.Resource(gvr)
+		if namespaced {
+			resource = dynamicClient.Resource(gvr).Namespace(namespace)
+		}
+		obj, err = resource.List(context.Background(), opts)
+		if err != nil {
+			return err
+		}
+	}
+	return printer.PrintObj(obj, os.Stdout)
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"flag"
+	dev "k8s-dev/pkg/k8s"
+	"os"
+)
+
+func runHealth(args []string) error {
+	var (
+		cf      clientFlags
+		opts    dev.HealthOptions
+		format  string
+		exitErr bool
+	)
+	fs := flag.NewFlagSet("health", flag.ExitOnError)
+	cf.register(fs)
+	fs.StringVar(&opts.Namespace, "n", "", "命名空间，为空时检查所有命名空间")
+	fs.DurationVar(&opts.TerminatingTimeout, "terminating-timeout", dev.DefaultTerminatingTimeout, "命名空间处于 Terminating 超过该时长视为卡住")
+	fs.StringVar(&format, "o", dev.FormatText, "输出格式：text|json|markdown")
+	fs.BoolVar(&exitErr, "exit-code", false, "存在异常时以非0状态码退出")
+	_ = fs.Parse(args)
+
+	client, err := cf.client()
+	if err != nil {
+		return err
+	}
+	report, err := dev.Health(context.Background(), client, opts)
+	if err != nil {
+		return err
+	}
+	if err := dev.WriteHealthReport(os.Stdout, report, format); err != nil {
+		return err
+	}
+	if exitErr && !report.Healthy() {
+		os.Exit(3)
+	}
+	return nil
+}
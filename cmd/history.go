@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"k8s-dev/pkg/history"
+	dev "k8s-dev/pkg/k8s"
+	appsV1 "k8s.io/api/apps/v1"
+	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/tools/cache"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+const defaultHistoryPath = "history.db"
+
+func runHistory(args []string) error {
+	actions := map[string]func(args []string) error{
+		"record":  runHistoryRecord,
+		"show":    runHistoryShow,
+		"changes": runHistoryChanges,
+	}
+	if len(args) == 0 || actions[args[0]] == nil {
+		return fmt.Errorf("usage: history record|show|changes [flags]")
+	}
+	return actions[args[0]](args[1:])
+}
+
+// runHistoryRecord 把 Pod 和 Deployment 的每次变更写入历史库
+func runHistoryRecord(args []string) error {
+	var (
+		cf        clientFlags
+		opts      history.Options
+		namespace string
+		interval  time.Duration
+	)
+	fs := flag.NewFlagSet("history record", flag.ExitOnError)
+	cf.register(fs)
+	fs.StringVar(&opts.Path, "db", defaultHistoryPath, "历史库文件路径")
+	fs.StringVar(&namespace, "n", "", "命名空间，为空时记录所有命名空间")
+	fs.DurationVar(&opts.MaxAge, "max-age", 7*24*time.Hour, "版本的保留时长，0 表示不限制")
+	fs.IntVar(&opts.MaxRevisions, "max-revisions", 100, "每个对象最多保留的版本数，0 表示不限制")
+	fs.DurationVar(&interval, "prune-interval", history.DefaultPruneInterval, "清理过期版本的间隔")
+	_ = fs.Parse(args)
+
+	client, err := cf.client()
+	if err != nil {
+		return err
+	}
+	store, err := history.Open(opts)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	// 直接使用 informer 的通知而不是控制器，控制器会合并处理前的多次更新
+	recorder := history.NewRecorder(store)
+	informers := []cache.SharedIndexInformer{
+		cache.NewSharedIndexInformer(cache.NewListWatchFromClient(client.CoreV1().RESTClient(), string(dev.POD), namespace, fields.Everything()), &coreV1.Pod{}, 0, cache.Indexers{}),
+		cache.NewSharedIndexInformer(cache.NewListWatchFromClient(client.AppsV1().RESTClient(), string(dev.DEPLOY), namespace, fields.Everything()), &appsV1.Deployment{}, 0, cache.Indexers{}),
+	}
+	for _, informer := range informers {
+		if _, err := informer.AddEventHandler(recorder.Handler()); err != nil {
+			return err
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go store.Run(ctx, interval)
+	for _, informer := range informers {
+		go informer.Run(ctx.Done())
+	}
+	recorder.Run(ctx)
+	return nil
+}
+
+// runHistoryShow 输出对象的历史版本，指定 -at 时输出对象在该时刻的内容
+func runHistoryShow(args []string) error {
+	var (
+		path      string
+		kind      string
+		namespace string
+		at        string
+		format    string
+	)
+	fs := flag.NewFlagSet("history show", flag.ExitOnError)
+	fs.StringVar(&path, "db", defaultHistoryPath, "历史库文件路径")
+	fs.StringVar(&kind, "kind", "Pod", "对象类型，例如 Pod、Deployment")
+	fs.StringVar(&namespace, "n", dev.DefaultNamespace, "命名空间，集群级别的对象为空")
+	fs.StringVar(&at, "at", "", "RFC3339 格式的时间，输出对象在该时刻的内容")
+	fs.StringVar(&format, "o", "", "输出格式：历史版本为 text|json，-at 时为 yaml|json")
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: history show [flags] <name>")
+	}
+	key := fs.Arg(0)
+	if namespace != "" {
+		key = namespace + "/" + key
+	}
+
+	store, err := history.Open(history.Options{Path: path, ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	if at == "" {
+		revs, err := store.History(kind, key)
+		if err != nil {
+			return err
+		}
+		return history.WriteRevisions(os.Stdout, revs, format)
+	}
+	t, err := time.Parse(time.RFC3339, at)
+	if err != nil {
+		return err
+	}
+	rev, exists, err := store.At(kind, key, t)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("%s %s did not exist at %s", kind, key, at)
+	}
+	return history.WriteObject(os.Stdout, rev, format)
+}
+
+// runHistoryChanges 输出一段时间内所有对象的变更
+func runHistoryChanges(args []string) error {
+	var (
+		path   string
+		since  time.Duration
+		format string
+	)
+	fs := flag.NewFlagSet("history changes", flag.ExitOnError)
+	fs.StringVar(&path, "db", defaultHistoryPath, "历史库文件路径")
+	fs.DurationVar(&since, "since", time.Hour, "输出最近这段时间的变更")
+	fs.StringVar(&format, "o", history.FormatText, "输出格式：text|json")
+	_ = fs.Parse(args)
+
+	store, err := history.Open(history.Options{Path: path, ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	now := time.Now()
+	revs, err := store.Changes(now.Add(-since), now)
+	if err != nil {
+		return err
+	}
+	return history.WriteRevisions(os.Stdout, revs, format)
+}
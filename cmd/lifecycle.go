@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"k8s-dev/pkg/controller"
+	dev "k8s-dev/pkg/k8s"
+	"k8s-dev/pkg/lifecycle"
+	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/tools/cache"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+func runLifecycle(args []string) error {
+	var (
+		cf        clientFlags
+		namespace string
+		interval  = lifecycle.DefaultReportInterval
+		retention = lifecycle.DefaultRetention
+		format    string
+	)
+	fs := flag.NewFlagSet("lifecycle", flag.ExitOnError)
+	cf.register(fs)
+	fs.StringVar(&namespace, "n", "", "命名空间，为空时统计所有命名空间")
+	fs.DurationVar(&interval, "interval", interval, "输出报告的间隔")
+	fs.DurationVar(&retention, "retention", retention, "已删除 Pod 的保留时长")
+	fs.StringVar(&format, "o", lifecycle.FormatText, "输出格式：text|json")
+	_ = fs.Parse(args)
+
+	client, err := cf.client()
+	if err != nil {
+		return err
+	}
+	tracker := lifecycle.NewTracker(lifecycle.Options{Retention: retention})
+	pods, err := controller.New(controller.Options[*coreV1.Pod]{
+		Name:      "lifecycle",
+		ListWatch: cache.NewListWatchFromClient(client.CoreV1().RESTClient(), string(dev.POD), namespace, fields.Everything()),
+		Reconcile: tracker.Reconcile,
+	})
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	reports := make(chan error, 1)
+	go func() { reports <- tracker.WriteReports(ctx, os.Stdout, interval, format) }()
+	if err := pods.Run(ctx); err != nil {
+		return err
+	}
+	if err := <-reports; err != nil {
+		return err
+	}
+	// 退出前输出最终报告
+	return lifecycle.WriteReport(os.Stdout, tracker.Report(), format)
+}
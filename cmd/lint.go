@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	dev "k8s-dev/pkg/k8s"
+	"os"
+)
+
+func runLint(args []string) error {
+	var (
+		cf         clientFlags
+		configFile string
+		cluster    bool
+		namespace  string
+		format     string
+	)
+	fs := flag.NewFlagSet("lint", flag.ExitOnError)
+	cf.register(fs)
+	fs.StringVar(&configFile, "config", "", "策略配置文件")
+	fs.BoolVar(&cluster, "cluster", false, "检查集群中正在运行的工作负载")
+	fs.StringVar(&namespace, "n", "", "检查集群时的命名空间，为空时检查所有命名空间")
+	fs.StringVar(&format, "o", dev.FormatText, "输出格式：text|json")
+	_ = fs.Parse(args)
+
+	var config dev.LintConfig
+	if configFile != "" {
+		var err error
+		if config, err = dev.LoadLintConfig(configFile); err != nil {
+			return err
+		}
+	}
+	linter := dev.NewLinter(config)
+
+	var findings []dev.LintFinding
+	for _, file := range fs.Args() {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		result, err := linter.LintManifest(data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+		findings = append(findings, result...)
+	}
+	if cluster {
+		client, err := cf.client()
+		if err != nil {
+			return err
+		}
+		result, err := linter.LintCluster(context.Background(), client, namespace)
+		if err != nil {
+			return err
+		}
+		findings = append(findings, result...)
+	}
+
+	switch format {
+	case dev.FormatJSON:
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(findings); err != nil {
+			return err
+		}
+	case dev.FormatText:
+		for _, finding := range findings {
+			fmt.Println(finding.String())
+		}
+	default:
+		return fmt.Errorf("unsupported output format %q", format)
+	}
+	for _, finding := range findings {
+		if finding.Severity == dev.SeverityError {
+			os.Exit(3)
+		}
+	}
+	return nil
+}
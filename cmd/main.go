@@ -0,0 +1,82 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	dev "k8s-dev/pkg/k8s"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"os"
+	"sort"
+)
+
+// command 子命令，args 为去掉子命令名后的参数
+type command struct {
+	usage string
+	run   func(args []string) error
+}
+
+var commands = map[string]command{
+	"health":    {usage: "汇总集群健康状态", run: runHealth},
+	"diagnose":  {usage: "诊断 Pod 未正常运行的原因", run: runDiagnose},
+	"validate":  {usage: "使用 OpenAPI v3 schema 离线校验清单", run: runValidate},
+	"lint":      {usage: "按工作负载最佳实践检查清单或集群对象", run: runLint},
+	"get":       {usage: "以 kubectl 风格输出资源列表", run: runGet},
+	"watch":     {usage: "把 Pod 的变更输出到标准输出、文件或 webhook", run: runWatch},
+	"lifecycle": {usage: "统计 Pod 生命周期各阶段的耗时", run: runLifecycle},
+	"history":   {usage: "记录 Pod 和 Deployment 的变更历史并查询", run: runHistory},
+}
+
+// clientFlags 所有子命令共用的集群连接参数
+type clientFlags struct {
+	master     string
+	kubeConfig string
+}
+
+func (f *clientFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.master, "master", "", "k8s ApiServer 地址")
+	fs.StringVar(&f.kubeConfig, "kubeconfig", "", ".kube/config 路径，为空时使用默认配置")
+}
+
+func (f *clientFlags) config() (*rest.Config, error) {
+	if f.master == "" && f.kubeConfig == "" {
+		return dev.GetK8SDefaultConfig(), nil
+	}
+	return dev.GetK8SConfig(f.master, f.kubeConfig)
+}
+
+func (f *clientFlags) client() (*kubernetes.Clientset, error) {
+	config, err := f.config()
+	if err != nil {
+		return nil, err
+	}
+	return kubernetes.NewForConfig(config)
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s <command> [flags]\n\nCommands:\n", os.Args[0])
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", name, commands[name].usage)
+	}
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		usage()
+		os.Exit(2)
+	}
+	if err := cmd.run(os.Args[2:]); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	dev "k8s-dev/pkg/k8s"
+	"k8s.io/client-go/discovery"
+	"os"
+	"strings"
+)
+
+// stringSlice 可重复指定的字符串参数
+type stringSlice []string
+
+func (s *stringSlice) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringSlice) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+func runValidate(args []string) error {
+	var (
+		cf          clientFlags
+		schemaFile  string
+		saveSchemas string
+		crdFiles    stringSlice
+	)
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	cf.register(fs)
+	fs.StringVar(&schemaFile, "schemas", "", "本地 OpenAPI v3 schema 文件，为空时通过 discovery 从集群获取")
+	fs.StringVar(&saveSchemas, "save-schemas", "", "把 schema（包括 -crd 指定的 CRD）保存到该文件，供离线使用")
+	fs.Var(&crdFiles, "crd", "CRD 清单文件，可重复指定")
+	_ = fs.Parse(args)
+
+	schemas, err := loadSchemas(cf, schemaFile)
+	if err != nil {
+		return err
+	}
+	for _, file := range crdFiles {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		if err := schemas.AddCRD(data); err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+	}
+	if saveSchemas != "" {
+		if err := schemas.Save(saveSchemas); err != nil {
+			return err
+		}
+	}
+
+	failed := false
+	for _, file := range fs.Args() {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		errs, err := schemas.ValidateManifest(data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+		for _, e := range errs {
+			failed = true
+			fmt.Printf("%s: %s\n", file, e.Error())
+		}
+	}
+	if failed {
+		os.Exit(3)
+	}
+	return nil
+}
+
+func loadSchemas(cf clientFlags, schemaFile string) (*dev.SchemaSet, error) {
+	if schemaFile != "" {
+		return dev.LoadSchemas(schemaFile)
+	}
+	config, err := cf.config()
+	if err != nil {
+		return nil, err
+	}
+	client, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return dev.FetchSchemas(client)
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"k8s-dev/pkg/controller"
+	dev "k8s-dev/pkg/k8s"
+	"k8s-dev/pkg/sink"
+	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+)
+
+// watchFlags watch 子命令的输出参数
+type watchFlags struct {
+	format         string
+	quiet          bool
+	file           sink.FileOptions
+	webhook        sink.WebhookOptions
+	webhookHeaders stringSlice
+	cloudEvents    sink.CloudEventsOptions
+}
+
+func (f *watchFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.format, "o", sink.FormatText, "标准输出格式：text|json")
+	fs.BoolVar(&f.quiet, "q", false, "不输出到标准输出")
+	fs.StringVar(&f.file.Path, "file", "", "以 JSON Lines 格式写入该文件")
+	fs.Int64Var(&f.file.MaxSize, "file-max-size", sink.DefaultMaxFileSize, "文件超过该字节数后轮转")
+	fs.IntVar(&f.file.MaxBackups, "file-max-backups", sink.DefaultMaxBackups, "保留的历史文件数")
+	fs.StringVar(&f.webhook.URL, "webhook", "", "批量 POST 记录到该地址")
+	fs.Var(&f.webhookHeaders, "webhook-header", "webhook 请求头 Key=Value，可重复指定")
+	fs.IntVar(&f.webhook.BatchSize, "webhook-batch", sink.DefaultBatchSize, "webhook 每批的最大记录数")
+	fs.IntVar(&f.webhook.MaxBuffered, "webhook-buffer", sink.DefaultMaxBuffered, "webhook 缓冲的最大记录数，已满时由控制器稍后重试")
+	fs.DurationVar(&f.webhook.FlushInterval, "webhook-interval", sink.DefaultFlushInterval, "webhook 缓冲的最长时间")
+	fs.IntVar(&f.webhook.MaxRetries, "webhook-retries", sink.DefaultMaxRetries, "webhook 发送失败后的重试次数")
+	fs.StringVar(&f.cloudEvents.URL, "cloudevents", "", "以 CloudEvents 格式逐条 POST 到该地址")
+	fs.StringVar(&f.cloudEvents.Mode, "cloudevents-mode", sink.CloudEventsBinary, "CloudEvents 内容模式：binary|structured")
+	fs.StringVar(&f.cloudEvents.Source, "cloudevents-source", sink.DefaultCloudEventsSource, "CloudEvents 的 source 属性")
+}
+
+// sink 按参数组合输出目标
+func (f *watchFlags) sink() (sink.Sink, error) {
+	var sinks []sink.Sink
+	closeAll := func() {
+		_ = sink.Multi(sinks...).Close()
+	}
+	if !f.quiet {
+		s, err := sink.NewStdout(f.format)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, s)
+	}
+	if f.file.Path != "" {
+		s, err := sink.NewFile(f.file)
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		sinks = append(sinks, s)
+	}
+	if f.webhook.URL != "" {
+		f.webhook.Headers = map[string]string{}
+		for _, header := range f.webhookHeaders {
+			k, v, ok := strings.Cut(header, "=")
+			if !ok {
+				closeAll()
+				return nil, fmt.Errorf("invalid webhook header %q, expected Key=Value", header)
+			}
+			f.webhook.Headers[k] = v
+		}
+		s, err := sink.NewWebhook(f.webhook)
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		sinks = append(sinks, s)
+	}
+	if f.cloudEvents.URL != "" {
+		s, err := sink.NewCloudEvents(f.cloudEvents)
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		sinks = append(sinks, s)
+	}
+	if len(sinks) == 0 {
+		return nil, fmt.Errorf("no sink configured")
+	}
+	return sink.Multi(sinks...), nil
+}
+
+func runWatch(args []string) error {
+	var (
+		cf          clientFlags
+		wf          watchFlags
+		namespace   string
+		nsSelector  string
+		contexts    stringSlice
+		metricsAddr string
+	)
+	fs := flag.NewFlagSet("watch", flag.ExitOnError)
+	cf.register(fs)
+	wf.register(fs)
+	fs.StringVar(&namespace, "n", "", "命名空间，为空时监听所有命名空间")
+	fs.StringVar(&nsSelector, "namespace-selector", "", "命名空间标签选择器，只监听匹配的命名空间，命名空间标签变化时自动增减，不能与 -n 同时使用")
+	fs.Var(&contexts, "context", "kubeconfig 中的 context，格式为 context 或 name=context，可重复指定或用逗号分隔，同时监听多个集群")
+	fs.StringVar(&metricsAddr, "metrics-addr", "", "指标和健康检查服务地址，为空时不启动")
+	_ = fs.Parse(args)
+
+	out, err := wf.sink()
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	opts := controller.Options[*coreV1.Pod]{
+		Name:           "watch",
+		Reconcile:      controller.SinkReconciler[*coreV1.Pod](out),
+		Diff:           true,
+		MetricsAddress: metricsAddr,
+	}
+	if len(contexts) > 0 {
+		if nsSelector != "" || cf.master != "" {
+			return fmt.Errorf("-context cannot be used with -namespace-selector or -master")
+		}
+		clusters, err := contextClusters(cf.kubeConfig, contexts, namespace)
+		if err != nil {
+			return err
+		}
+		opts.Clusters = clusters
+	} else if nsSelector != "" {
+		if namespace != "" {
+			return fmt.Errorf("-n and -namespace-selector are mutually exclusive")
+		}
+		selector, err := labels.Parse(nsSelector)
+		if err != nil {
+			return err
+		}
+		client, err := cf.client()
+		if err != nil {
+			return err
+		}
+		opts.Namespaces = &controller.NamespaceSelector{
+			Client:    client,
+			Selector:  selector,
+			ListWatch: dev.GetNamespacedListWatch(client, dev.POD),
+		}
+	} else {
+		client, err := cf.client()
+		if err != nil {
+			return err
+		}
+		opts.ListWatch = cache.NewListWatchFromClient(client.CoreV1().RESTClient(), string(dev.POD), namespace, fields.Everything())
+	}
+	pods, err := controller.New(opts)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return pods.Run(ctx)
+}
+
+// contextClusters 为每个 context 创建监听 namespace 中 Pod 的集群，name=context 时集群名称为 name。
+// 否则集群名称为 context 最后一个 "/" 之后的部分，例如 EKS 的 arn:aws:eks:region:account:cluster/name 为 name
+func contextClusters(kubeConfig string, contexts []string, namespace string) ([]controller.Cluster, error) {
+	var clusters []controller.Cluster
+	for _, value := range contexts {
+		for _, entry := range strings.Split(value, ",") {
+			name, kubeContext, ok := strings.Cut(entry, "=")
+			if !ok {
+				kubeContext = name
+				name = name[strings.LastIndex(name, "/")+1:]
+			}
+			if name == "" || strings.Contains(name, "/") {
+				return nil, fmt.Errorf("context %s: invalid cluster name %q, use name=context to name the cluster", kubeContext, name)
+			}
+			config, err := dev.GetK8SConfigForContext(kubeConfig, kubeContext)
+			if err != nil {
+				return nil, fmt.Errorf("context %s: %w", kubeContext, err)
+			}
+			client, err := kubernetes.NewForConfig(config)
+			if err != nil {
+				return nil, fmt.Errorf("context %s: %w", kubeContext, err)
+			}
+			clusters = append(clusters, controller.Cluster{
+				Name:      name,
+				ListWatch: cache.NewListWatchFromClient(client.CoreV1().RESTClient(), string(dev.POD), namespace, fields.Everything()),
+			})
+		}
+	}
+	return clusters, nil
+}
@@ -0,0 +1,282 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultClusterSyncTimeout 启动时等待单个集群同步的默认时间
+const DefaultClusterSyncTimeout = 30 * time.Second
+
+// Cluster 一个集群中要 watch 的资源
+type Cluster struct {
+	// Name 集群名称，出现在 key 和 Event.Cluster 中，不能为空或包含 "/"
+	Name string
+	// ListWatch 该集群中资源的 ListWatch
+	ListWatch cache.ListerWatcher
+}
+
+// ClusterStatus 集群的同步状态
+type ClusterStatus struct {
+	Name string
+	// Synced 缓存已完成首次同步
+	Synced bool
+	// Unreachable 启动时没有在 ClusterSyncTimeout 内完成同步，informer 会在后台继续重试，恢复后自动处理事件
+	Unreachable bool
+	WatchStats  WatchStats
+}
+
+// ClusterKey 返回 cluster 中对象 key 对应的工作队列 key：cluster/namespace/name
+func ClusterKey(cluster, key string) string {
+	return cluster + "/" + key
+}
+
+// SplitClusterKey 把 ClusterKey 拆分为集群名称和对象的 key
+func SplitClusterKey(key string) (cluster, objectKey string, err error) {
+	cluster, objectKey, ok := strings.Cut(key, "/")
+	if !ok || cluster == "" {
+		return "", "", fmt.Errorf("unexpected cluster key format: %q", key)
+	}
+	return cluster, objectKey, nil
+}
+
+// clusterInformer 一个集群的 informer
+type clusterInformer struct {
+	name     string
+	informer cache.SharedIndexInformer
+	watch    *resumingListWatch
+	timedOut atomic.Bool
+}
+
+// clusterInformers 每个集群一个 informer，Indexer 合并所有集群的缓存
+type clusterInformers struct {
+	controller string
+	timeout    time.Duration
+	indexers   cache.Indexers
+	// names 按名称排序的集群
+	names    []string
+	clusters map[string]*clusterInformer
+}
+
+func newClusterInformers(controller string, clusters []Cluster, objType runtime.Object, resync, timeout time.Duration, indexers cache.Indexers, handler func(cluster string) cache.ResourceEventHandler) (*clusterInformers, error) {
+	if timeout <= 0 {
+		timeout = DefaultClusterSyncTimeout
+	}
+	m := &clusterInformers{controller: controller, timeout: timeout, indexers: indexers, clusters: map[string]*clusterInformer{}}
+	for _, cluster := range clusters {
+		if cluster.Name == "" || strings.Contains(cluster.Name, "/") {
+			return nil, fmt.Errorf("invalid cluster name %q", cluster.Name)
+		}
+		if _, ok := m.clusters[cluster.Name]; ok {
+			return nil, fmt.Errorf("duplicate cluster %q", cluster.Name)
+		}
+		if cluster.ListWatch == nil {
+			return nil, fmt.Errorf("cluster %s: ListWatch is required", cluster.Name)
+		}
+		lw := newResumingListWatch(controller, cluster.ListWatch)
+		informer := cache.NewSharedIndexInformer(lw, objType, resync, indexers)
+		if _, err := informer.AddEventHandler(handler(cluster.Name)); err != nil {
+			return nil, fmt.Errorf("cluster %s: %w", cluster.Name, err)
+		}
+		m.clusters[cluster.Name] = &clusterInformer{name: cluster.Name, informer: informer, watch: lw}
+		m.names = append(m.names, cluster.Name)
+	}
+	sort.Strings(m.names)
+	return m, nil
+}
+
+// run 启动所有集群的 informer，每个集群独立等待同步，不可达的集群不影响其他集群
+func (m *clusterInformers) run(ctx context.Context) {
+	for _, name := range m.names {
+		ci := m.clusters[name]
+		go ci.informer.Run(ctx.Done())
+		go m.waitForSync(ctx, ci)
+	}
+}
+
+func (m *clusterInformers) waitForSync(ctx context.Context, ci *clusterInformer) {
+	timeout, cancel := context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+	if cache.WaitForCacheSync(timeout.Done(), ci.informer.HasSynced) || ctx.Err() != nil {
+		return
+	}
+	ci.timedOut.Store(true)
+	klog.InfoS("Cluster did not sync in time, continuing without it", "controller", m.controller, "cluster", ci.name, "timeout", m.timeout)
+}
+
+// HasSynced 所有集群都已同步，或者已超过等待时间
+func (m *clusterInformers) HasSynced() bool {
+	for _, ci := range m.clusters {
+		if !ci.informer.HasSynced() && !ci.timedOut.Load() {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *clusterInformers) statuses() []ClusterStatus {
+	statuses := make([]ClusterStatus, 0, len(m.names))
+	for _, name := range m.names {
+		ci := m.clusters[name]
+		synced := ci.informer.HasSynced()
+		statuses = append(statuses, ClusterStatus{
+			Name:        name,
+			Synced:      synced,
+			Unreachable: !synced && ci.timedOut.Load(),
+			WatchStats:  ci.watch.watchStats(),
+		})
+	}
+	return statuses
+}
+
+func (m *clusterInformers) watchStats() WatchStats {
+	var total WatchStats
+	for _, ci := range m.clusters {
+		stats := ci.watch.watchStats()
+		total.Relists += stats.Relists
+		total.Resumes += stats.Resumes
+		total.Bookmarks += stats.Bookmarks
+		total.Expired += stats.Expired
+	}
+	return total
+}
+
+// indexer 返回集群的缓存，其中的 key 不带集群名称
+func (m *clusterInformers) indexer(cluster string) (cache.Indexer, bool) {
+	ci, ok := m.clusters[cluster]
+	if !ok {
+		return nil, false
+	}
+	return ci.informer.GetIndexer(), true
+}
+
+// clusterIndexer 合并所有集群缓存的 cache.Indexer，key 为 ClusterKey。
+// 对象本身不带集群信息，因此不支持写入和 Get
+type clusterIndexer struct {
+	m *clusterInformers
+}
+
+var errClusterIndexer = errors.New("not supported by the merged cluster indexer, use the cluster's own indexer")
+
+func (c clusterIndexer) each(fn func(cluster string, indexer cache.Indexer) error) error {
+	for _, name := range c.m.names {
+		if err := fn(name, c.m.clusters[name].informer.GetIndexer()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c clusterIndexer) Add(interface{}) error {
+	return errClusterIndexer
+}
+
+func (c clusterIndexer) Update(interface{}) error {
+	return errClusterIndexer
+}
+
+func (c clusterIndexer) Delete(interface{}) error {
+	return errClusterIndexer
+}
+
+func (c clusterIndexer) List() []interface{} {
+	var items []interface{}
+	_ = c.each(func(_ string, indexer cache.Indexer) error {
+		items = append(items, indexer.List()...)
+		return nil
+	})
+	return items
+}
+
+func (c clusterIndexer) ListKeys() []string {
+	var keys []string
+	_ = c.each(func(cluster string, indexer cache.Indexer) error {
+		for _, key := range indexer.ListKeys() {
+			keys = append(keys, ClusterKey(cluster, key))
+		}
+		return nil
+	})
+	return keys
+}
+
+func (c clusterIndexer) Get(interface{}) (item interface{}, exists bool, err error) {
+	return nil, false, errClusterIndexer
+}
+
+func (c clusterIndexer) GetByKey(key string) (item interface{}, exists bool, err error) {
+	cluster, objectKey, err := SplitClusterKey(key)
+	if err != nil {
+		return nil, false, err
+	}
+	indexer, ok := c.m.indexer(cluster)
+	if !ok {
+		return nil, false, nil
+	}
+	return indexer.GetByKey(objectKey)
+}
+
+func (c clusterIndexer) Replace([]interface{}, string) error {
+	return errClusterIndexer
+}
+
+func (c clusterIndexer) Resync() error {
+	return errClusterIndexer
+}
+
+func (c clusterIndexer) Index(indexName string, obj interface{}) ([]interface{}, error) {
+	var items []interface{}
+	err := c.each(func(_ string, indexer cache.Indexer) error {
+		matched, err := indexer.Index(indexName, obj)
+		items = append(items, matched...)
+		return err
+	})
+	return items, err
+}
+
+func (c clusterIndexer) IndexKeys(indexName, indexedValue string) ([]string, error) {
+	var keys []string
+	err := c.each(func(cluster string, indexer cache.Indexer) error {
+		matched, err := indexer.IndexKeys(indexName, indexedValue)
+		for _, key := range matched {
+			keys = append(keys, ClusterKey(cluster, key))
+		}
+		return err
+	})
+	return keys, err
+}
+
+func (c clusterIndexer) ListIndexFuncValues(indexName string) []string {
+	values := sets.NewString()
+	_ = c.each(func(_ string, indexer cache.Indexer) error {
+		values.Insert(indexer.ListIndexFuncValues(indexName)...)
+		return nil
+	})
+	return values.List()
+}
+
+func (c clusterIndexer) ByIndex(indexName, indexedValue string) ([]interface{}, error) {
+	var items []interface{}
+	err := c.each(func(_ string, indexer cache.Indexer) error {
+		matched, err := indexer.ByIndex(indexName, indexedValue)
+		items = append(items, matched...)
+		return err
+	})
+	return items, err
+}
+
+func (c clusterIndexer) GetIndexers() cache.Indexers {
+	return c.m.indexers
+}
+
+func (c clusterIndexer) AddIndexers(cache.Indexers) error {
+	return errClusterIndexer
+}
@@ -0,0 +1,652 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"k8s-dev/pkg/diff"
+	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/clock"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultDrainTimeout 停止时等待正在处理的事件完成的默认时间
+const DefaultDrainTimeout = 30 * time.Second
+
+// Reconciler 控制器的业务逻辑，event 中包含变更类型以及变更前后的对象。
+// 返回错误时由 RetryPolicy 决定是否重试，重试逻辑不应是业务逻辑的一部分。
+// ctx 在控制器停止且超过 DrainTimeout 后取消。
+type Reconciler[T runtime.Object] func(ctx context.Context, event Event[T]) error
+
+// Options 创建控制器的参数，ListWatch 和 Informer 二选一
+type Options[T runtime.Object] struct {
+	// Name 控制器名称，同时作为工作队列名称
+	Name string
+	// ListWatch 控制器自己创建并运行 informer。watch 会请求 bookmark，
+	// 出错后从最新的 resourceVersion 继续，只有过期时才重新 List，见 WatchStats
+	ListWatch cache.ListerWatcher
+	// Informer 使用共享的 informer，需要由调用方启动，例如 SharedInformerFactory.Start
+	Informer cache.SharedIndexInformer
+	// Namespaces 按标签动态选择命名空间，每个命名空间一个 informer。Indexer 合并所有命名空间的缓存，
+	// Informer 返回 nil，不支持 Snapshot
+	Namespaces *NamespaceSelector
+	// Clusters 在多个集群中 watch 同一种资源，每个集群一个 informer，工作队列的 key 带集群名称，见 ClusterKey。
+	// Indexer 合并所有集群的缓存，Informer 返回 nil，不支持 Snapshot
+	Clusters []Cluster
+	// ClusterSyncTimeout 启动时等待每个集群同步的时间，超时的集群不阻塞其他集群，默认 DefaultClusterSyncTimeout
+	ClusterSyncTimeout time.Duration
+	// ResyncPeriod 仅在使用 ListWatch、Namespaces 或 Clusters 时生效
+	ResyncPeriod time.Duration
+	// Indexers 仅在使用 ListWatch、Namespaces 或 Clusters 时生效
+	Indexers cache.Indexers
+	// Reconcile 业务逻辑
+	Reconcile Reconciler[T]
+	// Predicates 事件加入队列前的过滤条件，全部通过才会加入队列
+	Predicates []Predicate
+	// Diff 处理 Updated 事件前计算字段差异，保存在 Event.Diff 中
+	Diff bool
+	// DiffIgnore 计算差异时忽略的字段，默认 diff.DefaultIgnore
+	DiffIgnore []string
+	// Shard 不为空时只处理分给当前副本的 key，Run 期间同时运行 Sharder
+	Shard *Sharder
+	// EventRecorder 通过 RecorderFrom(ctx) 提供给 Reconciler，放弃重试时也会在对象上记录 Warning 事件。
+	// 一般使用 NewEventRecorder 创建
+	EventRecorder record.EventRecorder
+	// Workers 并发处理的协程数，默认 1
+	Workers int
+	// DrainTimeout 停止时等待正在处理的事件完成的时间，默认 DefaultDrainTimeout
+	DrainTimeout time.Duration
+	// RetryPolicy 重试策略，默认 DefaultRetryPolicy()
+	RetryPolicy RetryPolicy
+	// DeadLetters 放弃重试的事件存放位置，默认 NewDeadLetterStore()
+	DeadLetters DeadLetterStore[T]
+	// Queue 工作队列使用的延迟队列，重试按 RetryPolicy 的延迟加入其中，默认 workqueue.NewNamedDelayingQueue(Name)。
+	// 测试可以替换为由假时钟控制的队列，见 controllertest.Harness
+	Queue workqueue.DelayingInterface
+	// Clock 死信的 DroppedAt 使用的时钟，默认真实时钟
+	Clock clock.PassiveClock
+	// Notified 每个 informer 通知处理完（加入队列或被过滤）后调用，测试用来等待控制器追上事件源
+	Notified func()
+	// LeaderElection 不为空时开启选主，只有 leader 处理事件
+	LeaderElection *LeaderElection
+	// MetricsAddress 不为空时在 Run 期间启动 /metrics、/healthz 和 /readyz 服务，例如 DefaultServerAddress。
+	// 多个控制器共用一个地址时使用 NewServer
+	MetricsAddress string
+	// Snapshot 不为空时从快照热启动，并按配置保存快照
+	Snapshot *SnapshotOptions
+}
+
+// Controller 基于 informer 和限速工作队列的通用控制器，
+// 使用者只需要提供 Reconcile 函数
+type Controller[T runtime.Object] struct {
+	name         string
+	queue        workqueue.RateLimitingInterface
+	pending      *pendingEvents[T]
+	informer     cache.SharedIndexInformer
+	ownInformer  bool
+	namespaces   *namespaceInformers
+	clusters     *clusterInformers
+	watch        *resumingListWatch
+	reconcile    Reconciler[T]
+	predicates   []Predicate
+	diff         bool
+	diffIgnore   []string
+	recorder     record.EventRecorder
+	shard        *Sharder
+	handoff      map[string]*Event[T]
+	handoffLock  sync.Mutex
+	workers      int
+	drainTimeout time.Duration
+	retry        RetryPolicy
+	deadLetters  DeadLetterStore[T]
+	election     *LeaderElection
+	leading      atomic.Bool
+	metricsAddr  string
+	snapshot     *SnapshotOptions
+	clock        clock.PassiveClock
+	notified     func()
+}
+
+// New
+//
+//	@Description: 创建控制器，并把 informer 的事件注册到工作队列
+//	@param opts
+//	@return *Controller[T]
+//	@return error
+func New[T runtime.Object](opts Options[T]) (*Controller[T], error) {
+	if opts.Reconcile == nil {
+		return nil, fmt.Errorf("controller %s: Reconcile is required", opts.Name)
+	}
+	sources := 0
+	for _, set := range []bool{opts.ListWatch != nil, opts.Informer != nil, opts.Namespaces != nil, len(opts.Clusters) > 0} {
+		if set {
+			sources++
+		}
+	}
+	if sources != 1 {
+		return nil, fmt.Errorf("controller %s: exactly one of ListWatch, Informer, Namespaces and Clusters is required", opts.Name)
+	}
+	if opts.Namespaces != nil && opts.Snapshot != nil && opts.Snapshot.Path != "" {
+		return nil, fmt.Errorf("controller %s: Snapshot is not supported with Namespaces", opts.Name)
+	}
+	if len(opts.Clusters) > 0 && opts.Snapshot != nil && opts.Snapshot.Path != "" {
+		return nil, fmt.Errorf("controller %s: Snapshot is not supported with Clusters", opts.Name)
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = 1
+	}
+	if opts.DrainTimeout <= 0 {
+		opts.DrainTimeout = DefaultDrainTimeout
+	}
+	if opts.RetryPolicy == nil {
+		opts.RetryPolicy = DefaultRetryPolicy()
+	}
+	if opts.DeadLetters == nil {
+		opts.DeadLetters = NewDeadLetterStore[T]()
+	}
+	if opts.Queue == nil {
+		opts.Queue = workqueue.NewNamedDelayingQueue(opts.Name)
+	}
+	if opts.Clock == nil {
+		opts.Clock = clock.RealClock{}
+	}
+	if opts.LeaderElection != nil {
+		if err := opts.LeaderElection.complete(); err != nil {
+			return nil, fmt.Errorf("controller %s: %w", opts.Name, err)
+		}
+	}
+
+	c := &Controller[T]{
+		name:         opts.Name,
+		queue:        workqueue.NewRateLimitingQueueWithDelayingInterface(opts.Queue, opts.RetryPolicy),
+		pending:      newPendingEvents[T](),
+		informer:     opts.Informer,
+		reconcile:    opts.Reconcile,
+		predicates:   opts.Predicates,
+		diff:         opts.Diff,
+		diffIgnore:   opts.DiffIgnore,
+		recorder:     opts.EventRecorder,
+		shard:        opts.Shard,
+		workers:      opts.Workers,
+		drainTimeout: opts.DrainTimeout,
+		retry:        opts.RetryPolicy,
+		deadLetters:  opts.DeadLetters,
+		election:     opts.LeaderElection,
+		metricsAddr:  opts.MetricsAddress,
+		snapshot:     opts.Snapshot,
+		clock:        opts.Clock,
+		notified:     opts.Notified,
+	}
+	if c.shard != nil {
+		c.handoff = map[string]*Event[T]{}
+		c.predicates = append([]Predicate{c.shard.Predicate()}, c.predicates...)
+		c.shard.onActivate(c.enqueueGained)
+	}
+	indexers := opts.Indexers
+	if indexers == nil {
+		indexers = cache.Indexers{}
+	}
+	if len(opts.Clusters) > 0 {
+		clusters, err := newClusterInformers(opts.Name, opts.Clusters, newObject[T](), opts.ResyncPeriod, opts.ClusterSyncTimeout, indexers, c.handler)
+		if err != nil {
+			return nil, fmt.Errorf("controller %s: %w", opts.Name, err)
+		}
+		c.clusters = clusters
+		return c, nil
+	}
+	if opts.Namespaces != nil {
+		namespaces, err := newNamespaceInformers(opts.Name, *opts.Namespaces, newObject[T](), opts.ResyncPeriod, indexers, c.handler(""))
+		if err != nil {
+			return nil, fmt.Errorf("controller %s: %w", opts.Name, err)
+		}
+		c.namespaces = namespaces
+		return c, nil
+	}
+	if c.informer == nil {
+		c.watch = newResumingListWatch(opts.Name, opts.ListWatch)
+		if opts.Snapshot != nil && opts.Snapshot.Path != "" {
+			if err := seedFromSnapshot[T](opts.Name, opts.Snapshot.Path, c.watch); err != nil {
+				return nil, fmt.Errorf("controller %s: %w", opts.Name, err)
+			}
+		}
+		c.informer = cache.NewSharedIndexInformer(c.watch, newObject[T](), opts.ResyncPeriod, indexers)
+		c.ownInformer = true
+	}
+
+	if _, err := c.informer.AddEventHandler(c.handler("")); err != nil {
+		return nil, fmt.Errorf("controller %s: %w", opts.Name, err)
+	}
+	return c, nil
+}
+
+// newObject 创建 T 的实例，作为 informer 的示例对象
+func newObject[T runtime.Object]() T {
+	var zero T
+	t := reflect.TypeOf(zero)
+	if t != nil && t.Kind() == reflect.Pointer {
+		return reflect.New(t.Elem()).Interface().(T)
+	}
+	return zero
+}
+
+// handler 返回 cluster 中 informer 的事件处理函数，不使用 Clusters 时 cluster 为空
+func (c *Controller[T]) handler(cluster string) cache.ResourceEventHandler {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			c.enqueue(cluster, cache.Added, nil, obj)
+		},
+		UpdateFunc: func(old interface{}, new interface{}) {
+			c.enqueue(cluster, cache.Updated, old, new)
+		},
+		DeleteFunc: func(obj interface{}) {
+			c.enqueue(cluster, cache.Deleted, nil, obj)
+		},
+	}
+}
+
+// enqueue 把 informer 事件转换为 Event 放入待处理集合，并把对象的 key 加入工作队列
+func (c *Controller[T]) enqueue(cluster string, action cache.DeltaType, old, obj interface{}) {
+	if c.notified != nil {
+		defer c.notified()
+	}
+	event := &Event[T]{Type: action, Cluster: cluster}
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		event.Key, event.Tombstone, obj = tombstone.Key, true, tombstone.Obj
+	} else {
+		key, err := cache.MetaNamespaceKeyFunc(obj)
+		if err != nil {
+			utilruntime.HandleError(fmt.Errorf("controller %s: %w", c.name, err))
+			return
+		}
+		event.Key = key
+	}
+	if cluster != "" {
+		event.Key = ClusterKey(cluster, event.Key)
+	}
+	if typed, ok := obj.(T); ok {
+		event.Object = typed
+	}
+	if typed, ok := old.(T); ok {
+		event.Old = typed
+	}
+	if action == cache.Deleted && c.shard != nil && c.shard.gaining(event.Key) {
+		// 原来的副本已停止处理该 key，当前副本在 enqueueGained 中补发删除事件
+		c.handoffLock.Lock()
+		c.handoff[event.Key] = event
+		c.handoffLock.Unlock()
+		return
+	}
+	c.add(event)
+}
+
+// add 经过 predicates 过滤后把事件加入队列
+func (c *Controller[T]) add(event *Event[T]) {
+	if len(c.predicates) > 0 && !allow(c.predicates, toUntyped(event)) {
+		return
+	}
+	c.pending.add(event)
+	c.queue.Add(event.Key)
+}
+
+// Enqueue 手动把缓存中的对象加入队列，Event.Type 为 cache.Sync；对象有未处理的事件时不做任何事
+func (c *Controller[T]) Enqueue(key string) error {
+	obj, exists, err := c.Indexer().GetByKey(key)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("controller %s: %s not found in cache", c.name, key)
+	}
+	typed, ok := obj.(T)
+	if !ok {
+		return fmt.Errorf("controller %s: unexpected object type %T for %s", c.name, obj, key)
+	}
+	if !c.pending.has(key) {
+		event := &Event[T]{Type: cache.Sync, Key: key, Old: typed, Object: typed}
+		if c.clusters != nil {
+			event.Cluster, _, _ = SplitClusterKey(key)
+		}
+		c.pending.add(event)
+	}
+	c.queue.Add(key)
+	return nil
+}
+
+// DeadLetters 返回放弃重试的事件
+func (c *Controller[T]) DeadLetters() DeadLetterStore[T] {
+	return c.deadLetters
+}
+
+// Requeue 把死信重新加入队列，期间对象的新事件会与死信合并
+func (c *Controller[T]) Requeue(key string) error {
+	letter, ok := c.deadLetters.Get(key)
+	if !ok {
+		return fmt.Errorf("controller %s: no dead letter for %s", c.name, key)
+	}
+	c.deadLetters.Delete(key)
+	event := letter.Event
+	c.pending.restore(&event)
+	c.queue.Add(key)
+	return nil
+}
+
+// RequeueAll 把所有死信重新加入队列，返回重新入队的数量
+func (c *Controller[T]) RequeueAll() int {
+	n := 0
+	for _, letter := range c.deadLetters.List() {
+		if c.Requeue(letter.Event.Key) == nil {
+			n++
+		}
+	}
+	return n
+}
+
+// Name 返回控制器名称
+func (c *Controller[T]) Name() string {
+	return c.name
+}
+
+// Informer 返回控制器使用的 informer，使用 Namespaces 或 Clusters 时返回 nil
+func (c *Controller[T]) Informer() cache.SharedIndexInformer {
+	return c.informer
+}
+
+// Indexer 返回 informer 的本地缓存，使用 Namespaces 时合并所有命名空间的缓存，
+// 使用 Clusters 时合并所有集群的缓存，key 为 ClusterKey，不支持写入
+func (c *Controller[T]) Indexer() cache.Indexer {
+	if c.clusters != nil {
+		return clusterIndexer{m: c.clusters}
+	}
+	if c.namespaces != nil {
+		return mergedIndexer{n: c.namespaces}
+	}
+	return c.informer.GetIndexer()
+}
+
+// HasSynced 缓存是否已完成首次同步，
+// 使用 Clusters 时不可达的集群超过 ClusterSyncTimeout 后视为已同步
+func (c *Controller[T]) HasSynced() bool {
+	if c.clusters != nil {
+		return c.clusters.HasSynced()
+	}
+	if c.namespaces != nil {
+		return c.namespaces.HasSynced()
+	}
+	return c.informer.HasSynced()
+}
+
+// Namespaces 返回正在 watch 的命名空间，只在使用 Namespaces 时有效
+func (c *Controller[T]) Namespaces() []string {
+	if c.namespaces == nil {
+		return nil
+	}
+	return c.namespaces.names()
+}
+
+// Clusters 返回每个集群的同步状态，只在使用 Clusters 时有效
+func (c *Controller[T]) Clusters() []ClusterStatus {
+	if c.clusters == nil {
+		return nil
+	}
+	return c.clusters.statuses()
+}
+
+// ClusterIndexer 返回一个集群的缓存，其中的 key 不带集群名称
+func (c *Controller[T]) ClusterIndexer(cluster string) (cache.Indexer, bool) {
+	if c.clusters == nil {
+		return nil, false
+	}
+	return c.clusters.indexer(cluster)
+}
+
+// WatchStats 返回 List/Watch 的统计，使用共享 Informer 时只有 ResourceVersion，
+// 使用 Namespaces 或 Clusters 时为所有 informer 的合计，没有 ResourceVersion
+func (c *Controller[T]) WatchStats() WatchStats {
+	if c.clusters != nil {
+		return c.clusters.watchStats()
+	}
+	if c.namespaces != nil {
+		return c.namespaces.watchStats()
+	}
+	if c.watch == nil {
+		return WatchStats{ResourceVersion: c.informer.LastSyncResourceVersion()}
+	}
+	return c.watch.watchStats()
+}
+
+// ProcessNextItem 在当前协程处理队列中的一个 key，队列为空时阻塞，队列关闭或 ctx 取消后返回 false。
+// 用于不调用 Run 而逐个驱动控制器的测试，例如 controllertest.Harness
+func (c *Controller[T]) ProcessNextItem(ctx context.Context) bool {
+	return c.processNextItem(ctx, withRecorder(ctx, c.recorder))
+}
+
+func (c *Controller[T]) processNextItem(ctx, workCtx context.Context) bool {
+	// 等待工作队列中有新 item
+	key, quit := c.queue.Get()
+	if quit {
+		return false
+	}
+
+	// 告诉队列我们已经处理完此key。这将为其他工作人员解锁key
+	// 这允许安全的并行处理，因为具有相同key的两个对象永远不会被并行处理
+	defer c.queue.Done(key)
+
+	// 控制器停止后不再处理新的 item
+	if ctx.Err() != nil {
+		return false
+	}
+	// 加入队列后 key 被分给了其他副本，交给新的副本处理；或命名空间已不再被选中
+	if !c.owns(key.(string)) {
+		c.pending.take(key.(string))
+		c.queue.Forget(key)
+		return true
+	}
+
+	event, ok := c.pending.take(key.(string))
+	if !ok {
+		// 事件已被其他 worker 合并处理
+		c.queue.Forget(key)
+		return true
+	}
+	if c.diff && event.Type == cache.Updated && event.Diff == nil {
+		c.computeDiff(event)
+	}
+	// 调用包含业务逻辑的方法
+	start := time.Now()
+	err := c.reconcile(workCtx, *event)
+	observeReconcile(c.name, start, err)
+	// 如果在执行业务逻辑过程中出现问题，则处理错误
+	c.handleErr(err, event)
+	return true
+}
+
+// owns 当前副本是否仍然负责 key
+func (c *Controller[T]) owns(key string) bool {
+	if c.shard != nil && !c.shard.Owns(key) {
+		return false
+	}
+	if c.namespaces != nil {
+		namespace, _, err := cache.SplitMetaNamespaceKey(key)
+		return err == nil && c.namespaces.watching(namespace)
+	}
+	return true
+}
+
+// enqueueGained 分片交接完成后，把新分到当前副本的 key 加入队列，并重放交接期间被过滤的删除事件
+func (c *Controller[T]) enqueueGained(previous, _ *Ring) {
+	self := c.shard.Identity()
+	for _, key := range c.Indexer().ListKeys() {
+		if previous.Owner(key) != self && c.shard.Owns(key) {
+			if err := c.Enqueue(key); err != nil {
+				utilruntime.HandleError(err)
+			}
+		}
+	}
+
+	c.handoffLock.Lock()
+	deletes := c.handoff
+	c.handoff = map[string]*Event[T]{}
+	c.handoffLock.Unlock()
+	for key, event := range deletes {
+		// 交接期间重新创建的对象已经在上面以 Sync 事件加入队列
+		if _, exists, _ := c.Indexer().GetByKey(key); exists || !c.shard.Owns(key) {
+			continue
+		}
+		c.add(event)
+	}
+}
+
+// computeDiff 计算事件的字段差异，合并的多次更新以最初的 Old 为准
+func (c *Controller[T]) computeDiff(event *Event[T]) {
+	if isNil(event.Old) || isNil(event.Object) {
+		return
+	}
+	changes, err := diff.Objects(event.Old, event.Object, c.diffIgnore...)
+	if err != nil {
+		utilruntime.HandleError(fmt.Errorf("controller %s: diff %s: %w", c.name, event.Key, err))
+		return
+	}
+	event.Diff = changes
+}
+
+// handleErr 检查是否发生错误，并确保稍后重试
+func (c *Controller[T]) handleErr(err error, event *Event[T]) {
+	key := event.Key
+	if err == nil {
+		c.queue.Forget(key)
+		return
+	}
+
+	if c.retry.ShouldRetry(key, err) {
+		klog.V(2).InfoS("Error syncing", "controller", c.name, "key", key, "err", err)
+		// 限制key速率重新排队。基于队列和重新排队历史记录，稍后将再次处理key
+		c.pending.restore(event)
+		c.queue.AddRateLimited(key)
+		return
+	}
+
+	attempts := c.queue.NumRequeues(key) + 1
+	c.queue.Forget(key)
+	// 放弃重试，事件进入死信存储，可以通过 Requeue 重新处理
+	deadLettersTotal.WithLabelValues(c.name).Inc()
+	c.deadLetters.Put(DeadLetter[T]{Event: *event, LastError: err, Attempts: attempts, DroppedAt: c.clock.Now()})
+	utilruntime.HandleError(fmt.Errorf("controller %s: dropping %v out of the queue after %d attempts: %w", c.name, key, attempts, err))
+	if c.recorder != nil && !isNil(event.Object) && event.Type != cache.Deleted {
+		c.recorder.Eventf(event.Object, coreV1.EventTypeWarning, "ReconcileFailed", "%s gave up after %d attempts: %v", c.name, attempts, err)
+	}
+}
+
+// Run
+//
+//	@Description: 启动 informer（仅限控制器自己创建的）和 worker，直到 ctx 取消。
+//	取消后不再处理新的事件，并最多等待 DrainTimeout 让正在处理的事件完成。
+//	开启选主时，缓存同步后先进入备用状态，当选后才启动 worker
+//	@param ctx
+//	@return error: 缓存同步失败、等待超时或失去 leader 身份
+func (c *Controller[T]) Run(ctx context.Context) error {
+	defer utilruntime.HandleCrash()
+	defer c.queue.ShutDown()
+
+	klog.InfoS("Starting controller", "controller", c.name)
+	if c.metricsAddr != "" {
+		go func() {
+			if err := NewServer(c.metricsAddr, c).Run(ctx); err != nil {
+				utilruntime.HandleError(fmt.Errorf("controller %s: %w", c.name, err))
+			}
+		}()
+	}
+	if c.ownInformer {
+		go c.informer.Run(ctx.Done())
+	}
+	if c.namespaces != nil {
+		go c.namespaces.run(ctx)
+	}
+	if c.clusters != nil {
+		c.clusters.run(ctx)
+	}
+
+	synced := []cache.InformerSynced{c.HasSynced}
+	if c.shard != nil {
+		go func() {
+			if err := c.shard.Run(ctx); err != nil {
+				utilruntime.HandleError(fmt.Errorf("controller %s: %w", c.name, err))
+			}
+		}()
+		synced = append(synced, c.shard.HasSynced)
+	}
+
+	// 在开始处理队列中的项目之前，等待所有相关的缓存同步
+	if !cache.WaitForNamedCacheSync(c.name, ctx.Done(), synced...) {
+		return fmt.Errorf("controller %s: failed to wait for caches to sync: %w", c.name, ctx.Err())
+	}
+
+	// 只在缓存同步后保存快照，避免用不完整的缓存覆盖已有的快照
+	if c.snapshot != nil && c.snapshot.Path != "" {
+		if c.snapshot.Interval > 0 {
+			go c.saveSnapshots(ctx)
+		}
+		if c.snapshot.SaveOnShutdown {
+			defer func() {
+				if err := c.SaveSnapshot(c.snapshot.Path); err != nil {
+					utilruntime.HandleError(err)
+				}
+			}()
+		}
+	}
+
+	if c.election != nil {
+		return c.lead(ctx)
+	}
+	return c.process(ctx)
+}
+
+// process 启动 worker 处理队列直到 ctx 取消，然后等待正在处理的事件完成
+func (c *Controller[T]) process(ctx context.Context) error {
+	defer c.queue.ShutDown()
+
+	// workCtx 传给 Reconciler，只有等待超时后才取消，保证正在处理的事件可以完成
+	workCtx, cancelWork := context.WithCancel(withRecorder(context.Background(), c.recorder))
+	defer cancelWork()
+	var wg sync.WaitGroup
+	for i := 0; i < c.workers; i++ {
+		wg.Add(1)
+		// 启动一个协程，每隔一定的时间，就去运行runWorker函数，直到 ctx 取消
+		go func() {
+			defer wg.Done()
+			wait.UntilWithContext(ctx, func(ctx context.Context) {
+				c.runWorker(ctx, workCtx)
+			}, time.Second)
+		}()
+	}
+
+	<-ctx.Done()
+	klog.InfoS("Stopping controller", "controller", c.name)
+	// 唤醒阻塞在 Get 上的 worker
+	c.queue.ShutDown()
+
+	drained := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		return nil
+	case <-time.After(c.drainTimeout):
+		return fmt.Errorf("controller %s: timed out after %v waiting for workers to finish", c.name, c.drainTimeout)
+	}
+}
+
+func (c *Controller[T]) runWorker(ctx, workCtx context.Context) {
+	for c.processNextItem(ctx, workCtx) {
+	}
+}
@@ -0,0 +1,448 @@
+// Package controllertest 提供在测试中确定性地驱动 controller.Controller 的工具
+package controllertest
+
+import (
+	"context"
+	"fmt"
+	"k8s-dev/pkg/controller"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+	fcache "k8s.io/client-go/tools/cache/testing"
+	"k8s.io/client-go/util/workqueue"
+	clocktesting "k8s.io/utils/clock/testing"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ReconcileCall Harness 记录的一次 Reconcile 调用
+type ReconcileCall[T runtime.Object] struct {
+	Event controller.Event[T]
+	Err   error
+}
+
+// DefaultTimeout Harness 等待 informer 处理事件的最长时间
+const DefaultTimeout = 10 * time.Second
+
+// Harness 确定性地驱动控制器的测试工具。控制器使用真实的 SharedIndexInformer，
+// 事件由 Harness 的 reflector 从 Source List/Watch 后经 DeltaFIFO 交给 informer 处理，
+// Add、Update、Delete、Relist 修改 Source 后等待控制器收到通知才返回，
+// 因此 tombstone、relist 产生的 Replaced 通知和额外注册的 handler 与真实集群中的行为一致。
+// watch 结束后 reflector 立即重新 List，不经过 informer 的退避。
+// 工作队列的重试延迟由假时钟控制，测试调用 Step 或 RunUntilIdle 在当前协程中处理队列，不需要集群也不需要 sleep。
+// Harness 不运行选主、指标服务和快照，不支持 Options.Shard 和 ResyncPeriod
+type Harness[T runtime.Object] struct {
+	// Controller 被测试的控制器，Indexer、Informer、DeadLetters 等方法可以直接使用
+	Controller *controller.Controller[T]
+	// Clock 工作队列和死信使用的假时钟，通过 Advance 推进
+	Clock *clocktesting.FakeClock
+	// Source informer 的事件源，对象的 resourceVersion 由它分配。
+	// 直接调用 AddDropWatch、DeleteDropWatch 等方法时 informer 要在 Relist 后才能看到变化
+	Source *fcache.FakeControllerSource
+
+	queue  *fakeDelayingQueue
+	retry  controller.RetryPolicy
+	fifo   *cache.DeltaFIFO
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	lock  sync.Mutex
+	calls []ReconcileCall[T]
+	// expected 应收到的 informer 通知数，observed 控制器已处理的通知数
+	expected, observed int
+	notify             chan struct{}
+	// expire 关闭时当前的 watch 以 410 Expired 结束，reflector 重新 List
+	expire chan struct{}
+}
+
+// deltaHandler SharedIndexInformer 处理 DeltaFIFO 中的变更的方法
+type deltaHandler interface {
+	HandleDeltas(obj interface{}) error
+}
+
+// NewHarness
+//
+//	@Description: 使用 opts 创建控制器，接入假的事件源、时钟和工作队列并启动 informer，
+//	opts 中不能设置 ListWatch、Informer、Queue、Clock 和 Notified
+//	@param opts
+//	@return *Harness[T]
+//	@return error
+func NewHarness[T runtime.Object](opts controller.Options[T]) (*Harness[T], error) {
+	if opts.ListWatch != nil || opts.Informer != nil || opts.Namespaces != nil || len(opts.Clusters) > 0 {
+		return nil, fmt.Errorf("controller %s: harness provides its own informer", opts.Name)
+	}
+	if opts.Queue != nil || opts.Clock != nil || opts.Notified != nil {
+		return nil, fmt.Errorf("controller %s: harness provides its own Queue, Clock and Notified", opts.Name)
+	}
+	if opts.Shard != nil {
+		return nil, fmt.Errorf("controller %s: harness does not support Shard", opts.Name)
+	}
+	if opts.ResyncPeriod > 0 {
+		return nil, fmt.Errorf("controller %s: harness does not support ResyncPeriod", opts.Name)
+	}
+	if opts.RetryPolicy == nil {
+		opts.RetryPolicy = controller.DefaultRetryPolicy()
+	}
+	h := &Harness[T]{
+		Clock:  clocktesting.NewFakeClock(time.Now()),
+		Source: fcache.NewFakeControllerSource(),
+		retry:  opts.RetryPolicy,
+		notify: make(chan struct{}, 1),
+		expire: make(chan struct{}),
+	}
+	indexers := opts.Indexers
+	if indexers == nil {
+		indexers = cache.Indexers{}
+	}
+	// informer 自己的 reflector watch 一个空的事件源，只用来启动 informer，事件由 h.fifo 交给 informer
+	informer := cache.NewSharedIndexInformer(fcache.NewFakeControllerSource(), newObject[T](), 0, indexers)
+	handler, ok := informer.(deltaHandler)
+	if !ok {
+		return nil, fmt.Errorf("controller %s: informer %T cannot handle deltas", opts.Name, informer)
+	}
+	h.queue = newFakeDelayingQueue(opts.Name, h.Clock)
+	opts.Informer = informer
+	opts.Queue = h.queue
+	opts.Clock = h.Clock
+	opts.Notified = h.notified
+	if reconcile := opts.Reconcile; reconcile != nil {
+		opts.Reconcile = func(ctx context.Context, event controller.Event[T]) error {
+			err := reconcile(ctx, event)
+			h.lock.Lock()
+			h.calls = append(h.calls, ReconcileCall[T]{Event: event, Err: err})
+			h.lock.Unlock()
+			return err
+		}
+	}
+	c, err := controller.New(opts)
+	if err != nil {
+		return nil, err
+	}
+	h.Controller = c
+
+	h.ctx, h.cancel = context.WithCancel(context.Background())
+	go informer.Run(h.ctx.Done())
+	syncCtx, cancel := context.WithTimeout(h.ctx, DefaultTimeout)
+	defer cancel()
+	if !cache.WaitForCacheSync(syncCtx.Done(), informer.HasSynced) {
+		h.Close()
+		return nil, fmt.Errorf("controller %s: harness informer did not sync", opts.Name)
+	}
+	h.fifo = cache.NewDeltaFIFOWithOptions(cache.DeltaFIFOOptions{KnownObjects: informer.GetIndexer(), EmitDeltaTypeReplaced: true})
+	go h.runReflector(opts.Name, newObject[T]())
+	go h.process(handler)
+	if !cache.WaitForCacheSync(syncCtx.Done(), h.fifo.HasSynced) {
+		h.Close()
+		return nil, fmt.Errorf("controller %s: harness informer did not sync", opts.Name)
+	}
+	return h, nil
+}
+
+// newObject 创建 T 的实例，作为 informer 的示例对象
+func newObject[T runtime.Object]() T {
+	var zero T
+	t := reflect.TypeOf(zero)
+	if t != nil && t.Kind() == reflect.Pointer {
+		return reflect.New(t.Elem()).Interface().(T)
+	}
+	return zero
+}
+
+// runReflector 从 Source List/Watch 到 h.fifo，watch 结束后立即重新 List
+func (h *Harness[T]) runReflector(name string, expectedType runtime.Object) {
+	lw := &cache.ListWatch{ListFunc: h.Source.List, WatchFunc: h.watch}
+	reflector := cache.NewNamedReflector(name, lw, expectedType, h.fifo, 0)
+	for h.ctx.Err() == nil {
+		if err := reflector.ListAndWatch(h.ctx.Done()); err != nil {
+			utilruntime.HandleError(err)
+		}
+	}
+}
+
+// process 把 h.fifo 中的变更交给 informer 更新缓存并通知 handler
+func (h *Harness[T]) process(handler deltaHandler) {
+	for {
+		if _, err := h.fifo.Pop(handler.HandleDeltas); err == cache.ErrFIFOClosed {
+			return
+		}
+	}
+}
+
+// Add 把对象加入 Source，等待控制器收到通知，缓存中已存在时与 informer 一样作为 Updated 事件
+func (h *Harness[T]) Add(obj T) error {
+	h.Source.Add(obj.DeepCopyObject())
+	return h.wait(1)
+}
+
+// Update 修改 Source 中的对象，等待控制器收到通知，缓存中不存在时与 informer 一样作为 Added 事件
+func (h *Harness[T]) Update(obj T) error {
+	h.Source.Modify(obj.DeepCopyObject())
+	return h.wait(1)
+}
+
+// Delete 从 Source 删除对象，等待控制器收到通知，缓存中不存在时 informer 不会通知
+func (h *Harness[T]) Delete(obj T) error {
+	_, exists, err := h.Controller.Indexer().Get(obj)
+	if err != nil {
+		return err
+	}
+	h.Source.Delete(obj.DeepCopyObject())
+	if !exists {
+		return nil
+	}
+	return h.wait(1)
+}
+
+// Relist 结束当前的 watch，reflector 立即重新 List 并用 Source 的内容替换缓存：
+// 缓存中多余的对象以 tombstone 通知删除，其余对象以 Replaced 通知，作为 Updated 事件交给控制器
+func (h *Harness[T]) Relist() error {
+	list, err := h.Source.List(metaV1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		return err
+	}
+	keys := sets.NewString(h.Controller.Indexer().ListKeys()...)
+	for _, item := range items {
+		key, err := cache.MetaNamespaceKeyFunc(item)
+		if err != nil {
+			return err
+		}
+		keys.Insert(key)
+	}
+	h.lock.Lock()
+	close(h.expire)
+	h.expire = make(chan struct{})
+	h.lock.Unlock()
+	return h.wait(keys.Len())
+}
+
+// watch 转发 Source 的 watch 事件，Relist 时发送 410 Expired 结束 watch
+func (h *Harness[T]) watch(options metaV1.ListOptions) (watch.Interface, error) {
+	w, err := h.Source.Watch(options)
+	if err != nil {
+		return nil, err
+	}
+	h.lock.Lock()
+	expire := h.expire
+	h.lock.Unlock()
+	events := make(chan watch.Event)
+	proxy := watch.NewProxyWatcher(events)
+	go func() {
+		defer w.Stop()
+		for {
+			select {
+			case event, ok := <-w.ResultChan():
+				if !ok {
+					close(events)
+					return
+				}
+				select {
+				case events <- event:
+				case <-proxy.StopChan():
+					return
+				}
+			case <-expire:
+				status := apiErrors.NewResourceExpired("harness relist").ErrStatus
+				select {
+				case events <- watch.Event{Type: watch.Error, Object: &status}:
+				case <-proxy.StopChan():
+				}
+				return
+			case <-proxy.StopChan():
+				return
+			}
+		}
+	}()
+	return proxy, nil
+}
+
+// notified 控制器处理完一个 informer 通知，作为 Options.Notified
+func (h *Harness[T]) notified() {
+	h.lock.Lock()
+	h.observed++
+	h.lock.Unlock()
+	select {
+	case h.notify <- struct{}{}:
+	default:
+	}
+}
+
+// wait 等待控制器再收到 n 个通知
+func (h *Harness[T]) wait(n int) error {
+	h.lock.Lock()
+	h.expected += n
+	expected := h.expected
+	h.lock.Unlock()
+	timeout := time.NewTimer(DefaultTimeout)
+	defer timeout.Stop()
+	for {
+		h.lock.Lock()
+		observed := h.observed
+		h.lock.Unlock()
+		if observed >= expected {
+			return nil
+		}
+		select {
+		case <-h.notify:
+		case <-timeout.C:
+			return fmt.Errorf("controller %s: harness timed out waiting for informer, %d of %d notifications", h.Controller.Name(), observed, expected)
+		}
+	}
+}
+
+// Step 处理队列中的一个 key，队列为空时返回 false。等待重试的 key 需要先通过 Advance 到期
+func (h *Harness[T]) Step() bool {
+	if h.queue.Len() == 0 {
+		return false
+	}
+	return h.Controller.ProcessNextItem(h.ctx)
+}
+
+// RunUntilIdle 处理队列直到为空，返回处理的 key 数量
+func (h *Harness[T]) RunUntilIdle() int {
+	n := 0
+	for h.Step() {
+		n++
+	}
+	return n
+}
+
+// Advance 推进假时钟，到期的重试加入队列
+func (h *Harness[T]) Advance(d time.Duration) {
+	h.Clock.Step(d)
+	h.queue.flush()
+}
+
+// Settle 处理队列，并把时钟依次推进到下一次重试，直到没有等待重试的 key。
+// 返回处理的 key 数量，RetryPolicy 永远重试时不会返回
+func (h *Harness[T]) Settle() int {
+	n := h.RunUntilIdle()
+	for {
+		next, ok := h.queue.next()
+		if !ok {
+			return n
+		}
+		h.Advance(next.Sub(h.Clock.Now()))
+		n += h.RunUntilIdle()
+	}
+}
+
+// Calls 返回所有 Reconcile 调用
+func (h *Harness[T]) Calls() []ReconcileCall[T] {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return append([]ReconcileCall[T](nil), h.calls...)
+}
+
+// Retries 返回 key 的重试次数
+func (h *Harness[T]) Retries(key string) int {
+	return h.retry.NumRequeues(key)
+}
+
+// Waiting 返回等待重试的 key 及其到期时间
+func (h *Harness[T]) Waiting() map[string]time.Time {
+	return h.queue.waitingKeys()
+}
+
+// Close 停止 informer 并关闭工作队列
+func (h *Harness[T]) Close() {
+	h.cancel()
+	if h.fifo != nil {
+		h.fifo.Close()
+	}
+	h.queue.ShutDown()
+}
+
+// fakeDelayingQueue 由假时钟控制的延迟队列，到期的 item 只在 flush 时加入队列，不启动后台协程
+type fakeDelayingQueue struct {
+	workqueue.Interface
+	clock *clocktesting.FakeClock
+
+	lock    sync.Mutex
+	waiting map[interface{}]time.Time
+}
+
+func newFakeDelayingQueue(name string, clock *clocktesting.FakeClock) *fakeDelayingQueue {
+	return &fakeDelayingQueue{
+		Interface: workqueue.NewNamed(name),
+		clock:     clock,
+		waiting:   map[interface{}]time.Time{},
+	}
+}
+
+func (q *fakeDelayingQueue) AddAfter(item interface{}, duration time.Duration) {
+	if q.ShuttingDown() {
+		return
+	}
+	if duration <= 0 {
+		q.Add(item)
+		return
+	}
+	readyAt := q.clock.Now().Add(duration)
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	// 与 client-go 的延迟队列一致，同一个 item 只保留最早的时间
+	if existing, ok := q.waiting[item]; ok && !readyAt.Before(existing) {
+		return
+	}
+	q.waiting[item] = readyAt
+}
+
+// flush 把到期的 item 按到期时间加入队列，时间相同时按 item 排序以保证结果确定
+func (q *fakeDelayingQueue) flush() {
+	now := q.clock.Now()
+	q.lock.Lock()
+	var ready []interface{}
+	for item, readyAt := range q.waiting {
+		if !readyAt.After(now) {
+			ready = append(ready, item)
+		}
+	}
+	sort.Slice(ready, func(i, j int) bool {
+		ti, tj := q.waiting[ready[i]], q.waiting[ready[j]]
+		if ti.Equal(tj) {
+			return fmt.Sprint(ready[i]) < fmt.Sprint(ready[j])
+		}
+		return ti.Before(tj)
+	})
+	for _, item := range ready {
+		delete(q.waiting, item)
+	}
+	q.lock.Unlock()
+	for _, item := range ready {
+		q.Add(item)
+	}
+}
+
+// next 返回最早的到期时间
+func (q *fakeDelayingQueue) next() (time.Time, bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	var earliest time.Time
+	for _, readyAt := range q.waiting {
+		if earliest.IsZero() || readyAt.Before(earliest) {
+			earliest = readyAt
+		}
+	}
+	return earliest, !earliest.IsZero()
+}
+
+func (q *fakeDelayingQueue) waitingKeys() map[string]time.Time {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	keys := make(map[string]time.Time, len(q.waiting))
+	for item, readyAt := range q.waiting {
+		keys[fmt.Sprint(item)] = readyAt
+	}
+	return keys
+}
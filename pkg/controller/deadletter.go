@@ -0,0 +1,68 @@
+package controller
+
+import (
+	"k8s.io/apimachinery/pkg/runtime"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DeadLetter 放弃重试的事件
+type DeadLetter[T runtime.Object] struct {
+	Event     Event[T]
+	LastError error
+	// Attempts 处理的总次数，包括首次处理
+	Attempts  int
+	DroppedAt time.Time
+}
+
+// DeadLetterStore 保存放弃重试的事件，同一个 key 只保留最后一次
+type DeadLetterStore[T runtime.Object] interface {
+	Put(letter DeadLetter[T])
+	Get(key string) (DeadLetter[T], bool)
+	// List 按 key 排序返回所有死信
+	List() []DeadLetter[T]
+	Delete(key string)
+}
+
+type memoryDeadLetters[T runtime.Object] struct {
+	lock    sync.RWMutex
+	letters map[string]DeadLetter[T]
+}
+
+// NewDeadLetterStore 创建内存中的死信存储
+func NewDeadLetterStore[T runtime.Object]() DeadLetterStore[T] {
+	return &memoryDeadLetters[T]{letters: map[string]DeadLetter[T]{}}
+}
+
+func (s *memoryDeadLetters[T]) Put(letter DeadLetter[T]) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.letters[letter.Event.Key] = letter
+}
+
+func (s *memoryDeadLetters[T]) Get(key string) (DeadLetter[T], bool) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	letter, ok := s.letters[key]
+	return letter, ok
+}
+
+func (s *memoryDeadLetters[T]) List() []DeadLetter[T] {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	letters := make([]DeadLetter[T], 0, len(s.letters))
+	for _, letter := range s.letters {
+		letters = append(letters, letter)
+	}
+	sort.Slice(letters, func(i, j int) bool {
+		return letters[i].Event.Key < letters[j].Event.Key
+	})
+	return letters
+}
+
+func (s *memoryDeadLetters[T]) Delete(key string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.letters, key)
+}
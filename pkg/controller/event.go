@@ -0,0 +1,96 @@
+package controller
+
+import (
+	"k8s-dev/pkg/diff"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/cache"
+	"sync"
+)
+
+// Event 一个待处理的对象变更，同一对象在被处理前的多次变更会合并为一个 Event
+type Event[T runtime.Object] struct {
+	// Type 变更类型：cache.Added、cache.Updated、cache.Deleted，手动入队时为 cache.Sync
+	Type cache.DeltaType
+	// Key 对象的 namespace/name，使用 Options.Clusters 时为 cluster/namespace/name
+	Key string
+	// Cluster 对象所在的集群，只在使用 Options.Clusters 时设置
+	Cluster string
+	// Old 本次合并的变更发生之前的对象，Added 时为空
+	Old T
+	// Object 最新的对象，删除时为最后已知的状态
+	Object T
+	// Tombstone 删除事件来自 cache.DeletedFinalStateUnknown，Object 可能不是对象的最终状态
+	Tombstone bool
+	// Diff Updated 事件中 Old 和 Object 的字段差异，只在开启 Options.Diff 时计算
+	Diff []diff.Change
+}
+
+// merge 把 next 合并到尚未处理的 e 上
+func (e *Event[T]) merge(next *Event[T]) {
+	// 重试时保留的差异已经过期，处理前重新计算
+	e.Diff = nil
+	switch {
+	case next.Type == cache.Deleted:
+		// 删除覆盖之前的所有变更，但保留最初的 Old，便于处理方对比
+		e.Type, e.Object, e.Tombstone = cache.Deleted, next.Object, next.Tombstone
+	case e.Type == cache.Deleted:
+		// 删除后又以同名重建，Old 为被删除的对象
+		e.Type, e.Old, e.Object, e.Tombstone = cache.Added, e.Object, next.Object, false
+	case e.Type == cache.Added:
+		// 新增后的更新仍然是新增
+		e.Object = next.Object
+	default:
+		e.Type, e.Object = next.Type, next.Object
+		if e.Type == cache.Sync {
+			e.Type = cache.Updated
+		}
+	}
+}
+
+// pendingEvents 按 key 保存尚未处理的事件，工作队列中只保存 key，从而实现按对象去重
+type pendingEvents[T runtime.Object] struct {
+	lock   sync.Mutex
+	events map[string]*Event[T]
+}
+
+func newPendingEvents[T runtime.Object]() *pendingEvents[T] {
+	return &pendingEvents[T]{events: map[string]*Event[T]{}}
+}
+
+// add 保存事件，已存在同一对象的事件时合并
+func (p *pendingEvents[T]) add(event *Event[T]) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if existing, ok := p.events[event.Key]; ok {
+		existing.merge(event)
+		return
+	}
+	p.events[event.Key] = event
+}
+
+// restore 把处理失败的事件放回，期间到达的新事件合并在其之后
+func (p *pendingEvents[T]) restore(event *Event[T]) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if newer, ok := p.events[event.Key]; ok {
+		event.merge(newer)
+	}
+	p.events[event.Key] = event
+}
+
+// take 取出并删除 key 对应的事件
+func (p *pendingEvents[T]) take(key string) (*Event[T], bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	event, ok := p.events[key]
+	delete(p.events, key)
+	return event, ok
+}
+
+// has 是否存在 key 对应的事件
+func (p *pendingEvents[T]) has(key string) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	_, ok := p.events[key]
+	return ok
+}
@@ -0,0 +1,175 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"k8s.io/klog/v2"
+	"os"
+	"sync"
+	"time"
+)
+
+// 选举参数的默认值，与 kube-controller-manager 一致
+const (
+	DefaultLeaseDuration = 15 * time.Second
+	DefaultRenewDeadline = 10 * time.Second
+	DefaultRetryPeriod   = 2 * time.Second
+)
+
+// ErrLeaderElectionLost 控制器失去了 leader 身份
+var ErrLeaderElectionLost = errors.New("leader election lost")
+
+// LeaderElection 基于 coordination.k8s.io Lease 的选主配置。
+// 未当选时控制器处于备用状态：informer 照常运行并保持缓存同步，但不处理事件。
+type LeaderElection struct {
+	// Client 用于读写 Lease
+	Client kubernetes.Interface
+	// Namespace Lease 所在的命名空间，默认 default
+	Namespace string
+	// Name Lease 名称，同一组控制器使用相同的名称
+	Name string
+	// Identity 当前实例的标识，默认 hostname_uuid
+	Identity string
+	// LeaseDuration 非 leader 等待多久后可以抢占 Lease，默认 DefaultLeaseDuration
+	LeaseDuration time.Duration
+	// RenewDeadline leader 续约失败多久后放弃 leader 身份，默认 DefaultRenewDeadline
+	RenewDeadline time.Duration
+	// RetryPeriod 获取或续约 Lease 的间隔，默认 DefaultRetryPeriod
+	RetryPeriod time.Duration
+	// ReleaseOnCancel 停止时主动释放 Lease，其他实例无需等待 LeaseDuration 即可接管。
+	// 释放时正在处理的事件可能尚未完成
+	ReleaseOnCancel bool
+	// OnStartedLeading 当选 leader 后调用，ctx 在失去 leader 身份时取消
+	OnStartedLeading func(ctx context.Context)
+	// OnStoppedLeading 失去 leader 身份或停止后调用，只有当选过才会调用
+	OnStoppedLeading func()
+	// OnNewLeader 观察到新的 leader 时调用，包括自己
+	OnNewLeader func(identity string)
+}
+
+// complete 校验配置并填充默认值
+func (le *LeaderElection) complete() error {
+	if le.Client == nil {
+		return errors.New("leader election: Client is required")
+	}
+	if le.Name == "" {
+		return errors.New("leader election: Name is required")
+	}
+	if le.Namespace == "" {
+		le.Namespace = metaV1.NamespaceDefault
+	}
+	if le.Identity == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return fmt.Errorf("leader election: %w", err)
+		}
+		le.Identity = hostname + "_" + string(uuid.NewUUID())
+	}
+	if le.LeaseDuration <= 0 {
+		le.LeaseDuration = DefaultLeaseDuration
+	}
+	if le.RenewDeadline <= 0 {
+		le.RenewDeadline = DefaultRenewDeadline
+	}
+	if le.RetryPeriod <= 0 {
+		le.RetryPeriod = DefaultRetryPeriod
+	}
+	return nil
+}
+
+// IsLeader 当前实例是否正在处理事件，未开启选主时始终为 true
+func (c *Controller[T]) IsLeader() bool {
+	return c.election == nil || c.leading.Load()
+}
+
+// lead
+//
+//	@Description: 参与选主，当选后处理事件直到失去 leader 身份或 ctx 取消
+//	@receiver c
+//	@param ctx
+//	@return error: 失去 leader 身份时返回 ErrLeaderElectionLost
+func (c *Controller[T]) lead(ctx context.Context) error {
+	le := c.election
+	var (
+		lock       sync.Mutex
+		running    bool
+		stopped    bool
+		processErr error
+	)
+	processed := make(chan struct{})
+
+	elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock: &resourcelock.LeaseLock{
+			LeaseMeta:  metaV1.ObjectMeta{Namespace: le.Namespace, Name: le.Name},
+			Client:     le.Client.CoordinationV1(),
+			LockConfig: resourcelock.ResourceLockConfig{Identity: le.Identity},
+		},
+		LeaseDuration:   le.LeaseDuration,
+		RenewDeadline:   le.RenewDeadline,
+		RetryPeriod:     le.RetryPeriod,
+		ReleaseOnCancel: le.ReleaseOnCancel,
+		Name:            c.name,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(leaderCtx context.Context) {
+				lock.Lock()
+				if stopped {
+					lock.Unlock()
+					return
+				}
+				running = true
+				lock.Unlock()
+				defer close(processed)
+
+				klog.InfoS("Became leader", "controller", c.name, "identity", le.Identity)
+				c.leading.Store(true)
+				if le.OnStartedLeading != nil {
+					le.OnStartedLeading(leaderCtx)
+				}
+				processErr = c.process(leaderCtx)
+			},
+			OnStoppedLeading: func() {
+				if !c.leading.Swap(false) {
+					return
+				}
+				klog.InfoS("Stopped leading", "controller", c.name, "identity", le.Identity)
+				if le.OnStoppedLeading != nil {
+					le.OnStoppedLeading()
+				}
+			},
+			OnNewLeader: func(identity string) {
+				klog.V(2).InfoS("New leader elected", "controller", c.name, "leader", identity)
+				if le.OnNewLeader != nil {
+					le.OnNewLeader(identity)
+				}
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("controller %s: %w", c.name, err)
+	}
+
+	klog.InfoS("Waiting for leadership", "controller", c.name, "lease", le.Namespace+"/"+le.Name, "identity", le.Identity)
+	elector.Run(ctx)
+
+	// 等待 worker 退出，processErr 在此之后才可以读取
+	lock.Lock()
+	stopped = true
+	wasRunning := running
+	lock.Unlock()
+	if wasRunning {
+		<-processed
+	}
+	if processErr != nil {
+		return processErr
+	}
+	if ctx.Err() == nil {
+		return fmt.Errorf("controller %s: %w", c.name, ErrLeaderElectionLost)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package controller
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"time"
+)
+
+// 导入 controller-runtime 的 metrics 包时会注册 workqueue 的指标：
+// workqueue_depth、workqueue_adds_total、workqueue_retries_total、workqueue_work_duration_seconds、
+// workqueue_unfinished_work_seconds 等，按队列名称（即控制器名称）区分
+
+// reconcile 结果
+const (
+	resultSuccess = "success"
+	resultError   = "error"
+)
+
+// List 的方式
+const (
+	listRelist = "relist"
+	listResume = "resume"
+)
+
+var (
+	// reconcileTotal 每个控制器 Reconcile 的次数，按结果区分
+	reconcileTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "controller_reconcile_total",
+		Help: "Total number of reconciliations per controller",
+	}, []string{"controller", "result"})
+
+	// reconcileDuration 每个控制器 Reconcile 的耗时
+	reconcileDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "controller_reconcile_duration_seconds",
+		Help:    "Length of time per reconciliation per controller",
+		Buckets: prometheus.ExponentialBuckets(0.001, 2, 15),
+	}, []string{"controller"})
+
+	// deadLettersTotal 每个控制器放弃重试的次数
+	deadLettersTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "controller_dead_letters_total",
+		Help: "Total number of items dropped out of the queue per controller",
+	}, []string{"controller"})
+
+	// watchListsTotal 每个控制器 informer List 的次数，relist 发送给 ApiServer，resume 从已知的 resourceVersion 继续
+	watchListsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "controller_watch_lists_total",
+		Help: "Total number of informer lists per controller, by whether the list was sent to the ApiServer (relist) or resumed from the last known resourceVersion (resume)",
+	}, []string{"controller", "mode"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(reconcileTotal, reconcileDuration, deadLettersTotal, watchListsTotal)
+}
+
+// observeReconcile 记录一次 Reconcile 的结果和耗时
+func observeReconcile(controller string, start time.Time, err error) {
+	result := resultSuccess
+	if err != nil {
+		result = resultError
+	}
+	reconcileTotal.WithLabelValues(controller, result).Inc()
+	reconcileDuration.WithLabelValues(controller).Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,382 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+	"sort"
+	"sync"
+	"time"
+)
+
+// NamespaceSelector 按标签动态选择命名空间，每个选中的命名空间运行一个 informer，
+// 命名空间加上或去掉标签时启动或停止对应的 informer，所有 informer 共用控制器的工作队列
+type NamespaceSelector struct {
+	// Client 用于 watch 命名空间
+	Client kubernetes.Interface
+	// Selector 命名空间的标签选择器，为空时选择所有命名空间
+	Selector labels.Selector
+	// ListWatch 返回命名空间中资源的 ListWatch，例如
+	// cache.NewListWatchFromClient(client.CoreV1().RESTClient(), "pods", namespace, fields.Everything())
+	ListWatch func(namespace string) cache.ListerWatcher
+}
+
+// namespaceInformer 一个命名空间的 informer
+type namespaceInformer struct {
+	informer cache.SharedIndexInformer
+	watch    *resumingListWatch
+	stop     chan struct{}
+}
+
+// namespaceInformers 管理按命名空间启动的 informer，Indexer 合并所有命名空间的缓存
+type namespaceInformers struct {
+	name       string
+	selector   NamespaceSelector
+	objType    runtime.Object
+	resync     time.Duration
+	indexers   cache.Indexers
+	handler    cache.ResourceEventHandler
+	namespaces cache.SharedIndexInformer
+
+	lock      sync.RWMutex
+	running   bool
+	synced    bool
+	informers map[string]*namespaceInformer
+}
+
+func newNamespaceInformers(name string, selector NamespaceSelector, objType runtime.Object, resync time.Duration, indexers cache.Indexers, handler cache.ResourceEventHandler) (*namespaceInformers, error) {
+	if selector.Client == nil {
+		return nil, errors.New("Namespaces.Client is required")
+	}
+	if selector.ListWatch == nil {
+		return nil, errors.New("Namespaces.ListWatch is required")
+	}
+	if selector.Selector == nil {
+		selector.Selector = labels.Everything()
+	}
+	n := &namespaceInformers{
+		name:      name,
+		selector:  selector,
+		objType:   objType,
+		resync:    resync,
+		indexers:  indexers,
+		handler:   handler,
+		informers: map[string]*namespaceInformer{},
+	}
+	namespaces := selector.Client.CoreV1().Namespaces()
+	n.namespaces = cache.NewSharedIndexInformer(&cache.ListWatch{
+		ListFunc: func(options metaV1.ListOptions) (runtime.Object, error) {
+			return namespaces.List(context.Background(), options)
+		},
+		WatchFunc: func(options metaV1.ListOptions) (watch.Interface, error) {
+			return namespaces.Watch(context.Background(), options)
+		},
+	}, &coreV1.Namespace{}, 0, cache.Indexers{})
+	// 在本地按标签过滤，命名空间去掉标签时才能收到更新事件
+	_, err := n.namespaces.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(interface{}) { n.sync() },
+		UpdateFunc: func(interface{}, interface{}) { n.sync() },
+		DeleteFunc: func(interface{}) { n.sync() },
+	})
+	if err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// run 运行命名空间 informer，ctx 取消时停止所有 informer
+func (n *namespaceInformers) run(ctx context.Context) {
+	go n.namespaces.Run(ctx.Done())
+	if cache.WaitForCacheSync(ctx.Done(), n.namespaces.HasSynced) {
+		n.lock.Lock()
+		n.running = true
+		n.lock.Unlock()
+		n.sync()
+	}
+	<-ctx.Done()
+
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	n.running = false
+	for namespace := range n.informers {
+		n.stopLocked(namespace)
+	}
+}
+
+// sync 使运行的 informer 与选中的命名空间一致
+func (n *namespaceInformers) sync() {
+	selected := sets.NewString()
+	for _, obj := range n.namespaces.GetStore().List() {
+		ns, ok := obj.(*coreV1.Namespace)
+		if ok && ns.DeletionTimestamp == nil && n.selector.Selector.Matches(labels.Set(ns.Labels)) {
+			selected.Insert(ns.Name)
+		}
+	}
+
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	if !n.running {
+		return
+	}
+	for namespace := range n.informers {
+		if !selected.Has(namespace) {
+			n.stopLocked(namespace)
+		}
+	}
+	for _, namespace := range selected.List() {
+		if _, ok := n.informers[namespace]; !ok {
+			n.startLocked(namespace)
+		}
+	}
+	n.synced = true
+}
+
+func (n *namespaceInformers) startLocked(namespace string) {
+	lw := newResumingListWatch(n.name, n.selector.ListWatch(namespace))
+	informer := cache.NewSharedIndexInformer(lw, n.objType, n.resync, n.indexers)
+	if _, err := informer.AddEventHandler(n.handler); err != nil {
+		klog.ErrorS(err, "Failed to add event handler", "controller", n.name, "namespace", namespace)
+		return
+	}
+	ni := &namespaceInformer{informer: informer, watch: lw, stop: make(chan struct{})}
+	n.informers[namespace] = ni
+	go informer.Run(ni.stop)
+	klog.InfoS("Started namespace informer", "controller", n.name, "namespace", namespace)
+}
+
+// stopLocked 停止命名空间的 informer，缓存随之丢弃，不会产生删除事件
+func (n *namespaceInformers) stopLocked(namespace string) {
+	ni, ok := n.informers[namespace]
+	if !ok {
+		return
+	}
+	close(ni.stop)
+	delete(n.informers, namespace)
+	klog.InfoS("Stopped namespace informer", "controller", n.name, "namespace", namespace)
+}
+
+// names 返回正在 watch 的命名空间
+func (n *namespaceInformers) names() []string {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+	namespaces := make([]string, 0, len(n.informers))
+	for namespace := range n.informers {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+	return namespaces
+}
+
+// watching 是否正在 watch namespace
+func (n *namespaceInformers) watching(namespace string) bool {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+	_, ok := n.informers[namespace]
+	return ok
+}
+
+// HasSynced 命名空间列表和所有命名空间的 informer 都已同步
+func (n *namespaceInformers) HasSynced() bool {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+	if !n.synced {
+		return false
+	}
+	for _, ni := range n.informers {
+		if !ni.informer.HasSynced() {
+			return false
+		}
+	}
+	return true
+}
+
+func (n *namespaceInformers) watchStats() WatchStats {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+	var total WatchStats
+	for _, ni := range n.informers {
+		stats := ni.watch.watchStats()
+		total.Relists += stats.Relists
+		total.Resumes += stats.Resumes
+		total.Bookmarks += stats.Bookmarks
+		total.Expired += stats.Expired
+	}
+	return total
+}
+
+// caches 返回按命名空间排序的缓存
+func (n *namespaceInformers) caches() []cache.Indexer {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+	namespaces := make([]string, 0, len(n.informers))
+	for namespace := range n.informers {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+	indexers := make([]cache.Indexer, 0, len(namespaces))
+	for _, namespace := range namespaces {
+		indexers = append(indexers, n.informers[namespace].informer.GetIndexer())
+	}
+	return indexers
+}
+
+// indexer 返回 namespace 的缓存
+func (n *namespaceInformers) indexer(namespace string) (cache.Indexer, bool) {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+	ni, ok := n.informers[namespace]
+	if !ok {
+		return nil, false
+	}
+	return ni.informer.GetIndexer(), true
+}
+
+// mergedIndexer 合并所有命名空间缓存的 cache.Indexer，按 key 中的命名空间路由。
+// 写入只能写到正在 watch 的命名空间，不支持 Replace、Resync 和 AddIndexers
+type mergedIndexer struct {
+	n *namespaceInformers
+}
+
+var errMergedIndexer = errors.New("not supported by the merged namespace indexer")
+
+func (m mergedIndexer) route(obj interface{}) (cache.Indexer, error) {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return nil, err
+	}
+	indexer, ok := m.n.indexer(accessor.GetNamespace())
+	if !ok {
+		return nil, fmt.Errorf("namespace %q is not watched", accessor.GetNamespace())
+	}
+	return indexer, nil
+}
+
+func (m mergedIndexer) Add(obj interface{}) error {
+	indexer, err := m.route(obj)
+	if err != nil {
+		return err
+	}
+	return indexer.Add(obj)
+}
+
+func (m mergedIndexer) Update(obj interface{}) error {
+	indexer, err := m.route(obj)
+	if err != nil {
+		return err
+	}
+	return indexer.Update(obj)
+}
+
+func (m mergedIndexer) Delete(obj interface{}) error {
+	indexer, err := m.route(obj)
+	if err != nil {
+		return err
+	}
+	return indexer.Delete(obj)
+}
+
+func (m mergedIndexer) List() []interface{} {
+	var items []interface{}
+	for _, indexer := range m.n.caches() {
+		items = append(items, indexer.List()...)
+	}
+	return items
+}
+
+func (m mergedIndexer) ListKeys() []string {
+	var keys []string
+	for _, indexer := range m.n.caches() {
+		keys = append(keys, indexer.ListKeys()...)
+	}
+	return keys
+}
+
+func (m mergedIndexer) Get(obj interface{}) (item interface{}, exists bool, err error) {
+	key, err := cache.MetaNamespaceKeyFunc(obj)
+	if err != nil {
+		return nil, false, err
+	}
+	return m.GetByKey(key)
+}
+
+func (m mergedIndexer) GetByKey(key string) (item interface{}, exists bool, err error) {
+	namespace, _, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return nil, false, err
+	}
+	indexer, ok := m.n.indexer(namespace)
+	if !ok {
+		return nil, false, nil
+	}
+	return indexer.GetByKey(key)
+}
+
+func (m mergedIndexer) Replace([]interface{}, string) error {
+	return errMergedIndexer
+}
+
+func (m mergedIndexer) Resync() error {
+	return errMergedIndexer
+}
+
+func (m mergedIndexer) Index(indexName string, obj interface{}) ([]interface{}, error) {
+	var items []interface{}
+	for _, indexer := range m.n.caches() {
+		matched, err := indexer.Index(indexName, obj)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, matched...)
+	}
+	return items, nil
+}
+
+func (m mergedIndexer) IndexKeys(indexName, indexedValue string) ([]string, error) {
+	var keys []string
+	for _, indexer := range m.n.caches() {
+		matched, err := indexer.IndexKeys(indexName, indexedValue)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, matched...)
+	}
+	return keys, nil
+}
+
+func (m mergedIndexer) ListIndexFuncValues(indexName string) []string {
+	values := sets.NewString()
+	for _, indexer := range m.n.caches() {
+		values.Insert(indexer.ListIndexFuncValues(indexName)...)
+	}
+	return values.List()
+}
+
+func (m mergedIndexer) ByIndex(indexName, indexedValue string) ([]interface{}, error) {
+	var items []interface{}
+	for _, indexer := range m.n.caches() {
+		matched, err := indexer.ByIndex(indexName, indexedValue)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, matched...)
+	}
+	return items, nil
+}
+
+func (m mergedIndexer) GetIndexers() cache.Indexers {
+	return m.n.indexers
+}
+
+func (m mergedIndexer) AddIndexers(cache.Indexers) error {
+	return errMergedIndexer
+}
@@ -0,0 +1,195 @@
+package controller
+
+import (
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/cache"
+	"strings"
+)
+
+// Predicate 在 informer 事件加入队列前过滤，返回 false 时丢弃事件。
+// event.Old 只在 Updated 事件中不为空；删除事件的 Object 可能来自 tombstone。
+// 自定义条件直接实现该函数即可
+type Predicate func(event Event[runtime.Object]) bool
+
+// toUntyped 把事件转换为 Predicate 使用的 Event[runtime.Object]，nil 指针转换为 nil 接口
+func toUntyped[T runtime.Object](event *Event[T]) Event[runtime.Object] {
+	untyped := Event[runtime.Object]{Type: event.Type, Key: event.Key, Cluster: event.Cluster, Tombstone: event.Tombstone}
+	if !isNil(event.Old) {
+		untyped.Old = event.Old
+	}
+	if !isNil(event.Object) {
+		untyped.Object = event.Object
+	}
+	return untyped
+}
+
+// allow 所有 predicates 都通过时返回 true
+func allow(predicates []Predicate, event Event[runtime.Object]) bool {
+	for _, p := range predicates {
+		if !p(event) {
+			return false
+		}
+	}
+	return true
+}
+
+// accessor 返回对象的元数据，对象为空或不是 k8s 对象时返回 nil
+func accessor(obj runtime.Object) metaV1.Object {
+	if obj == nil {
+		return nil
+	}
+	m, err := meta.Accessor(obj)
+	if err != nil {
+		return nil
+	}
+	return m
+}
+
+// namespaceOf 从 key 中解析命名空间，tombstone 中没有对象时也可以使用
+func namespaceOf(event Event[runtime.Object]) string {
+	key := event.Key
+	if event.Cluster != "" {
+		key = strings.TrimPrefix(key, event.Cluster+"/")
+	}
+	ns, _, _ := cache.SplitMetaNamespaceKey(key)
+	return ns
+}
+
+// InNamespaces 只保留 namespaces 中的对象
+func InNamespaces(namespaces ...string) Predicate {
+	set := toSet(namespaces)
+	return func(event Event[runtime.Object]) bool {
+		return set[namespaceOf(event)]
+	}
+}
+
+// ExcludeNamespaces 丢弃 namespaces 中的对象
+func ExcludeNamespaces(namespaces ...string) Predicate {
+	set := toSet(namespaces)
+	return func(event Event[runtime.Object]) bool {
+		return !set[namespaceOf(event)]
+	}
+}
+
+func toSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		set[v] = true
+	}
+	return set
+}
+
+// LabelSelector 只保留标签匹配 selector 的对象。
+// Updated 事件中新旧对象任意一个匹配即保留，这样标签被移除的变更也能被处理
+func LabelSelector(selector labels.Selector) Predicate {
+	matches := func(obj runtime.Object) bool {
+		m := accessor(obj)
+		return m != nil && selector.Matches(labels.Set(m.GetLabels()))
+	}
+	return func(event Event[runtime.Object]) bool {
+		return matches(event.Object) || matches(event.Old)
+	}
+}
+
+// HasAnnotation 只保留带有注解 key 的对象，Updated 事件中新旧对象任意一个带有即保留
+func HasAnnotation(key string) Predicate {
+	has := func(obj runtime.Object) bool {
+		m := accessor(obj)
+		if m == nil {
+			return false
+		}
+		_, ok := m.GetAnnotations()[key]
+		return ok
+	}
+	return func(event Event[runtime.Object]) bool {
+		return has(event.Object) || has(event.Old)
+	}
+}
+
+// updated 对 Updated 事件调用 changed，其他事件直接保留
+func updated(changed func(old, obj metaV1.Object) bool) Predicate {
+	return func(event Event[runtime.Object]) bool {
+		if event.Type != cache.Updated {
+			return true
+		}
+		old, obj := accessor(event.Old), accessor(event.Object)
+		if old == nil || obj == nil {
+			return true
+		}
+		return changed(old, obj)
+	}
+}
+
+// GenerationChanged 丢弃 metadata.generation 没有变化的 Updated 事件，即只处理 spec 的变更。
+// 注意 pod 等资源不维护 generation
+func GenerationChanged() Predicate {
+	return updated(func(old, obj metaV1.Object) bool {
+		return old.GetGeneration() != obj.GetGeneration()
+	})
+}
+
+// ResourceVersionChanged 丢弃 resourceVersion 没有变化的 Updated 事件，即 resync 产生的事件
+func ResourceVersionChanged() Predicate {
+	return updated(func(old, obj metaV1.Object) bool {
+		return old.GetResourceVersion() != obj.GetResourceVersion()
+	})
+}
+
+// SpecChanged 丢弃只有 metadata 或 status 变化的 Updated 事件。
+// 比较除 metadata 和 status 之外的所有顶层字段，对没有 spec 的资源（例如 ConfigMap 的 data）同样适用
+func SpecChanged() Predicate {
+	return func(event Event[runtime.Object]) bool {
+		if event.Type != cache.Updated || event.Old == nil || event.Object == nil {
+			return true
+		}
+		old, err := withoutMetadataAndStatus(event.Old)
+		if err != nil {
+			return true
+		}
+		obj, err := withoutMetadataAndStatus(event.Object)
+		if err != nil {
+			return true
+		}
+		return !equality.Semantic.DeepEqual(old, obj)
+	}
+}
+
+func withoutMetadataAndStatus(obj runtime.Object) (map[string]interface{}, error) {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+	delete(content, "metadata")
+	delete(content, "status")
+	return content, nil
+}
+
+// And 所有 predicates 都通过时保留
+func And(predicates ...Predicate) Predicate {
+	return func(event Event[runtime.Object]) bool {
+		return allow(predicates, event)
+	}
+}
+
+// Or 任意一个 predicate 通过时保留
+func Or(predicates ...Predicate) Predicate {
+	return func(event Event[runtime.Object]) bool {
+		for _, p := range predicates {
+			if p(event) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Not 取反
+func Not(predicate Predicate) Predicate {
+	return func(event Event[runtime.Object]) bool {
+		return !predicate(event)
+	}
+}
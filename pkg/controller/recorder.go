@@ -0,0 +1,118 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedCoreV1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+	"sync"
+)
+
+// EventRecorderOptions 创建 EventRecorder 的参数
+type EventRecorderOptions struct {
+	// Client 用于写入 Event
+	Client kubernetes.Interface
+	// Component 事件来源，即 kubectl describe 中的 From 列
+	Component string
+	// Correlator 事件聚合和限流参数，零值使用 client-go 的默认值：
+	// 相似事件 10 分钟内超过 10 条时聚合为一条，每个对象突发 25 条后每 5 分钟只允许 1 条
+	Correlator record.CorrelatorOptions
+}
+
+// NewEventRecorder
+//
+//	@Description: 创建写入 ApiServer 的 EventRecorder，重复的事件会被聚合和限流
+//	@param opts
+//	@return record.EventRecorder
+//	@return func(): 停止写入，程序退出前调用
+//	@return error
+func NewEventRecorder(opts EventRecorderOptions) (record.EventRecorder, func(), error) {
+	if opts.Client == nil {
+		return nil, nil, fmt.Errorf("event recorder: Client is required")
+	}
+	if opts.Component == "" {
+		return nil, nil, fmt.Errorf("event recorder: Component is required")
+	}
+	broadcaster := record.NewBroadcasterWithCorrelatorOptions(opts.Correlator)
+	broadcaster.StartStructuredLogging(4)
+	broadcaster.StartRecordingToSink(&typedCoreV1.EventSinkImpl{Interface: opts.Client.CoreV1().Events("")})
+	recorder := broadcaster.NewRecorder(scheme.Scheme, coreV1.EventSource{Component: opts.Component})
+	return recorder, broadcaster.Shutdown, nil
+}
+
+type recorderKey struct{}
+
+// withRecorder 把 recorder 放入传给 Reconciler 的 ctx
+func withRecorder(ctx context.Context, recorder record.EventRecorder) context.Context {
+	if recorder == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, recorderKey{}, recorder)
+}
+
+// RecorderFrom 返回控制器的 EventRecorder，用于在 Reconciler 中对正在处理的对象记录事件。
+// 控制器没有配置 EventRecorder 时返回的 recorder 会丢弃所有事件
+func RecorderFrom(ctx context.Context) record.EventRecorder {
+	if recorder, ok := ctx.Value(recorderKey{}).(record.EventRecorder); ok {
+		return recorder
+	}
+	return &record.FakeRecorder{}
+}
+
+// RecordedEvent FakeRecorder 记录的事件
+type RecordedEvent struct {
+	Object      runtime.Object
+	Type        string
+	Reason      string
+	Message     string
+	Annotations map[string]string
+}
+
+// FakeRecorder 测试使用的 EventRecorder，保存所有事件，不做聚合和限流
+type FakeRecorder struct {
+	lock   sync.Mutex
+	events []RecordedEvent
+}
+
+// NewFakeRecorder 创建 FakeRecorder
+func NewFakeRecorder() *FakeRecorder {
+	return &FakeRecorder{}
+}
+
+func (f *FakeRecorder) Event(object runtime.Object, eventtype, reason, message string) {
+	f.AnnotatedEventf(object, nil, eventtype, reason, "%s", message)
+}
+
+func (f *FakeRecorder) Eventf(object runtime.Object, eventtype, reason, messageFmt string, args ...interface{}) {
+	f.AnnotatedEventf(object, nil, eventtype, reason, messageFmt, args...)
+}
+
+func (f *FakeRecorder) AnnotatedEventf(object runtime.Object, annotations map[string]string, eventtype, reason, messageFmt string, args ...interface{}) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.events = append(f.events, RecordedEvent{
+		Object:      object,
+		Type:        eventtype,
+		Reason:      reason,
+		Message:     fmt.Sprintf(messageFmt, args...),
+		Annotations: annotations,
+	})
+}
+
+// Events 返回已记录的事件
+func (f *FakeRecorder) Events() []RecordedEvent {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return append([]RecordedEvent(nil), f.events...)
+}
+
+// Reset 清空已记录的事件
+func (f *FakeRecorder) Reset() {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.events = nil
+}
@@ -0,0 +1,189 @@
+package controller
+
+import (
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+	"sort"
+	"sync"
+)
+
+// WatchStats 控制器 List/Watch 的统计
+type WatchStats struct {
+	// ResourceVersion 最新的 resourceVersion，包括 bookmark 带来的
+	ResourceVersion string
+	// Relists 向 ApiServer 发起完整 List 的次数，包括启动时的第一次
+	Relists int64
+	// Resumes 不发起 List，从已知的 resourceVersion 继续 watch 的次数，包括从快照启动
+	Resumes int64
+	// Bookmarks 收到的 bookmark 数量
+	Bookmarks int64
+	// Expired resourceVersion 过期（410 Gone）的次数，过期后下一次 List 会发送给 ApiServer
+	Expired int64
+}
+
+// resumingListWatch 包装 lw，记录 List 和 watch 到的对象以及最新的 resourceVersion。
+// watch 出错后 reflector 会重新 List，此时直接返回记录的对象，reflector 从记录的 resourceVersion 继续 watch，
+// 只有 resourceVersion 过期时才向 ApiServer 发起完整的 List。
+// 记录的对象与 informer 缓存共用同一份，不会额外复制
+type resumingListWatch struct {
+	lw   cache.ListerWatcher
+	name string
+
+	lock sync.Mutex
+	// items 与 rv 对应的完整对象集合，valid 为 false 时不能用于恢复
+	items map[string]runtime.Object
+	rv    string
+	valid bool
+	// building 分页 List 过程中已收到的对象
+	building map[string]runtime.Object
+	stats    WatchStats
+}
+
+func newResumingListWatch(name string, lw cache.ListerWatcher) *resumingListWatch {
+	return &resumingListWatch{lw: lw, name: name, items: map[string]runtime.Object{}}
+}
+
+// seed 使用快照等已知状态作为恢复点
+func (r *resumingListWatch) seed(items []runtime.Object, rv string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.items = make(map[string]runtime.Object, len(items))
+	for _, item := range items {
+		if key, err := cache.MetaNamespaceKeyFunc(item); err == nil {
+			r.items[key] = item
+		}
+	}
+	r.rv, r.stats.ResourceVersion = rv, rv
+	r.valid = rv != ""
+}
+
+func (r *resumingListWatch) List(options metaV1.ListOptions) (runtime.Object, error) {
+	r.lock.Lock()
+	if options.Continue == "" && r.valid {
+		list := &metaV1.List{ListMeta: metaV1.ListMeta{ResourceVersion: r.rv}}
+		keys := make([]string, 0, len(r.items))
+		for key := range r.items {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			list.Items = append(list.Items, runtime.RawExtension{Object: r.items[key]})
+		}
+		r.stats.Resumes++
+		r.lock.Unlock()
+		watchListsTotal.WithLabelValues(r.name, listResume).Inc()
+		klog.V(2).InfoS("Resuming watch", "controller", r.name, "resourceVersion", list.ResourceVersion, "items", len(list.Items))
+		return list, nil
+	}
+	r.lock.Unlock()
+
+	obj, err := r.lw.List(options)
+	if err != nil {
+		if isExpired(err) {
+			r.expire()
+		}
+		return nil, err
+	}
+	items, err := meta.ExtractList(obj)
+	if err != nil {
+		return nil, err
+	}
+	listMeta, err := meta.ListAccessor(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	// 分页 List 只在第一页计数，最后一页收到后才成为恢复点
+	if options.Continue == "" || r.building == nil {
+		r.building = map[string]runtime.Object{}
+		r.stats.Relists++
+		watchListsTotal.WithLabelValues(r.name, listRelist).Inc()
+	}
+	for _, item := range items {
+		if key, err := cache.MetaNamespaceKeyFunc(item); err == nil {
+			r.building[key] = item
+		}
+	}
+	if listMeta.GetContinue() == "" {
+		r.items, r.building = r.building, nil
+		r.rv, r.stats.ResourceVersion = listMeta.GetResourceVersion(), listMeta.GetResourceVersion()
+		r.valid = true
+	}
+	return obj, nil
+}
+
+func (r *resumingListWatch) Watch(options metaV1.ListOptions) (watch.Interface, error) {
+	// 请求 bookmark，长时间没有变更时 resourceVersion 也能保持最新，恢复时不容易过期
+	options.AllowWatchBookmarks = true
+	w, err := r.lw.Watch(options)
+	if err != nil {
+		if isExpired(err) {
+			r.expire()
+		}
+		return nil, err
+	}
+	return watch.Filter(w, func(event watch.Event) (watch.Event, bool) {
+		r.observe(event)
+		return event, true
+	}), nil
+}
+
+// observe 根据 watch 事件更新记录的对象和 resourceVersion
+func (r *resumingListWatch) observe(event watch.Event) {
+	if event.Type == watch.Error {
+		if isExpired(apiErrors.FromObject(event.Object)) {
+			r.expire()
+		}
+		return
+	}
+	accessor, err := meta.Accessor(event.Object)
+	if err != nil {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	switch event.Type {
+	case watch.Added, watch.Modified:
+		if key, err := cache.MetaNamespaceKeyFunc(event.Object); err == nil {
+			r.items[key] = event.Object
+		}
+	case watch.Deleted:
+		if key, err := cache.MetaNamespaceKeyFunc(event.Object); err == nil {
+			delete(r.items, key)
+		}
+	case watch.Bookmark:
+		r.stats.Bookmarks++
+	}
+	if rv := accessor.GetResourceVersion(); rv != "" {
+		r.rv, r.stats.ResourceVersion = rv, rv
+	}
+}
+
+// expire resourceVersion 过期，下一次 List 需要发送给 ApiServer
+func (r *resumingListWatch) expire() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.valid {
+		r.stats.Expired++
+		klog.V(2).InfoS("Watch resourceVersion expired, relisting", "controller", r.name, "resourceVersion", r.rv)
+	}
+	r.valid = false
+}
+
+func (r *resumingListWatch) watchStats() WatchStats {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.stats
+}
+
+// isExpired 与 reflector 一样把 410 Expired 和 Gone 都视为 resourceVersion 过期
+func isExpired(err error) bool {
+	return apiErrors.IsResourceExpired(err) || apiErrors.IsGone(err)
+}
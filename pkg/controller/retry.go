@@ -0,0 +1,95 @@
+package controller
+
+import (
+	"errors"
+	"k8s.io/client-go/util/workqueue"
+	"time"
+)
+
+// RetryPolicy 处理失败时的重试策略。
+// 重试的等待时间和已重试次数由内嵌的 workqueue.RateLimiter 负责，ShouldRetry 决定是否继续重试。
+type RetryPolicy interface {
+	workqueue.RateLimiter
+	// ShouldRetry 返回 false 时放弃重试，事件进入死信存储
+	ShouldRetry(key string, err error) bool
+}
+
+// permanentError 标记为不需要重试的错误
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent 包装一个不需要重试的错误，任何重试策略都会直接放弃
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent 判断错误是否由 Permanent 包装
+func IsPermanent(err error) bool {
+	var permanent *permanentError
+	return errors.As(err, &permanent)
+}
+
+type maxRetries struct {
+	workqueue.RateLimiter
+	max int
+}
+
+func (p *maxRetries) ShouldRetry(key string, err error) bool {
+	return !IsPermanent(err) && p.NumRequeues(key) < p.max
+}
+
+// MaxRetries
+//
+//	@Description: 最多重试 max 次，重试间隔由 limiter 决定
+//	@param max
+//	@param limiter: 例如 ExponentialBackoff 或 workqueue.DefaultControllerRateLimiter()
+//	@return RetryPolicy
+func MaxRetries(max int, limiter workqueue.RateLimiter) RetryPolicy {
+	return &maxRetries{RateLimiter: limiter, max: max}
+}
+
+// ExponentialBackoff 按 key 指数退避：base*2^重试次数，最长 max
+func ExponentialBackoff(base, max time.Duration) workqueue.RateLimiter {
+	return workqueue.NewItemExponentialFailureRateLimiter(base, max)
+}
+
+// DefaultRetryPolicy 与 pod informer 示例一致：最多重试5次，使用 workqueue 默认的限速退避
+func DefaultRetryPolicy() RetryPolicy {
+	return MaxRetries(5, workqueue.DefaultControllerRateLimiter())
+}
+
+type noRetryOn struct {
+	RetryPolicy
+	classifiers []func(error) bool
+}
+
+func (p *noRetryOn) ShouldRetry(key string, err error) bool {
+	for _, classify := range p.classifiers {
+		if classify(err) {
+			return false
+		}
+	}
+	return p.RetryPolicy.ShouldRetry(key, err)
+}
+
+// NoRetryOn
+//
+//	@Description: 按错误类型决定是否重试，任意 classifier 返回 true 时不再重试，例如 NoRetryOn(policy, errors.IsNotFound)
+//	@param policy
+//	@param classifiers
+//	@return RetryPolicy
+func NoRetryOn(policy RetryPolicy, classifiers ...func(error) bool) RetryPolicy {
+	return &noRetryOn{RetryPolicy: policy, classifiers: classifiers}
+}
@@ -0,0 +1,85 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"k8s.io/klog/v2"
+	"net/http"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"strings"
+	"time"
+)
+
+// DefaultServerAddress 指标和健康检查服务的默认地址
+const DefaultServerAddress = ":8080"
+
+// Syncer 可以参与就绪检查的控制器
+type Syncer interface {
+	Name() string
+	HasSynced() bool
+}
+
+// Server 提供 /metrics、/healthz 和 /readyz，多个控制器可以共用一个 Server
+type Server struct {
+	// Addr 监听地址，默认 DefaultServerAddress
+	Addr        string
+	controllers []Syncer
+}
+
+// NewServer
+//
+//	@Description: 创建指标和健康检查服务，所有 controllers 的缓存同步后 /readyz 才返回 200
+//	@param addr
+//	@param controllers
+//	@return *Server
+func NewServer(addr string, controllers ...Syncer) *Server {
+	if addr == "" {
+		addr = DefaultServerAddress
+	}
+	return &Server{Addr: addr, controllers: controllers}
+}
+
+// Handler 返回服务的路由，便于嵌入已有的 HTTP 服务
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(metrics.Registry, promhttp.HandlerOpts{}))
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("/readyz", s.ready)
+	return mux
+}
+
+// ready 所有控制器的缓存同步后返回 200，否则返回 503 和未同步的控制器
+func (s *Server) ready(w http.ResponseWriter, r *http.Request) {
+	var pending []string
+	for _, c := range s.controllers {
+		if !c.HasSynced() {
+			pending = append(pending, c.Name())
+		}
+	}
+	if len(pending) > 0 {
+		http.Error(w, "caches not synced: "+strings.Join(pending, ", "), http.StatusServiceUnavailable)
+		return
+	}
+	_, _ = fmt.Fprintln(w, "ok")
+}
+
+// Run 启动服务直到 ctx 取消
+func (s *Server) Run(ctx context.Context) error {
+	server := &http.Server{Addr: s.Addr, Handler: s.Handler(), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	klog.InfoS("Serving metrics and health probes", "addr", s.Addr)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("metrics server: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,307 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	coordinationV1 "k8s.io/api/coordination/v1"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/clock"
+	"os"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ShardGroupLabel 分片成员 Lease 上的标签，值为分片组名称
+const ShardGroupLabel = "k8s-dev/shard-group"
+
+// 分片参数的默认值
+const (
+	DefaultShardLeaseDuration = 15 * time.Second
+	DefaultShardRenewInterval = 5 * time.Second
+)
+
+// ShardOptions 分片参数。每个副本维护一个带 ShardGroupLabel 的 Lease，
+// 未过期的 Lease 即为当前成员，key 按一致性哈希分配给成员。
+// 副本停止时删除自己的 Lease，崩溃的副本留下的 Lease 过期超过 LeaseDuration 后由其他成员删除
+type ShardOptions struct {
+	// Client 用于读写 Lease
+	Client kubernetes.Interface
+	// Namespace Lease 所在的命名空间，默认 default
+	Namespace string
+	// Group 分片组名称，同一组副本使用相同的名称
+	Group string
+	// Identity 当前副本的标识，同一组的副本必须不同。默认 hostname，在 Pod 中即 Pod 名称。
+	// Lease 名称由 Group 和 Identity 生成，使用稳定的标识时重启后沿用原来的 Lease
+	Identity string
+	// LeaseDuration 成员多久没有续约后被移除，默认 DefaultShardLeaseDuration
+	LeaseDuration time.Duration
+	// RenewInterval 续约和刷新成员列表的间隔，默认 DefaultShardRenewInterval
+	RenewInterval time.Duration
+	// HandoffDelay 成员变化后，新分到的 key 延迟多久才开始处理，让原来的副本有时间发现变化并完成正在处理的事件。
+	// 失去的 key 立即停止处理。默认 2*RenewInterval，应大于 RenewInterval 加上单次处理的最长耗时
+	HandoffDelay time.Duration
+	// VirtualNodes 每个成员的虚拟节点数，默认 DefaultVirtualNodes
+	VirtualNodes int
+	// OnChange 成员变化时调用
+	OnChange func(members []string)
+	// Clock 续约、判断过期和交接使用的时钟，默认真实时钟
+	Clock clock.WithTicker
+}
+
+// Sharder 维护分片成员和 key 的归属
+type Sharder struct {
+	opts      ShardOptions
+	leaseName string
+
+	lock sync.RWMutex
+	// active 完成交接的哈希环，pending 为最新的成员构成的哈希环，activateAt 之后成为 active
+	active     *Ring
+	pending    *Ring
+	activateAt time.Time
+	synced     bool
+	listeners  []func(previous, current *Ring)
+}
+
+// NewSharder
+//
+//	@Description: 校验参数并创建 Sharder，需要调用 Run 才会加入分片组
+//	@param opts
+//	@return *Sharder
+//	@return error
+func NewSharder(opts ShardOptions) (*Sharder, error) {
+	if opts.Client == nil {
+		return nil, errors.New("sharder: Client is required")
+	}
+	if opts.Group == "" {
+		return nil, errors.New("sharder: Group is required")
+	}
+	if opts.Namespace == "" {
+		opts.Namespace = metaV1.NamespaceDefault
+	}
+	if opts.Identity == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("sharder: %w", err)
+		}
+		opts.Identity = hostname
+	}
+	if opts.LeaseDuration <= 0 {
+		opts.LeaseDuration = DefaultShardLeaseDuration
+	}
+	if opts.RenewInterval <= 0 {
+		opts.RenewInterval = DefaultShardRenewInterval
+	}
+	if opts.HandoffDelay <= 0 {
+		opts.HandoffDelay = 2 * opts.RenewInterval
+	}
+	if opts.Clock == nil {
+		opts.Clock = clock.RealClock{}
+	}
+	return &Sharder{
+		opts: opts,
+		// identity 可能包含 Lease 名称不允许的字符，使用其哈希值
+		leaseName: fmt.Sprintf("%s-%016x", opts.Group, hash(opts.Identity)),
+	}, nil
+}
+
+// Identity 当前副本的标识
+func (s *Sharder) Identity() string {
+	return s.opts.Identity
+}
+
+// Members 返回最新的成员
+func (s *Sharder) Members() []string {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.pending.Members()
+}
+
+// HasSynced 是否已获取过成员列表
+func (s *Sharder) HasSynced() bool {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.synced
+}
+
+// Owns 当前副本是否负责 key。交接期间只处理在新旧哈希环中都属于自己的 key，避免两个副本同时处理
+func (s *Sharder) Owns(key string) bool {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.owns(key, s.opts.Clock.Now())
+}
+
+func (s *Sharder) owns(key string, now time.Time) bool {
+	if s.pending.Owner(key) != s.opts.Identity {
+		return false
+	}
+	return !now.Before(s.activateAt) || s.active.Owner(key) == s.opts.Identity
+}
+
+// gaining key 是否正在交接给当前副本，交接完成前由 Owns 过滤
+func (s *Sharder) gaining(key string) bool {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.pending.Owner(key) == s.opts.Identity && !s.owns(key, s.opts.Clock.Now())
+}
+
+// Predicate 过滤不属于当前副本的 key，需要与 Run 一起使用
+func (s *Sharder) Predicate() Predicate {
+	return func(event Event[runtime.Object]) bool {
+		return s.Owns(event.Key)
+	}
+}
+
+// onActivate 交接完成后调用 fn，previous 为交接前的哈希环，用于找出新分到的 key
+func (s *Sharder) onActivate(fn func(previous, current *Ring)) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.listeners = append(s.listeners, fn)
+}
+
+// Run
+//
+//	@Description: 加入分片组，定期续约并刷新成员，ctx 取消时删除自己的 Lease 以便其他副本尽快接管
+//	@param ctx
+//	@return error
+func (s *Sharder) Run(ctx context.Context) error {
+	ticker := s.opts.Clock.NewTicker(s.opts.RenewInterval)
+	defer ticker.Stop()
+	for {
+		if err := s.renew(ctx); err != nil {
+			klog.ErrorS(err, "Failed to renew shard lease", "group", s.opts.Group, "identity", s.opts.Identity)
+		}
+		if err := s.refresh(ctx); err != nil {
+			klog.ErrorS(err, "Failed to list shard members", "group", s.opts.Group)
+		}
+		select {
+		case <-ctx.Done():
+			return s.leave()
+		case <-ticker.C():
+		}
+	}
+}
+
+// renew 创建或续约自己的 Lease
+func (s *Sharder) renew(ctx context.Context) error {
+	leases := s.opts.Client.CoordinationV1().Leases(s.opts.Namespace)
+	now := metaV1.NewMicroTime(s.opts.Clock.Now())
+	seconds := int32(s.opts.LeaseDuration.Seconds())
+	if seconds < 1 {
+		seconds = 1
+	}
+	lease, err := leases.Get(ctx, s.leaseName, metaV1.GetOptions{})
+	if apiErrors.IsNotFound(err) {
+		_, err = leases.Create(ctx, &coordinationV1.Lease{
+			ObjectMeta: metaV1.ObjectMeta{Name: s.leaseName, Namespace: s.opts.Namespace, Labels: map[string]string{ShardGroupLabel: s.opts.Group}},
+			Spec: coordinationV1.LeaseSpec{
+				HolderIdentity:       &s.opts.Identity,
+				LeaseDurationSeconds: &seconds,
+				AcquireTime:          &now,
+				RenewTime:            &now,
+			},
+		}, metaV1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	lease.Spec.HolderIdentity = &s.opts.Identity
+	lease.Spec.LeaseDurationSeconds = &seconds
+	lease.Spec.RenewTime = &now
+	_, err = leases.Update(ctx, lease, metaV1.UpdateOptions{})
+	return err
+}
+
+// leave 删除自己的 Lease
+func (s *Sharder) leave() error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.opts.RenewInterval)
+	defer cancel()
+	err := s.opts.Client.CoordinationV1().Leases(s.opts.Namespace).Delete(ctx, s.leaseName, metaV1.DeleteOptions{})
+	if err != nil && !apiErrors.IsNotFound(err) {
+		return fmt.Errorf("sharder: leave %s: %w", s.opts.Group, err)
+	}
+	return nil
+}
+
+// refresh 列出未过期的 Lease 并更新哈希环，删除过期超过 LeaseDuration 的 Lease
+func (s *Sharder) refresh(ctx context.Context) error {
+	list, err := s.opts.Client.CoordinationV1().Leases(s.opts.Namespace).List(ctx, metaV1.ListOptions{LabelSelector: ShardGroupLabel + "=" + s.opts.Group})
+	if err != nil {
+		return err
+	}
+	now := s.opts.Clock.Now()
+	var members []string
+	for i := range list.Items {
+		spec := list.Items[i].Spec
+		if spec.HolderIdentity == nil || spec.RenewTime == nil || spec.LeaseDurationSeconds == nil {
+			continue
+		}
+		duration := time.Duration(*spec.LeaseDurationSeconds) * time.Second
+		if expireAt := spec.RenewTime.Add(duration); expireAt.Before(now) {
+			if expireAt.Add(duration).Before(now) {
+				s.collect(ctx, &list.Items[i])
+			}
+			continue
+		}
+		members = append(members, *spec.HolderIdentity)
+	}
+	sort.Strings(members)
+	s.update(members, now)
+	return nil
+}
+
+// collect 删除崩溃的副本留下的 Lease。只在 resourceVersion 未变化时删除，
+// 期间恢复续约的副本不受影响，被误删时下次续约会重新创建
+func (s *Sharder) collect(ctx context.Context, lease *coordinationV1.Lease) {
+	err := s.opts.Client.CoordinationV1().Leases(s.opts.Namespace).Delete(ctx, lease.Name, metaV1.DeleteOptions{
+		Preconditions: &metaV1.Preconditions{ResourceVersion: &lease.ResourceVersion},
+	})
+	switch {
+	case apiErrors.IsNotFound(err), apiErrors.IsConflict(err):
+		return
+	case err != nil:
+		klog.ErrorS(err, "Failed to delete expired shard lease", "group", s.opts.Group, "lease", lease.Name)
+		return
+	}
+	klog.V(2).InfoS("Deleted expired shard lease", "group", s.opts.Group, "lease", lease.Name, "identity", *lease.Spec.HolderIdentity)
+}
+
+// update 交接时间到达后激活新的哈希环，成员变化时开始新的交接
+func (s *Sharder) update(members []string, now time.Time) {
+	s.lock.Lock()
+	var (
+		previous, current *Ring
+		listeners         []func(previous, current *Ring)
+		changed           bool
+	)
+	if s.synced && s.active != s.pending && !now.Before(s.activateAt) {
+		previous, current = s.active, s.pending
+		s.active = s.pending
+		listeners = s.listeners
+	}
+	// 首次加入时其他副本也需要时间发现自己，同样等待交接。
+	// 交接期间只有在新旧两个环中都属于自己的 key 才会被处理
+	if !s.synced || !reflect.DeepEqual(members, s.pending.Members()) {
+		s.pending = NewRing(members, s.opts.VirtualNodes)
+		s.activateAt = now.Add(s.opts.HandoffDelay)
+		s.synced, changed = true, true
+	}
+	s.lock.Unlock()
+
+	if changed {
+		klog.InfoS("Shard members changed", "group", s.opts.Group, "identity", s.opts.Identity, "members", members)
+		if s.opts.OnChange != nil {
+			s.opts.OnChange(members)
+		}
+	}
+	for _, fn := range listeners {
+		fn(previous, current)
+	}
+}
@@ -0,0 +1,62 @@
+package controller
+
+import (
+	"context"
+	"k8s-dev/pkg/sink"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"reflect"
+	"time"
+)
+
+// NewRecord 把事件转换为 sink.Record，Kind 优先取对象自带的 TypeMeta，其次从 client-go 的 scheme 中查找
+func NewRecord[T runtime.Object](event Event[T]) sink.Record {
+	record := sink.Record{
+		Time:      time.Now(),
+		Type:      string(event.Type),
+		Key:       event.Key,
+		Cluster:   event.Cluster,
+		Tombstone: event.Tombstone,
+		Diff:      event.Diff,
+	}
+	if !isNil(event.Object) {
+		record.Object = event.Object
+		record.Kind = kindOf(event.Object)
+		if m, err := meta.Accessor(event.Object); err == nil {
+			record.Namespace = m.GetNamespace()
+			record.Name = m.GetName()
+			record.UID = string(m.GetUID())
+			record.ResourceVersion = m.GetResourceVersion()
+		}
+	}
+	if !isNil(event.Old) {
+		record.Old = event.Old
+	}
+	return record
+}
+
+// SinkReconciler 把每个事件转换为 sink.Record 发送给 s，发送失败时按控制器的重试策略重试
+func SinkReconciler[T runtime.Object](s sink.Sink) Reconciler[T] {
+	return func(ctx context.Context, event Event[T]) error {
+		return s.Send(ctx, NewRecord(event))
+	}
+}
+
+func isNil(obj runtime.Object) bool {
+	if obj == nil {
+		return true
+	}
+	v := reflect.ValueOf(obj)
+	return v.Kind() == reflect.Pointer && v.IsNil()
+}
+
+func kindOf(obj runtime.Object) string {
+	if kind := obj.GetObjectKind().GroupVersionKind().Kind; kind != "" {
+		return kind
+	}
+	if kinds, _, err := scheme.Scheme.ObjectKinds(obj); err == nil && len(kinds) > 0 {
+		return kinds[0].Kind
+	}
+	return reflect.Indirect(reflect.ValueOf(obj)).Type().Name()
+}
@@ -0,0 +1,145 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// SnapshotOptions 缓存快照的参数
+type SnapshotOptions struct {
+	// Path 快照文件。启动时如果文件存在，使用快照填充缓存并从快照的 resourceVersion 开始 watch，
+	// 只对使用 ListWatch 的控制器生效。bookmark 会使快照的 resourceVersion 保持最新，重启时不容易过期
+	Path string
+	// Interval 大于 0 时定期保存快照
+	Interval time.Duration
+	// SaveOnShutdown 停止时保存快照
+	SaveOnShutdown bool
+}
+
+// Snapshot 缓存快照，可以用于热启动，也可以离线加载后配合 index.Query 查询
+type Snapshot[T runtime.Object] struct {
+	// ResourceVersion 保存快照时 informer 最后同步的 resourceVersion
+	ResourceVersion string    `json:"resourceVersion"`
+	SavedAt         time.Time `json:"savedAt"`
+	Items           []T       `json:"items"`
+}
+
+// LoadSnapshot 从 path 加载快照
+func LoadSnapshot[T runtime.Object](path string) (*Snapshot[T], error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := &Snapshot[T]{}
+	if err := json.Unmarshal(data, snapshot); err != nil {
+		return nil, fmt.Errorf("load snapshot %s: %w", path, err)
+	}
+	return snapshot, nil
+}
+
+// Save 把快照写入 path，先写临时文件再改名，避免中途失败留下不完整的快照
+func (s *Snapshot[T]) Save(path string) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("save snapshot %s: %w", path, err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("save snapshot %s: %w", path, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("save snapshot %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("save snapshot %s: %w", path, err)
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Indexer 使用快照中的对象创建本地缓存，用于离线查询
+func (s *Snapshot[T]) Indexer(indexers cache.Indexers) (cache.Indexer, error) {
+	if indexers == nil {
+		indexers = cache.Indexers{}
+	}
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, indexers)
+	for _, item := range s.Items {
+		if err := indexer.Add(item); err != nil {
+			return nil, err
+		}
+	}
+	return indexer, nil
+}
+
+// Snapshot 返回当前缓存的快照，对象按 key 排序
+func (c *Controller[T]) Snapshot() *Snapshot[T] {
+	// 先读取 resourceVersion 再读取对象，保证对象不会比 resourceVersion 旧，
+	// 从该 resourceVersion 开始 watch 最多重复收到已有的变更
+	snapshot := &Snapshot[T]{ResourceVersion: c.informer.LastSyncResourceVersion(), SavedAt: time.Now()}
+	keys := c.Indexer().ListKeys()
+	sort.Strings(keys)
+	for _, key := range keys {
+		obj, exists, err := c.Indexer().GetByKey(key)
+		if err != nil || !exists {
+			continue
+		}
+		if typed, ok := obj.(T); ok {
+			snapshot.Items = append(snapshot.Items, typed)
+		}
+	}
+	return snapshot
+}
+
+// SaveSnapshot 把当前缓存的快照写入 path
+func (c *Controller[T]) SaveSnapshot(path string) error {
+	snapshot := c.Snapshot()
+	if err := snapshot.Save(path); err != nil {
+		return fmt.Errorf("controller %s: %w", c.name, err)
+	}
+	klog.V(2).InfoS("Saved cache snapshot", "controller", c.name, "path", path, "items", len(snapshot.Items), "resourceVersion", snapshot.ResourceVersion)
+	return nil
+}
+
+// seedFromSnapshot 快照文件存在时把它作为 lw 的恢复点
+func seedFromSnapshot[T runtime.Object](name, path string, lw *resumingListWatch) error {
+	snapshot, err := LoadSnapshot[T](path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	klog.InfoS("Seeding cache from snapshot", "controller", name, "path", path, "items", len(snapshot.Items), "resourceVersion", snapshot.ResourceVersion)
+	items := make([]runtime.Object, 0, len(snapshot.Items))
+	for _, item := range snapshot.Items {
+		items = append(items, item)
+	}
+	lw.seed(items, snapshot.ResourceVersion)
+	return nil
+}
+
+// saveSnapshots 每隔 Interval 保存一次快照，直到 ctx 取消
+func (c *Controller[T]) saveSnapshots(ctx context.Context) {
+	ticker := time.NewTicker(c.snapshot.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.SaveSnapshot(c.snapshot.Path); err != nil {
+				utilruntime.HandleError(err)
+			}
+		}
+	}
+}
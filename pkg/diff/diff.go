@@ -0,0 +1,141 @@
+package diff
+
+import (
+	"fmt"
+	"k8s.io/apimachinery/pkg/runtime"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultIgnore 默认忽略的字段，每次更新都会变化，没有审计价值
+var DefaultIgnore = []string{"metadata.resourceVersion", "metadata.managedFields"}
+
+// Change 一个字段的变化，新增字段的 Old 和删除字段的 New 为 nil
+type Change struct {
+	// Path 字段路径，例如 spec.containers[0].image、metadata.labels["app.kubernetes.io/name"]
+	Path string      `json:"path"`
+	Old  interface{} `json:"old,omitempty"`
+	New  interface{} `json:"new,omitempty"`
+}
+
+// String 以 path: old -> new 的格式输出
+func (c Change) String() string {
+	return fmt.Sprintf("%s: %s -> %s", c.Path, format(c.Old), format(c.New))
+}
+
+func format(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "<none>"
+	case string:
+		return strconv.Quote(v)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// Objects
+//
+//	@Description: 比较两个对象，返回按路径排序的字段变化
+//	@param old
+//	@param new
+//	@param ignore: 忽略的字段路径，其子字段同样忽略，为空时使用 DefaultIgnore
+//	@return []Change
+//	@return error
+func Objects(old, new runtime.Object, ignore ...string) ([]Change, error) {
+	oldContent, err := runtime.DefaultUnstructuredConverter.ToUnstructured(old)
+	if err != nil {
+		return nil, err
+	}
+	newContent, err := runtime.DefaultUnstructuredConverter.ToUnstructured(new)
+	if err != nil {
+		return nil, err
+	}
+	return Maps(oldContent, newContent, ignore...), nil
+}
+
+// Maps 比较两个 unstructured 内容，参数同 Objects
+func Maps(old, new map[string]interface{}, ignore ...string) []Change {
+	if len(ignore) == 0 {
+		ignore = DefaultIgnore
+	}
+	d := &differ{ignore: ignore}
+	d.compare("", old, new)
+	return d.changes
+}
+
+type differ struct {
+	ignore  []string
+	changes []Change
+}
+
+func (d *differ) ignored(path string) bool {
+	for _, p := range d.ignore {
+		if path == p || strings.HasPrefix(path, p+".") || strings.HasPrefix(path, p+"[") {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *differ) compare(path string, old, new interface{}) {
+	if d.ignored(path) {
+		return
+	}
+	oldMap, oldIsMap := old.(map[string]interface{})
+	newMap, newIsMap := new.(map[string]interface{})
+	if oldIsMap && newIsMap {
+		keys := map[string]bool{}
+		for k := range oldMap {
+			keys[k] = true
+		}
+		for k := range newMap {
+			keys[k] = true
+		}
+		sorted := make([]string, 0, len(keys))
+		for k := range keys {
+			sorted = append(sorted, k)
+		}
+		sort.Strings(sorted)
+		for _, k := range sorted {
+			d.compare(field(path, k), oldMap[k], newMap[k])
+		}
+		return
+	}
+
+	oldList, oldIsList := old.([]interface{})
+	newList, newIsList := new.([]interface{})
+	if oldIsList && newIsList {
+		for i := 0; i < len(oldList) || i < len(newList); i++ {
+			var o, n interface{}
+			if i < len(oldList) {
+				o = oldList[i]
+			}
+			if i < len(newList) {
+				n = newList[i]
+			}
+			d.compare(fmt.Sprintf("%s[%d]", path, i), o, n)
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(old, new) {
+		d.changes = append(d.changes, Change{Path: path, Old: old, New: new})
+	}
+}
+
+var identifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// field 拼接字段路径，包含特殊字符的 key 使用 ["key"]
+func field(path, key string) string {
+	if !identifier.MatchString(key) {
+		return path + "[" + strconv.Quote(key) + "]"
+	}
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,69 @@
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sigs.k8s.io/yaml"
+	"text/tabwriter"
+	"time"
+)
+
+// 输出格式
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+)
+
+// WriteRevisions
+//
+//	@Description: 以表格或 JSON 输出版本列表，表格不包含对象内容
+//	@param w
+//	@param revs
+//	@param format: text|json
+//	@return error
+func WriteRevisions(w io.Writer, revs []Revision, format string) error {
+	switch format {
+	case FormatJSON:
+		return json.NewEncoder(w).Encode(revs)
+	case FormatText, "":
+		tw := tabwriter.NewWriter(w, 0, 8, 3, ' ', 0)
+		fmt.Fprintln(tw, "TIME\tTYPE\tKIND\tKEY\tRESOURCEVERSION\tUID")
+		for _, rev := range revs {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", rev.Time.Format(time.RFC3339), rev.Type, rev.Kind, rev.Key, rev.ResourceVersion, rev.UID)
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("unknown format %q", format)
+	}
+}
+
+// WriteObject
+//
+//	@Description: 输出版本中的完整对象
+//	@param w
+//	@param rev
+//	@param format: yaml|json
+//	@return error
+func WriteObject(w io.Writer, rev Revision, format string) error {
+	switch format {
+	case FormatYAML, "":
+		data, err := yaml.JSONToYAML(rev.Object)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	case FormatJSON:
+		var object interface{}
+		if err := json.Unmarshal(rev.Object, &object); err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(object)
+	default:
+		return fmt.Errorf("unknown format %q", format)
+	}
+}
@@ -0,0 +1,115 @@
+package history
+
+import (
+	"context"
+	"k8s-dev/pkg/controller"
+	"k8s-dev/pkg/sink"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/tools/cache"
+	"sync"
+	"time"
+)
+
+// DefaultRetryInterval 写入失败（例如查询长时间占用文件锁）后重试的间隔
+const DefaultRetryInterval = time.Second
+
+// Recorder 把 informer 的每个通知按观察到的时间写入 Store。通知不经过控制器的工作队列，
+// 处理前的多次更新不会被合并，每个中间版本都会保存
+type Recorder struct {
+	store *Store
+
+	lock    sync.Mutex
+	pending []sink.Record
+	notify  chan struct{}
+}
+
+// NewRecorder 创建写入 store 的 Recorder，需要调用 Run 才会写入
+func NewRecorder(store *Store) *Recorder {
+	return &Recorder{store: store, notify: make(chan struct{}, 1)}
+}
+
+// Handler 返回注册到 informer 的 handler，只把记录加入队列，不会阻塞 informer
+func (r *Recorder) Handler() cache.ResourceEventHandler {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			r.observe(cache.Added, obj)
+		},
+		UpdateFunc: func(_, obj interface{}) {
+			r.observe(cache.Updated, obj)
+		},
+		DeleteFunc: func(obj interface{}) {
+			r.observe(cache.Deleted, obj)
+		},
+	}
+}
+
+// observe 以当前时间生成记录并加入队列
+func (r *Recorder) observe(action cache.DeltaType, obj interface{}) {
+	event := controller.Event[runtime.Object]{Type: action}
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		event.Key, event.Tombstone, obj = tombstone.Key, true, tombstone.Obj
+	} else {
+		key, err := cache.MetaNamespaceKeyFunc(obj)
+		if err != nil {
+			utilruntime.HandleError(err)
+			return
+		}
+		event.Key = key
+	}
+	if object, ok := obj.(runtime.Object); ok {
+		event.Object = object
+	}
+	record := controller.NewRecord(event)
+	record.Time = r.store.opts.Clock.Now()
+
+	r.lock.Lock()
+	r.pending = append(r.pending, record)
+	r.lock.Unlock()
+	select {
+	case r.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Run
+//
+//	@Description: 把队列中的记录批量写入 Store，写入失败时保留记录，在 DefaultRetryInterval 后重试。
+//	ctx 取消时写入剩余的记录后返回
+//	@param ctx
+func (r *Recorder) Run(ctx context.Context) {
+	for {
+		var retry <-chan time.Time
+		if err := r.flush(); err != nil {
+			utilruntime.HandleError(err)
+			retry = time.After(DefaultRetryInterval)
+		}
+		select {
+		case <-ctx.Done():
+			if err := r.flush(); err != nil {
+				utilruntime.HandleError(err)
+			}
+			return
+		case <-r.notify:
+		case <-retry:
+		}
+	}
+}
+
+// flush 在一个事务中写入队列中的所有记录，失败时放回队列
+func (r *Recorder) flush() error {
+	r.lock.Lock()
+	records := r.pending
+	r.pending = nil
+	r.lock.Unlock()
+	if len(records) == 0 {
+		return nil
+	}
+	if err := r.store.Write(records...); err != nil {
+		r.lock.Lock()
+		r.pending = append(records, r.pending...)
+		r.lock.Unlock()
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,457 @@
+package history
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	bolt "go.etcd.io/bbolt"
+	"k8s-dev/pkg/sink"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/clock"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultPruneInterval 按 MaxAge 清理的默认间隔
+const DefaultPruneInterval = time.Minute
+
+// DefaultOpenTimeout 等待数据库文件锁的时间。写入时持有排他锁，查询时持有共享锁，
+// Store 只在每个事务期间打开数据库，因此正在记录的历史库也可以查询，只需等待正在进行的事务结束
+const DefaultOpenTimeout = time.Second
+
+var (
+	// bucketRevisions <uid>\x00<seq> → Revision 的 JSON，seq 全局递增，同一对象的版本按观察到的顺序排列
+	bucketRevisions = []byte("revisions")
+	// bucketVersions <uid>\x00<resourceVersion>[\x00Deleted] → 版本的 key，用于去重
+	bucketVersions = []byte("versions")
+	// bucketKeys <kind>\x00<key>\x00<uid> → 空，按名称查找对象，同名重建的对象有多个 uid
+	bucketKeys = []byte("keys")
+	// bucketTimes <time><seq> → 版本的 key，按时间查询和清理
+	bucketTimes = []byte("times")
+)
+
+// Revision 对象的一个版本，Type 为变更类型（Added、Updated、Deleted、Sync），Object 为完整的对象
+type Revision struct {
+	UID             string          `json:"uid"`
+	ResourceVersion string          `json:"resourceVersion,omitempty"`
+	Type            string          `json:"type"`
+	Time            time.Time       `json:"time"`
+	Kind            string          `json:"kind"`
+	Key             string          `json:"key"`
+	Cluster         string          `json:"cluster,omitempty"`
+	Namespace       string          `json:"namespace,omitempty"`
+	Name            string          `json:"name"`
+	Object          json.RawMessage `json:"object,omitempty"`
+}
+
+// Options 打开历史库的参数
+type Options struct {
+	// Path 数据库文件路径，不存在时创建
+	Path string
+	// ReadOnly 只读打开，用于查询
+	ReadOnly bool
+	// MaxAge 版本的保留时长，由 Prune 清理，0 表示不限制
+	MaxAge time.Duration
+	// MaxRevisions 每个对象最多保留的版本数，写入时删除最旧的版本，0 表示不限制
+	MaxRevisions int
+	// Clock 默认使用真实时间
+	Clock clock.PassiveClock
+}
+
+// Store 保存对象变更历史的本地数据库，实现了 sink.Sink，
+// 一般通过 Recorder 写入 informer 观察到的每次变更
+type Store struct {
+	opts Options
+	// lock 同一进程内的事务依次打开数据库，文件锁属于打开的文件，同时打开两次会互相等待
+	lock sync.Mutex
+}
+
+// Open
+//
+//	@Description: 创建历史库或检查历史库可以读取。数据库只在每个事务期间打开，
+//	文件正在被其他进程写入或查询时，事务等待 DefaultOpenTimeout 后返回错误
+//	@param opts
+//	@return *Store
+//	@return error
+func Open(opts Options) (*Store, error) {
+	if opts.Path == "" {
+		return nil, errors.New("history: Path is required")
+	}
+	if opts.Clock == nil {
+		opts.Clock = clock.RealClock{}
+	}
+	s := &Store{opts: opts}
+	var err error
+	if opts.ReadOnly {
+		err = s.view(func(*bolt.Tx) error { return nil })
+	} else {
+		err = s.update(func(tx *bolt.Tx) error {
+			for _, name := range [][]byte{bucketRevisions, bucketVersions, bucketKeys, bucketTimes} {
+				if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Close 数据库只在事务期间打开，没有需要释放的资源
+func (s *Store) Close() error {
+	return nil
+}
+
+// update 打开数据库执行读写事务，结束后立即关闭以释放文件锁
+func (s *Store) update(fn func(tx *bolt.Tx) error) error {
+	return s.transaction(func(db *bolt.DB) error { return db.Update(fn) })
+}
+
+// view 打开数据库执行只读事务，结束后立即关闭以释放文件锁
+func (s *Store) view(fn func(tx *bolt.Tx) error) error {
+	return s.transaction(func(db *bolt.DB) error { return db.View(fn) })
+}
+
+func (s *Store) transaction(fn func(db *bolt.DB) error) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	db, err := bolt.Open(s.opts.Path, 0o600, &bolt.Options{Timeout: DefaultOpenTimeout, ReadOnly: s.opts.ReadOnly})
+	if err != nil {
+		return fmt.Errorf("history: open %s: %w", s.opts.Path, err)
+	}
+	err = fn(db)
+	if closeErr := db.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Send 保存记录中的对象，见 Write
+func (s *Store) Send(_ context.Context, record sink.Record) error {
+	return s.Write(record)
+}
+
+// Write 在一个事务中保存多条记录。resourceVersion 相同的重复记录（例如 resync）只保存一次，
+// 没有 uid 的记录（对象未知的 tombstone）无法关联到对象，直接忽略
+func (s *Store) Write(records ...sink.Record) error {
+	var revs []Revision
+	var data [][]byte
+	for _, record := range records {
+		if record.UID == "" {
+			continue
+		}
+		rev, encoded, err := s.encode(record)
+		if err != nil {
+			return err
+		}
+		revs, data = append(revs, rev), append(data, encoded)
+	}
+	if len(revs) == 0 {
+		return nil
+	}
+
+	return s.update(func(tx *bolt.Tx) error {
+		for i, rev := range revs {
+			if err := s.put(tx, rev, data[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// encode 把记录转换为版本及其 JSON
+func (s *Store) encode(record sink.Record) (Revision, []byte, error) {
+	rev := Revision{
+		UID:             record.UID,
+		ResourceVersion: record.ResourceVersion,
+		Type:            record.Type,
+		Time:            record.Time,
+		Kind:            record.Kind,
+		Key:             record.Key,
+		Cluster:         record.Cluster,
+		Namespace:       record.Namespace,
+		Name:            record.Name,
+	}
+	if rev.Time.IsZero() {
+		rev.Time = s.opts.Clock.Now()
+	}
+	if record.Object != nil {
+		object, err := json.Marshal(record.Object)
+		if err != nil {
+			return rev, nil, fmt.Errorf("history: encode %s: %w", record.Key, err)
+		}
+		rev.Object = object
+	}
+	data, err := json.Marshal(rev)
+	if err != nil {
+		return rev, nil, fmt.Errorf("history: encode %s: %w", record.Key, err)
+	}
+	return rev, data, nil
+}
+
+// put 保存一个版本及其索引
+func (s *Store) put(tx *bolt.Tx, rev Revision, data []byte) error {
+	versions := tx.Bucket(bucketVersions)
+	version := versionKey(rev)
+	if rev.ResourceVersion != "" && versions.Get(version) != nil {
+		return nil
+	}
+	revisions := tx.Bucket(bucketRevisions)
+	seq, err := revisions.NextSequence()
+	if err != nil {
+		return err
+	}
+	key := revisionKey(rev.UID, seq)
+	if err := revisions.Put(key, data); err != nil {
+		return err
+	}
+	if rev.ResourceVersion != "" {
+		if err := versions.Put(version, key); err != nil {
+			return err
+		}
+	}
+	if err := tx.Bucket(bucketKeys).Put(objectKey(rev.Kind, rev.Key, rev.UID), nil); err != nil {
+		return err
+	}
+	if err := tx.Bucket(bucketTimes).Put(timeKey(rev.Time, seq), key); err != nil {
+		return err
+	}
+	if s.opts.MaxRevisions > 0 {
+		return s.trim(tx, rev.UID)
+	}
+	return nil
+}
+
+// trim 删除 uid 超出 MaxRevisions 的最旧版本
+func (s *Store) trim(tx *bolt.Tx, uid string) error {
+	var keys [][]byte
+	prefix := []byte(uid + "\x00")
+	c := tx.Bucket(bucketRevisions).Cursor()
+	for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+		keys = append(keys, append([]byte(nil), k...))
+	}
+	for i := 0; i < len(keys)-s.opts.MaxRevisions; i++ {
+		if err := deleteRevision(tx, keys[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Prune 删除超过 MaxAge 的版本，返回删除的数量
+func (s *Store) Prune() (int, error) {
+	if s.opts.MaxAge <= 0 {
+		return 0, nil
+	}
+	cutoff := timeKey(s.opts.Clock.Now().Add(-s.opts.MaxAge), 0)
+	n := 0
+	err := s.update(func(tx *bolt.Tx) error {
+		var keys [][]byte
+		c := tx.Bucket(bucketTimes).Cursor()
+		for k, v := c.First(); k != nil && bytes.Compare(k, cutoff) < 0; k, v = c.Next() {
+			keys = append(keys, append([]byte(nil), v...))
+		}
+		for _, key := range keys {
+			if err := deleteRevision(tx, key); err != nil {
+				return err
+			}
+		}
+		n = len(keys)
+		return nil
+	})
+	return n, err
+}
+
+// Run
+//
+//	@Description: 每隔 interval 执行一次 Prune，直到 ctx 取消
+//	@param ctx
+//	@param interval: 小于等于 0 时使用 DefaultPruneInterval
+func (s *Store) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultPruneInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := s.Prune()
+			if err != nil {
+				utilruntime.HandleError(err)
+				continue
+			}
+			if n > 0 {
+				klog.V(2).InfoS("Pruned history", "path", s.opts.Path, "revisions", n)
+			}
+		}
+	}
+}
+
+// deleteRevision 删除版本及其索引，对象没有剩余版本时同时删除名称索引
+func deleteRevision(tx *bolt.Tx, key []byte) error {
+	revisions := tx.Bucket(bucketRevisions)
+	data := revisions.Get(key)
+	if data == nil {
+		return nil
+	}
+	var rev Revision
+	if err := json.Unmarshal(data, &rev); err != nil {
+		return err
+	}
+	seq := binary.BigEndian.Uint64(key[len(key)-8:])
+	if err := revisions.Delete(key); err != nil {
+		return err
+	}
+	versions := tx.Bucket(bucketVersions)
+	if version := versionKey(rev); bytes.Equal(versions.Get(version), key) {
+		if err := versions.Delete(version); err != nil {
+			return err
+		}
+	}
+	if err := tx.Bucket(bucketTimes).Delete(timeKey(rev.Time, seq)); err != nil {
+		return err
+	}
+	prefix := []byte(rev.UID + "\x00")
+	if k, _ := revisions.Cursor().Seek(prefix); k == nil || !bytes.HasPrefix(k, prefix) {
+		return tx.Bucket(bucketKeys).Delete(objectKey(rev.Kind, rev.Key, rev.UID))
+	}
+	return nil
+}
+
+// Revisions 返回 uid 的所有版本，按观察到的顺序排列
+func (s *Store) Revisions(uid string) ([]Revision, error) {
+	var revs []Revision
+	err := s.view(func(tx *bolt.Tx) error {
+		var err error
+		revs, err = revisionsOf(tx, uid)
+		return err
+	})
+	return revs, err
+}
+
+// History 返回 kind 中 key（namespace/name）对应对象的所有版本，包括同名重建前的对象，按时间排列
+func (s *Store) History(kind, key string) ([]Revision, error) {
+	var revs []Revision
+	err := s.view(func(tx *bolt.Tx) error {
+		keys := tx.Bucket(bucketKeys)
+		if keys == nil {
+			return nil
+		}
+		prefix := []byte(kind + "\x00" + key + "\x00")
+		c := keys.Cursor()
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			matched, err := revisionsOf(tx, string(k[len(prefix):]))
+			if err != nil {
+				return err
+			}
+			revs = append(revs, matched...)
+		}
+		return nil
+	})
+	sort.SliceStable(revs, func(i, j int) bool {
+		return revs[i].Time.Before(revs[j].Time)
+	})
+	return revs, err
+}
+
+// At
+//
+//	@Description: 返回 t 时刻 kind 中 key 对应对象的版本，即 t 之前的最后一个版本
+//	@param kind
+//	@param key: namespace/name
+//	@param t
+//	@return Revision
+//	@return bool: t 时刻对象不存在（尚未创建或已删除）时为 false
+//	@return error
+func (s *Store) At(kind, key string, t time.Time) (Revision, bool, error) {
+	revs, err := s.History(kind, key)
+	if err != nil {
+		return Revision{}, false, err
+	}
+	for i := len(revs) - 1; i >= 0; i-- {
+		if !revs[i].Time.After(t) {
+			return revs[i], revs[i].Type != string(cache.Deleted), nil
+		}
+	}
+	return Revision{}, false, nil
+}
+
+// Changes 返回 [from, to) 期间所有对象的版本，按时间排列
+func (s *Store) Changes(from, to time.Time) ([]Revision, error) {
+	var revs []Revision
+	err := s.view(func(tx *bolt.Tx) error {
+		times := tx.Bucket(bucketTimes)
+		if times == nil {
+			return nil
+		}
+		revisions := tx.Bucket(bucketRevisions)
+		end := timeKey(to, 0)
+		c := times.Cursor()
+		for k, v := c.Seek(timeKey(from, 0)); k != nil && bytes.Compare(k, end) < 0; k, v = c.Next() {
+			var rev Revision
+			if err := json.Unmarshal(revisions.Get(v), &rev); err != nil {
+				return err
+			}
+			revs = append(revs, rev)
+		}
+		return nil
+	})
+	return revs, err
+}
+
+func revisionsOf(tx *bolt.Tx, uid string) ([]Revision, error) {
+	revisions := tx.Bucket(bucketRevisions)
+	if revisions == nil {
+		return nil, nil
+	}
+	var revs []Revision
+	prefix := []byte(uid + "\x00")
+	c := revisions.Cursor()
+	for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+		var rev Revision
+		if err := json.Unmarshal(v, &rev); err != nil {
+			return nil, err
+		}
+		revs = append(revs, rev)
+	}
+	return revs, nil
+}
+
+func revisionKey(uid string, seq uint64) []byte {
+	return binary.BigEndian.AppendUint64([]byte(uid+"\x00"), seq)
+}
+
+func versionKey(rev Revision) []byte {
+	key := rev.UID + "\x00" + rev.ResourceVersion
+	// 删除事件的 resourceVersion 与最后一次更新相同
+	if rev.Type == string(cache.Deleted) {
+		key += "\x00Deleted"
+	}
+	return []byte(key)
+}
+
+func objectKey(kind, key, uid string) []byte {
+	return []byte(kind + "\x00" + key + "\x00" + uid)
+}
+
+// timeKey 按时间排序的 key，1970 年之前的时间视为 0
+func timeKey(t time.Time, seq uint64) []byte {
+	nanos := t.UnixNano()
+	if nanos < 0 {
+		nanos = 0
+	}
+	return binary.BigEndian.AppendUint64(binary.BigEndian.AppendUint64(nil, uint64(nanos)), seq)
+}
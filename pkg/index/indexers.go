@@ -0,0 +1,108 @@
+package index
+
+import (
+	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/client-go/tools/cache"
+)
+
+// 索引名称
+const (
+	// PodNode 按 spec.nodeName 索引，未调度的 pod 不建立索引
+	PodNode = "pod-node"
+	// PodPhase 按 status.phase 索引
+	PodPhase = "pod-phase"
+	// PodImage 按容器镜像索引，包括 init 容器和临时容器
+	PodImage = "pod-image"
+	// Owner 按 ownerReferences 的 uid 索引
+	Owner = "owner"
+	// Label 按 key=value 索引所有标签
+	Label = "label"
+)
+
+// PodIndexers 返回所有 pod 索引，同时包含 cache.NamespaceIndex，可以直接作为 Options.Indexers
+func PodIndexers() cache.Indexers {
+	return cache.Indexers{
+		cache.NamespaceIndex: cache.MetaNamespaceIndexFunc,
+		PodNode:              PodNodeIndexFunc,
+		PodPhase:             PodPhaseIndexFunc,
+		PodImage:             PodImageIndexFunc,
+		Owner:                OwnerIndexFunc,
+		Label:                LabelIndexFunc,
+	}
+}
+
+// LabelValue 返回 Label 索引使用的值
+func LabelValue(key, value string) string {
+	return key + "=" + value
+}
+
+// PodNodeIndexFunc 按节点名称索引 pod
+func PodNodeIndexFunc(obj interface{}) ([]string, error) {
+	pod, ok := obj.(*coreV1.Pod)
+	if !ok || pod.Spec.NodeName == "" {
+		return nil, nil
+	}
+	return []string{pod.Spec.NodeName}, nil
+}
+
+// PodPhaseIndexFunc 按阶段索引 pod
+func PodPhaseIndexFunc(obj interface{}) ([]string, error) {
+	pod, ok := obj.(*coreV1.Pod)
+	if !ok || pod.Status.Phase == "" {
+		return nil, nil
+	}
+	return []string{string(pod.Status.Phase)}, nil
+}
+
+// PodImageIndexFunc 按容器镜像索引 pod，同一镜像只索引一次
+func PodImageIndexFunc(obj interface{}) ([]string, error) {
+	pod, ok := obj.(*coreV1.Pod)
+	if !ok {
+		return nil, nil
+	}
+	seen := map[string]bool{}
+	var images []string
+	add := func(image string) {
+		if image != "" && !seen[image] {
+			seen[image] = true
+			images = append(images, image)
+		}
+	}
+	for _, c := range pod.Spec.InitContainers {
+		add(c.Image)
+	}
+	for _, c := range pod.Spec.Containers {
+		add(c.Image)
+	}
+	for _, c := range pod.Spec.EphemeralContainers {
+		add(c.Image)
+	}
+	return images, nil
+}
+
+// OwnerIndexFunc 按 owner 的 uid 索引任意对象
+func OwnerIndexFunc(obj interface{}) ([]string, error) {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return nil, err
+	}
+	var uids []string
+	for _, ref := range accessor.GetOwnerReferences() {
+		uids = append(uids, string(ref.UID))
+	}
+	return uids, nil
+}
+
+// LabelIndexFunc 按 key=value 索引任意对象的标签
+func LabelIndexFunc(obj interface{}) ([]string, error) {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return nil, err
+	}
+	var values []string
+	for k, v := range accessor.GetLabels() {
+		values = append(values, LabelValue(k, v))
+	}
+	return values, nil
+}
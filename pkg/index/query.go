@@ -0,0 +1,145 @@
+package index
+
+import (
+	"fmt"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/cache"
+	"sort"
+)
+
+// Reader 查询使用的只读缓存，cache.Indexer 满足该接口
+type Reader interface {
+	List() []interface{}
+	GetByKey(key string) (item interface{}, exists bool, err error)
+	IndexKeys(indexName, indexedValue string) ([]string, error)
+}
+
+// keyLister 能直接列出 key 的 Reader，例如 cache.Indexer 和合并多个集群的缓存
+type keyLister interface {
+	ListKeys() []string
+}
+
+// condition 一个索引条件
+type condition struct {
+	index, value string
+}
+
+// Query 在本地缓存上组合查询，多个条件取交集，不访问 ApiServer
+type Query[T runtime.Object] struct {
+	reader     Reader
+	conditions []condition
+	selector   labels.Selector
+}
+
+// NewQuery 创建在 reader 上查询 T 的 Query
+func NewQuery[T runtime.Object](reader Reader) *Query[T] {
+	return &Query[T]{reader: reader}
+}
+
+// Where 增加一个索引条件，index 必须已注册到 reader
+func (q *Query[T]) Where(index, value string) *Query[T] {
+	q.conditions = append(q.conditions, condition{index: index, value: value})
+	return q
+}
+
+// InNamespace 只查询 namespace 中的对象，需要注册 cache.NamespaceIndex
+func (q *Query[T]) InNamespace(namespace string) *Query[T] {
+	return q.Where(cache.NamespaceIndex, namespace)
+}
+
+// OwnedBy 只查询 owner uid 为 uid 的对象，需要注册 Owner 索引
+func (q *Query[T]) OwnedBy(uid string) *Query[T] {
+	return q.Where(Owner, uid)
+}
+
+// Selector 按标签选择器过滤，在索引条件之后执行
+func (q *Query[T]) Selector(selector labels.Selector) *Query[T] {
+	q.selector = selector
+	return q
+}
+
+// Keys 返回满足索引条件的 key，按字典序排序；没有索引条件时返回所有对象的 key。
+// reader 实现了 ListKeys 时使用 reader 自己的 key，合并多个集群的缓存中 key 带集群名称
+func (q *Query[T]) Keys() ([]string, error) {
+	if len(q.conditions) == 0 {
+		var keys []string
+		if lister, ok := q.reader.(keyLister); ok {
+			keys = lister.ListKeys()
+		} else {
+			for _, obj := range q.reader.List() {
+				key, err := cache.MetaNamespaceKeyFunc(obj)
+				if err != nil {
+					return nil, err
+				}
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		return keys, nil
+	}
+
+	var result map[string]bool
+	for _, c := range q.conditions {
+		keys, err := q.reader.IndexKeys(c.index, c.value)
+		if err != nil {
+			return nil, fmt.Errorf("query %s=%s: %w", c.index, c.value, err)
+		}
+		next := make(map[string]bool, len(keys))
+		for _, key := range keys {
+			if result == nil || result[key] {
+				next[key] = true
+			}
+		}
+		result = next
+		if len(result) == 0 {
+			break
+		}
+	}
+	keys := make([]string, 0, len(result))
+	for key := range result {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// List 返回满足所有条件的对象，按 key 排序
+func (q *Query[T]) List() ([]T, error) {
+	keys, err := q.Keys()
+	if err != nil {
+		return nil, err
+	}
+	items := make([]T, 0, len(keys))
+	for _, key := range keys {
+		obj, exists, err := q.reader.GetByKey(key)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			// 查询期间对象被删除
+			continue
+		}
+		typed, ok := obj.(T)
+		if !ok {
+			return nil, fmt.Errorf("query: unexpected object type %T for %s", obj, key)
+		}
+		if q.selector != nil {
+			accessor, err := meta.Accessor(typed)
+			if err != nil {
+				return nil, err
+			}
+			if !q.selector.Matches(labels.Set(accessor.GetLabels())) {
+				continue
+			}
+		}
+		items = append(items, typed)
+	}
+	return items, nil
+}
+
+// ByIndex 返回索引 index 中值为 value 的对象，按 key 排序
+func ByIndex[T runtime.Object](reader Reader, index, value string) ([]T, error) {
+	return NewQuery[T](reader).Where(index, value).List()
+}
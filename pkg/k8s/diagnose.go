@@ -0,0 +1,354 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"io"
+	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/kubernetes"
+	"sort"
+	"strings"
+)
+
+// 诊断原因的可信度，数值越大越可能是根因
+const (
+	ScoreDefinite = 100
+	ScoreHigh     = 80
+	ScoreMedium   = 50
+	ScoreLow      = 20
+)
+
+// Cause 一个可能的故障原因及其证据
+type Cause struct {
+	Reason   string   `json:"reason"`
+	Score    int      `json:"score"`
+	Message  string   `json:"message"`
+	Evidence []string `json:"evidence,omitempty"`
+}
+
+// Diagnosis Pod 诊断结果，Causes 按 Score 从高到低排序
+type Diagnosis struct {
+	Namespace string          `json:"namespace"`
+	Name      string          `json:"name"`
+	Phase     coreV1.PodPhase `json:"phase"`
+	Ready     bool            `json:"ready"`
+	Causes    []Cause         `json:"causes"`
+}
+
+// Top 返回最可能的原因，没有原因时返回 nil
+func (d *Diagnosis) Top() *Cause {
+	if len(d.Causes) == 0 {
+		return nil
+	}
+	return &d.Causes[0]
+}
+
+func (d *Diagnosis) add(cause Cause) {
+	for i := range d.Causes {
+		if d.Causes[i].Reason == cause.Reason && d.Causes[i].Message == cause.Message {
+			d.Causes[i].Evidence = append(d.Causes[i].Evidence, cause.Evidence...)
+			if cause.Score > d.Causes[i].Score {
+				d.Causes[i].Score = cause.Score
+			}
+			return
+		}
+	}
+	d.Causes = append(d.Causes, cause)
+}
+
+// DiagnosePod
+//
+//	@Description: 查询 Pod 并诊断其未正常运行的原因
+//	@param ctx
+//	@param client
+//	@param namespace
+//	@param name
+//	@return *Diagnosis
+//	@return error
+func DiagnosePod(ctx context.Context, client kubernetes.Interface, namespace, name string) (*Diagnosis, error) {
+	pod, err := client.CoreV1().Pods(namespace).Get(ctx, name, metaV1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return Diagnose(ctx, client, pod)
+}
+
+// Diagnose
+//
+//	@Description: 根据 Pod 状态、容器状态、调度事件以及引用的 ConfigMap/Secret/PVC 推断 Pod 未正常运行的原因
+//	@param ctx
+//	@param client: 用于查询事件和被引用的资源
+//	@param pod
+//	@return *Diagnosis
+//	@return error
+func Diagnose(ctx context.Context, client kubernetes.Interface, pod *coreV1.Pod) (*Diagnosis, error) {
+	d := &Diagnosis{Namespace: pod.Namespace, Name: pod.Name, Phase: pod.Status.Phase, Ready: podReady(pod)}
+
+	diagnoseConditions(d, pod)
+	diagnoseContainers(d, pod)
+	diagnoseReferences(ctx, d, client, pod)
+	diagnoseEvents(ctx, d, client, pod)
+
+	sort.SliceStable(d.Causes, func(i, j int) bool {
+		return d.Causes[i].Score > d.Causes[j].Score
+	})
+	return d, nil
+}
+
+func podReady(pod *coreV1.Pod) bool {
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == coreV1.PodReady {
+			return cond.Status == coreV1.ConditionTrue
+		}
+	}
+	return false
+}
+
+func diagnoseConditions(d *Diagnosis, pod *coreV1.Pod) {
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == coreV1.PodScheduled && cond.Status == coreV1.ConditionFalse {
+			d.add(Cause{
+				Reason:   "Unschedulable",
+				Score:    ScoreHigh,
+				Message:  "Pod 无法被调度到任何节点",
+				Evidence: []string{fmt.Sprintf("condition %s=%s: %s", cond.Type, cond.Status, joinReason(cond.Reason, cond.Message))},
+			})
+		}
+	}
+	if pod.Status.Phase == coreV1.PodFailed && pod.Status.Reason != "" {
+		d.add(Cause{
+			Reason:   pod.Status.Reason,
+			Score:    ScoreHigh,
+			Message:  "Pod 已失败",
+			Evidence: []string{joinReason(pod.Status.Reason, pod.Status.Message)},
+		})
+	}
+}
+
+func diagnoseContainers(d *Diagnosis, pod *coreV1.Pod) {
+	statuses := append(append([]coreV1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+	for _, cs := range statuses {
+		if waiting := cs.State.Waiting; waiting != nil {
+			evidence := fmt.Sprintf("container %s waiting: %s", cs.Name, joinReason(waiting.Reason, waiting.Message))
+			switch waiting.Reason {
+			case "ImagePullBackOff", "ErrImagePull", "InvalidImageName", "ErrImageNeverPull":
+				d.add(Cause{Reason: "ImagePullError", Score: ScoreDefinite, Message: fmt.Sprintf("容器 %s 镜像 %s 拉取失败", cs.Name, cs.Image), Evidence: []string{evidence}})
+			case "CreateContainerConfigError", "CreateContainerError":
+				d.add(Cause{Reason: waiting.Reason, Score: ScoreHigh, Message: fmt.Sprintf("容器 %s 创建失败", cs.Name), Evidence: []string{evidence}})
+			case "CrashLoopBackOff":
+				d.add(Cause{Reason: "CrashLoopBackOff", Score: ScoreHigh, Message: fmt.Sprintf("容器 %s 反复崩溃，已重启 %d 次", cs.Name, cs.RestartCount), Evidence: []string{evidence}})
+			}
+		}
+		for _, terminated := range []*coreV1.ContainerStateTerminated{cs.State.Terminated, cs.LastTerminationState.Terminated} {
+			if terminated == nil || terminated.ExitCode == 0 {
+				continue
+			}
+			evidence := fmt.Sprintf("container %s terminated: reason=%s exitCode=%d %s", cs.Name, terminated.Reason, terminated.ExitCode, terminated.Message)
+			if terminated.Reason == "OOMKilled" {
+				d.add(Cause{Reason: "OOMKilled", Score: ScoreDefinite, Message: fmt.Sprintf("容器 %s 内存超出限制被杀死", cs.Name), Evidence: []string{strings.TrimSpace(evidence)}})
+				continue
+			}
+			d.add(Cause{Reason: "ContainerExited", Score: ScoreMedium, Message: fmt.Sprintf("容器 %s 以非0状态码 %d 退出", cs.Name, terminated.ExitCode), Evidence: []string{strings.TrimSpace(evidence)}})
+		}
+	}
+}
+
+// podReference Pod 引用的外部资源
+type podReference struct {
+	resource Resource
+	name     string
+	optional bool
+	from     string
+}
+
+func podReferences(pod *coreV1.Pod) []podReference {
+	var refs []podReference
+	isOptional := func(b *bool) bool { return b != nil && *b }
+	for _, vol := range pod.Spec.Volumes {
+		from := "volume " + vol.Name
+		switch {
+		case vol.ConfigMap != nil:
+			refs = append(refs, podReference{CM, vol.ConfigMap.Name, isOptional(vol.ConfigMap.Optional), from})
+		case vol.Secret != nil:
+			refs = append(refs, podReference{SECRET, vol.Secret.SecretName, isOptional(vol.Secret.Optional), from})
+		case vol.PersistentVolumeClaim != nil:
+			refs = append(refs, podReference{PVC, vol.PersistentVolumeClaim.ClaimName, false, from})
+		case vol.Projected != nil:
+			for _, src := range vol.Projected.Sources {
+				if src.ConfigMap != nil {
+					refs = append(refs, podReference{CM, src.ConfigMap.Name, isOptional(src.ConfigMap.Optional), from})
+				}
+				if src.Secret != nil {
+					refs = append(refs, podReference{SECRET, src.Secret.Name, isOptional(src.Secret.Optional), from})
+				}
+			}
+		}
+	}
+	containers := append(append([]coreV1.Container{}, pod.Spec.InitContainers...), pod.Spec.Containers...)
+	for _, c := range containers {
+		from := "container " + c.Name
+		for _, env := range c.EnvFrom {
+			if env.ConfigMapRef != nil {
+				refs = append(refs, podReference{CM, env.ConfigMapRef.Name, isOptional(env.ConfigMapRef.Optional), from})
+			}
+			if env.SecretRef != nil {
+				refs = append(refs, podReference{SECRET, env.SecretRef.Name, isOptional(env.SecretRef.Optional), from})
+			}
+		}
+		for _, env := range c.Env {
+			if env.ValueFrom == nil {
+				continue
+			}
+			if ref := env.ValueFrom.ConfigMapKeyRef; ref != nil {
+				refs = append(refs, podReference{CM, ref.Name, isOptional(ref.Optional), from + " env " + env.Name})
+			}
+			if ref := env.ValueFrom.SecretKeyRef; ref != nil {
+				refs = append(refs, podReference{SECRET, ref.Name, isOptional(ref.Optional), from + " env " + env.Name})
+			}
+		}
+	}
+	for _, secret := range pod.Spec.ImagePullSecrets {
+		refs = append(refs, podReference{SECRET, secret.Name, false, "imagePullSecrets"})
+	}
+	return refs
+}
+
+// diagnoseReferences 检查 Pod 引用的资源是否存在。没有权限等原因无法查询时只作为低可信度的证据，不中断诊断
+func diagnoseReferences(ctx context.Context, d *Diagnosis, client kubernetes.Interface, pod *coreV1.Pod) {
+	checked := map[string]bool{}
+	for _, ref := range podReferences(pod) {
+		if ref.optional || checked[string(ref.resource)+"/"+ref.name] {
+			continue
+		}
+		checked[string(ref.resource)+"/"+ref.name] = true
+
+		var err error
+		switch ref.resource {
+		case CM:
+			_, err = client.CoreV1().ConfigMaps(pod.Namespace).Get(ctx, ref.name, metaV1.GetOptions{})
+		case SECRET:
+			_, err = client.CoreV1().Secrets(pod.Namespace).Get(ctx, ref.name, metaV1.GetOptions{})
+		case PVC:
+			var claim *coreV1.PersistentVolumeClaim
+			claim, err = client.CoreV1().PersistentVolumeClaims(pod.Namespace).Get(ctx, ref.name, metaV1.GetOptions{})
+			if err == nil && claim.Status.Phase != coreV1.ClaimBound {
+				d.add(Cause{
+					Reason:   "PVCNotBound",
+					Score:    ScoreHigh,
+					Message:  fmt.Sprintf("%s %s 未绑定", PVC, ref.name),
+					Evidence: []string{fmt.Sprintf("%s references %s %s in phase %s", ref.from, PVC, ref.name, claim.Status.Phase)},
+				})
+			}
+		}
+		if errors.IsNotFound(err) {
+			d.add(Cause{
+				Reason:   "Missing" + missingKind(ref.resource),
+				Score:    ScoreDefinite,
+				Message:  fmt.Sprintf("%s %s 不存在", ref.resource, ref.name),
+				Evidence: []string{fmt.Sprintf("%s references %s %s/%s", ref.from, ref.resource, pod.Namespace, ref.name)},
+			})
+		} else if err != nil {
+			d.add(Cause{
+				Reason:   "UnverifiedReference",
+				Score:    ScoreLow,
+				Message:  fmt.Sprintf("无法检查 %s %s", ref.resource, ref.name),
+				Evidence: []string{fmt.Sprintf("%s references %s %s/%s: %v", ref.from, ref.resource, pod.Namespace, ref.name, err)},
+			})
+		}
+	}
+}
+
+func missingKind(r Resource) string {
+	switch r {
+	case CM:
+		return "ConfigMap"
+	case SECRET:
+		return "Secret"
+	case PVC:
+		return "PersistentVolumeClaim"
+	}
+	return string(r)
+}
+
+// diagnoseEvents 根据 Pod 的告警事件推断原因。无法查询事件时只作为低可信度的证据，继续使用状态推断的原因
+func diagnoseEvents(ctx context.Context, d *Diagnosis, client kubernetes.Interface, pod *coreV1.Pod) {
+	selector := fields.Set{"involvedObject.kind": "Pod", "involvedObject.name": pod.Name}.AsSelector().String()
+	events, err := client.CoreV1().Events(pod.Namespace).List(ctx, metaV1.ListOptions{FieldSelector: selector})
+	if err != nil {
+		d.add(Cause{
+			Reason:   "EventsUnavailable",
+			Score:    ScoreLow,
+			Message:  "无法查询 Pod 的事件",
+			Evidence: []string{fmt.Sprintf("list %s %s/%s: %v", EVENT, pod.Namespace, pod.Name, err)},
+		})
+		return
+	}
+	for _, event := range events.Items {
+		if event.Type != coreV1.EventTypeWarning || event.InvolvedObject.Name != pod.Name {
+			continue
+		}
+		if pod.UID != "" && event.InvolvedObject.UID != "" && event.InvolvedObject.UID != pod.UID {
+			continue
+		}
+		evidence := fmt.Sprintf("event %s (x%d): %s", event.Reason, maxInt32(event.Count, 1), event.Message)
+		switch event.Reason {
+		case "FailedScheduling":
+			d.add(Cause{Reason: "Unschedulable", Score: ScoreHigh, Message: "Pod 无法被调度到任何节点", Evidence: []string{evidence}})
+		case "FailedMount", "FailedAttachVolume":
+			d.add(Cause{Reason: event.Reason, Score: ScoreHigh, Message: "存储卷挂载失败", Evidence: []string{evidence}})
+		case "Failed", "ErrImagePull", "ImagePullBackOff":
+			if strings.Contains(event.Message, "image") {
+				d.attach("ImagePullError", Cause{Reason: "ImagePullError", Score: ScoreHigh, Message: "镜像拉取失败", Evidence: []string{evidence}})
+			} else {
+				d.add(Cause{Reason: "WarningEvent", Score: ScoreLow, Message: "告警事件", Evidence: []string{evidence}})
+			}
+		case "BackOff":
+			d.attach("CrashLoopBackOff", Cause{Reason: "WarningEvent", Score: ScoreLow, Message: "告警事件", Evidence: []string{evidence}})
+		default:
+			d.add(Cause{Reason: "WarningEvent", Score: ScoreLow, Message: "告警事件", Evidence: []string{evidence}})
+		}
+	}
+}
+
+// attach 把证据追加到已有的同名原因上，不存在时作为新原因添加
+func (d *Diagnosis) attach(reason string, cause Cause) {
+	for i := range d.Causes {
+		if d.Causes[i].Reason == reason {
+			d.Causes[i].Evidence = append(d.Causes[i].Evidence, cause.Evidence...)
+			return
+		}
+	}
+	d.add(cause)
+}
+
+func maxInt32(a, b int32) int32 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// WriteDiagnosis
+//
+//	@Description: 以文本形式输出诊断结果
+//	@param w
+//	@param d
+//	@return error
+func WriteDiagnosis(w io.Writer, d *Diagnosis) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Pod %s/%s phase=%s ready=%t\n", d.Namespace, d.Name, d.Phase, d.Ready)
+	if len(d.Causes) == 0 {
+		b.WriteString("  未发现可能的原因\n")
+	}
+	for i, cause := range d.Causes {
+		fmt.Fprintf(&b, "  %d. [%d] %s: %s\n", i+1, cause.Score, cause.Reason, cause.Message)
+		for _, evidence := range cause.Evidence {
+			fmt.Fprintf(&b, "       - %s\n", evidence)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
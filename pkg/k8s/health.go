@@ -0,0 +1,323 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	appsV1 "k8s.io/api/apps/v1"
+	batchV1 "k8s.io/api/batch/v1"
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	FormatText     = "text"
+	FormatJSON     = "json"
+	FormatMarkdown = "markdown"
+)
+
+// DefaultTerminatingTimeout 命名空间处于 Terminating 超过该时长，视为卡住
+const DefaultTerminatingTimeout = 5 * time.Minute
+
+// HealthOptions 健康检查参数
+type HealthOptions struct {
+	// Namespace 检查的命名空间，为空时检查所有命名空间；节点和命名空间本身始终按集群范围检查
+	Namespace string
+	// TerminatingTimeout 命名空间删除超时时长，默认 DefaultTerminatingTimeout
+	TerminatingTimeout time.Duration
+}
+
+// HealthIssue 一条不健康记录
+type HealthIssue struct {
+	Resource  Resource `json:"resource"`
+	Namespace string   `json:"namespace,omitempty"`
+	Name      string   `json:"name"`
+	Status    string   `json:"status"`
+	Reason    string   `json:"reason,omitempty"`
+}
+
+// HealthReport 集群健康汇总
+type HealthReport struct {
+	GeneratedAt time.Time     `json:"generatedAt"`
+	Namespace   string        `json:"namespace,omitempty"`
+	Nodes       []HealthIssue `json:"nodes"`
+	Pods        []HealthIssue `json:"pods"`
+	Deployments []HealthIssue `json:"deployments"`
+	Jobs        []HealthIssue `json:"jobs"`
+	Namespaces  []HealthIssue `json:"namespaces"`
+}
+
+// Healthy 没有任何不健康记录时返回 true
+func (r *HealthReport) Healthy() bool {
+	return len(r.Nodes)+len(r.Pods)+len(r.Deployments)+len(r.Jobs)+len(r.Namespaces) == 0
+}
+
+func (r *HealthReport) sections() []struct {
+	title  string
+	issues []HealthIssue
+} {
+	return []struct {
+		title  string
+		issues []HealthIssue
+	}{
+		{"Nodes", r.Nodes},
+		{"Pods", r.Pods},
+		{"Deployments", r.Deployments},
+		{"Jobs", r.Jobs},
+		{"Namespaces", r.Namespaces},
+	}
+}
+
+// Health
+//
+//	@Description: 汇总集群健康状态：NotReady/资源压力节点、异常Pod、副本不足的Deployment、失败的Job以及卡在 Terminating 的命名空间
+//	@param ctx
+//	@param client
+//	@param opts
+//	@return *HealthReport
+//	@return error
+func Health(ctx context.Context, client kubernetes.Interface, opts HealthOptions) (*HealthReport, error) {
+	if opts.TerminatingTimeout <= 0 {
+		opts.TerminatingTimeout = DefaultTerminatingTimeout
+	}
+	now := time.Now()
+	report := &HealthReport{GeneratedAt: now, Namespace: opts.Namespace}
+
+	nodes, err := client.CoreV1().Nodes().List(ctx, metaV1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("list %s: %w", NODE, err)
+	}
+	for i := range nodes.Items {
+		report.Nodes = append(report.Nodes, nodeIssues(&nodes.Items[i])...)
+	}
+
+	pods, err := client.CoreV1().Pods(opts.Namespace).List(ctx, metaV1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("list %s: %w", POD, err)
+	}
+	for i := range pods.Items {
+		report.Pods = append(report.Pods, podIssues(&pods.Items[i])...)
+	}
+
+	deploys, err := client.AppsV1().Deployments(opts.Namespace).List(ctx, metaV1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("list %s: %w", DEPLOY, err)
+	}
+	for i := range deploys.Items {
+		if issue, ok := deploymentIssue(&deploys.Items[i]); ok {
+			report.Deployments = append(report.Deployments, issue)
+		}
+	}
+
+	jobs, err := client.BatchV1().Jobs(opts.Namespace).List(ctx, metaV1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("list %s: %w", JOB, err)
+	}
+	for i := range jobs.Items {
+		if issue, ok := jobIssue(&jobs.Items[i]); ok {
+			report.Jobs = append(report.Jobs, issue)
+		}
+	}
+
+	namespaces, err := client.CoreV1().Namespaces().List(ctx, metaV1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("list %s: %w", NS, err)
+	}
+	for i := range namespaces.Items {
+		if issue, ok := namespaceIssue(&namespaces.Items[i], now, opts.TerminatingTimeout); ok {
+			report.Namespaces = append(report.Namespaces, issue)
+		}
+	}
+	return report, nil
+}
+
+func nodeIssues(node *coreV1.Node) []HealthIssue {
+	var issues []HealthIssue
+	for _, cond := range node.Status.Conditions {
+		switch cond.Type {
+		case coreV1.NodeReady:
+			if cond.Status != coreV1.ConditionTrue {
+				issues = append(issues, HealthIssue{Resource: NODE, Name: node.Name, Status: "NotReady", Reason: joinReason(cond.Reason, cond.Message)})
+			}
+		case coreV1.NodeMemoryPressure, coreV1.NodeDiskPressure, coreV1.NodePIDPressure, coreV1.NodeNetworkUnavailable:
+			if cond.Status == coreV1.ConditionTrue {
+				issues = append(issues, HealthIssue{Resource: NODE, Name: node.Name, Status: string(cond.Type), Reason: joinReason(cond.Reason, cond.Message)})
+			}
+		}
+	}
+	return issues
+}
+
+func podIssues(pod *coreV1.Pod) []HealthIssue {
+	var issues []HealthIssue
+	statuses := append(append([]coreV1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+	for _, cs := range statuses {
+		if cs.State.Waiting == nil {
+			continue
+		}
+		switch cs.State.Waiting.Reason {
+		case "CrashLoopBackOff", "ImagePullBackOff", "ErrImagePull":
+			issues = append(issues, HealthIssue{
+				Resource:  POD,
+				Namespace: pod.Namespace,
+				Name:      pod.Name,
+				Status:    cs.State.Waiting.Reason,
+				Reason:    fmt.Sprintf("container %s: %s", cs.Name, cs.State.Waiting.Message),
+			})
+		}
+	}
+	if len(issues) == 0 && pod.Status.Phase == coreV1.PodPending {
+		issues = append(issues, HealthIssue{Resource: POD, Namespace: pod.Namespace, Name: pod.Name, Status: string(coreV1.PodPending), Reason: pendingReason(pod)})
+	}
+	return issues
+}
+
+func pendingReason(pod *coreV1.Pod) string {
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == coreV1.PodScheduled && cond.Status == coreV1.ConditionFalse {
+			return joinReason(cond.Reason, cond.Message)
+		}
+	}
+	for _, cs := range pod.Status.ContainerStatuses {
+		if cs.State.Waiting != nil && cs.State.Waiting.Reason != "" {
+			return joinReason(cs.State.Waiting.Reason, cs.State.Waiting.Message)
+		}
+	}
+	return joinReason(pod.Status.Reason, pod.Status.Message)
+}
+
+func deploymentIssue(deploy *appsV1.Deployment) (HealthIssue, bool) {
+	desired := int32(1)
+	if deploy.Spec.Replicas != nil {
+		desired = *deploy.Spec.Replicas
+	}
+	if deploy.Status.AvailableReplicas >= desired {
+		return HealthIssue{}, false
+	}
+	reason := fmt.Sprintf("%d/%d replicas available", deploy.Status.AvailableReplicas, desired)
+	for _, cond := range deploy.Status.Conditions {
+		if cond.Status != coreV1.ConditionTrue && cond.Message != "" {
+			reason = fmt.Sprintf("%s; %s", reason, cond.Message)
+		}
+	}
+	return HealthIssue{Resource: DEPLOY, Namespace: deploy.Namespace, Name: deploy.Name, Status: "Unavailable", Reason: reason}, true
+}
+
+func jobIssue(job *batchV1.Job) (HealthIssue, bool) {
+	for _, cond := range job.Status.Conditions {
+		if cond.Type == batchV1.JobFailed && cond.Status == coreV1.ConditionTrue {
+			return HealthIssue{Resource: JOB, Namespace: job.Namespace, Name: job.Name, Status: "Failed", Reason: joinReason(cond.Reason, cond.Message)}, true
+		}
+		if cond.Type == batchV1.JobComplete && cond.Status == coreV1.ConditionTrue {
+			return HealthIssue{}, false
+		}
+	}
+	if job.Status.Failed > 0 {
+		return HealthIssue{Resource: JOB, Namespace: job.Namespace, Name: job.Name, Status: "Failing", Reason: fmt.Sprintf("%d failed pods", job.Status.Failed)}, true
+	}
+	return HealthIssue{}, false
+}
+
+func namespaceIssue(ns *coreV1.Namespace, now time.Time, timeout time.Duration) (HealthIssue, bool) {
+	if ns.Status.Phase != coreV1.NamespaceTerminating || ns.DeletionTimestamp == nil {
+		return HealthIssue{}, false
+	}
+	age := now.Sub(ns.DeletionTimestamp.Time)
+	if age < timeout {
+		return HealthIssue{}, false
+	}
+	reason := fmt.Sprintf("terminating for %s", age.Round(time.Second))
+	for _, cond := range ns.Status.Conditions {
+		if cond.Status == coreV1.ConditionTrue && cond.Message != "" {
+			reason = fmt.Sprintf("%s; %s", reason, cond.Message)
+		}
+	}
+	return HealthIssue{Resource: NS, Name: ns.Name, Status: string(coreV1.NamespaceTerminating), Reason: reason}, true
+}
+
+func joinReason(reason, message string) string {
+	if reason == "" {
+		return message
+	}
+	if message == "" {
+		return reason
+	}
+	return reason + ": " + message
+}
+
+// WriteHealthReport
+//
+//	@Description: 以 text、json 或 markdown 格式输出健康报告
+//	@param w
+//	@param report
+//	@param format
+//	@return error
+func WriteHealthReport(w io.Writer, report *HealthReport, format string) error {
+	switch format {
+	case FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	case FormatMarkdown:
+		return writeHealthMarkdown(w, report)
+	case FormatText, "":
+		return writeHealthText(w, report)
+	default:
+		return fmt.Errorf("unsupported output format %q", format)
+	}
+}
+
+func writeHealthText(w io.Writer, report *HealthReport) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "Cluster health at %s\n", report.GeneratedAt.Format(time.RFC3339))
+	if report.Healthy() {
+		fmt.Fprintln(tw, "No issues found.")
+		return tw.Flush()
+	}
+	for _, section := range report.sections() {
+		fmt.Fprintf(tw, "\n%s (%d)\n", section.title, len(section.issues))
+		if len(section.issues) == 0 {
+			continue
+		}
+		fmt.Fprintln(tw, "NAMESPACE\tNAME\tSTATUS\tREASON")
+		for _, issue := range section.issues {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", orDash(issue.Namespace), issue.Name, issue.Status, orDash(issue.Reason))
+		}
+	}
+	return tw.Flush()
+}
+
+func writeHealthMarkdown(w io.Writer, report *HealthReport) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Cluster health\n\nGenerated at %s.\n", report.GeneratedAt.Format(time.RFC3339))
+	if report.Healthy() {
+		b.WriteString("\nNo issues found.\n")
+	}
+	for _, section := range report.sections() {
+		if len(section.issues) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "\n## %s (%d)\n\n| Namespace | Name | Status | Reason |\n| --- | --- | --- | --- |\n", section.title, len(section.issues))
+		for _, issue := range section.issues {
+			fmt.Fprintf(&b, "| %s | %s | %s | %s |\n", orDash(issue.Namespace), issue.Name, issue.Status, markdownEscape(orDash(issue.Reason)))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func markdownEscape(s string) string {
+	return strings.NewReplacer("|", "\\|", "\n", " ").Replace(s)
+}
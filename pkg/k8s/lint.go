@@ -0,0 +1,431 @@
+package k8s
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	appsV1 "k8s.io/api/apps/v1"
+	batchV1 "k8s.io/api/batch/v1"
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/kubernetes"
+	"os"
+	"sigs.k8s.io/yaml"
+	"strings"
+)
+
+type Severity string
+
+const (
+	SeverityError   = Severity("error")
+	SeverityWarning = Severity("warning")
+	SeverityInfo    = Severity("info")
+)
+
+// LintFinding 一条违反策略的记录
+type LintFinding struct {
+	Object   string   `json:"object"`
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	Path     string   `json:"path"`
+	Message  string   `json:"message"`
+}
+
+func (f LintFinding) String() string {
+	return fmt.Sprintf("%s [%s] %s: %s: %s", f.Severity, f.Rule, f.Object, f.Path, f.Message)
+}
+
+// Violation 规则检查的结果，Path 相对于 Pod 模板
+type Violation struct {
+	Path    string
+	Message string
+}
+
+// LintRule 一条针对 Pod 模板的检查规则
+type LintRule struct {
+	Name        string
+	Severity    Severity
+	Description string
+	Check       func(template *coreV1.PodTemplateSpec, config *LintConfig) []Violation
+}
+
+// LintRuleConfig 单条规则的配置
+type LintRuleConfig struct {
+	Disabled bool     `json:"disabled,omitempty"`
+	Severity Severity `json:"severity,omitempty"`
+}
+
+// LintConfig 策略配置，可以从 yaml/json 文件加载
+type LintConfig struct {
+	Rules map[string]LintRuleConfig `json:"rules,omitempty"`
+	// RequiredLabels 团队策略要求 Pod 模板必须携带的标签
+	RequiredLabels []string `json:"requiredLabels,omitempty"`
+}
+
+// LoadLintConfig
+//
+//	@Description: 从 yaml 或 json 文件加载策略配置
+//	@param path
+//	@return LintConfig
+//	@return error
+func LoadLintConfig(path string) (LintConfig, error) {
+	var config LintConfig
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return config, err
+	}
+	err = yaml.Unmarshal(data, &config)
+	return config, err
+}
+
+// DefaultLintRules 内置规则
+func DefaultLintRules() []LintRule {
+	return []LintRule{
+		{Name: "image-tag", Severity: SeverityError, Description: "镜像必须指定非 latest 的 tag 或 digest", Check: checkImageTag},
+		{Name: "resources", Severity: SeverityWarning, Description: "容器必须设置 cpu/memory 的 requests 和 limits", Check: checkResources},
+		{Name: "probes", Severity: SeverityWarning, Description: "长期运行的容器必须设置 liveness 和 readiness 探针，Job、CronJob 等批处理负载不检查", Check: checkProbes},
+		{Name: "run-as-non-root", Severity: SeverityError, Description: "容器必须以非 root 用户运行", Check: checkRunAsNonRoot},
+		{Name: "host-path", Severity: SeverityError, Description: "禁止使用 hostPath 存储卷", Check: checkHostPath},
+		{Name: "required-labels", Severity: SeverityWarning, Description: "Pod 模板必须携带策略要求的标签", Check: checkRequiredLabels},
+	}
+}
+
+// Linter 基于规则的 Pod 模板检查器
+type Linter struct {
+	config LintConfig
+	rules  []LintRule
+}
+
+// NewLinter 使用内置规则创建 Linter，规则可以通过 config 禁用或者修改级别
+func NewLinter(config LintConfig) *Linter {
+	return &Linter{config: config, rules: DefaultLintRules()}
+}
+
+// AddRule 添加自定义规则，同名规则会被覆盖
+func (l *Linter) AddRule(rule LintRule) {
+	for i := range l.rules {
+		if l.rules[i].Name == rule.Name {
+			l.rules[i] = rule
+			return
+		}
+	}
+	l.rules = append(l.rules, rule)
+}
+
+// Rules 返回当前启用的规则，级别已按配置调整
+func (l *Linter) Rules() []LintRule {
+	var rules []LintRule
+	for _, rule := range l.rules {
+		rc := l.config.Rules[rule.Name]
+		if rc.Disabled {
+			continue
+		}
+		if rc.Severity != "" {
+			rule.Severity = rc.Severity
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// LintTemplate
+//
+//	@Description: 检查一个 Pod 模板
+//	@param object: 结果中显示的对象标识
+//	@param prefix: Pod 模板在对象中的路径，例如 spec.template
+//	@param template
+//	@return []LintFinding
+func (l *Linter) LintTemplate(object, prefix string, template *coreV1.PodTemplateSpec) []LintFinding {
+	var findings []LintFinding
+	for _, rule := range l.Rules() {
+		for _, v := range rule.Check(template, &l.config) {
+			findings = append(findings, LintFinding{
+				Object:   object,
+				Rule:     rule.Name,
+				Severity: rule.Severity,
+				Path:     joinPath(prefix, v.Path),
+				Message:  v.Message,
+			})
+		}
+	}
+	return findings
+}
+
+// LintObject
+//
+//	@Description: 检查包含 Pod 模板的对象：Pod、Deployment、StatefulSet、DaemonSet、ReplicaSet、Job、CronJob，其他类型返回空
+//	@param obj
+//	@return []LintFinding
+//	@return error
+func (l *Linter) LintObject(obj runtime.Object) ([]LintFinding, error) {
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		typed, err := toTyped(u)
+		if err != nil || typed == nil {
+			return nil, err
+		}
+		obj = typed
+	}
+	var (
+		meta     metaV1.Object
+		kind     string
+		prefix   string
+		template *coreV1.PodTemplateSpec
+	)
+	switch o := obj.(type) {
+	case *coreV1.Pod:
+		meta, kind, template = o, "Pod", &coreV1.PodTemplateSpec{ObjectMeta: o.ObjectMeta, Spec: o.Spec}
+	case *appsV1.Deployment:
+		meta, kind, prefix, template = o, "Deployment", "spec.template", &o.Spec.Template
+	case *appsV1.StatefulSet:
+		meta, kind, prefix, template = o, "StatefulSet", "spec.template", &o.Spec.Template
+	case *appsV1.DaemonSet:
+		meta, kind, prefix, template = o, "DaemonSet", "spec.template", &o.Spec.Template
+	case *appsV1.ReplicaSet:
+		meta, kind, prefix, template = o, "ReplicaSet", "spec.template", &o.Spec.Template
+	case *batchV1.Job:
+		meta, kind, prefix, template = o, "Job", "spec.template", &o.Spec.Template
+	case *batchV1.CronJob:
+		meta, kind, prefix, template = o, "CronJob", "spec.jobTemplate.spec.template", &o.Spec.JobTemplate.Spec.Template
+	default:
+		return nil, nil
+	}
+	return l.LintTemplate(kind+" "+objectName(meta.GetNamespace(), meta.GetName()), prefix, template), nil
+}
+
+func toTyped(u *unstructured.Unstructured) (runtime.Object, error) {
+	var obj runtime.Object
+	switch u.GroupVersionKind().GroupKind().String() {
+	case "Pod":
+		obj = &coreV1.Pod{}
+	case "Deployment.apps":
+		obj = &appsV1.Deployment{}
+	case "StatefulSet.apps":
+		obj = &appsV1.StatefulSet{}
+	case "DaemonSet.apps":
+		obj = &appsV1.DaemonSet{}
+	case "ReplicaSet.apps":
+		obj = &appsV1.ReplicaSet{}
+	case "Job.batch":
+		obj = &batchV1.Job{}
+	case "CronJob.batch":
+		obj = &batchV1.CronJob{}
+	default:
+		return nil, nil
+	}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, obj); err != nil {
+		return nil, fmt.Errorf("convert %s %s: %w", u.GetKind(), u.GetName(), err)
+	}
+	return obj, nil
+}
+
+// LintManifest
+//
+//	@Description: 检查本地 yaml/json 清单，支持以 --- 分隔的多个文档
+//	@param data
+//	@return []LintFinding
+//	@return error
+func (l *Linter) LintManifest(data []byte) ([]LintFinding, error) {
+	var findings []LintFinding
+	decoder := utilyaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096)
+	for {
+		obj := &unstructured.Unstructured{}
+		if err := decoder.Decode(&obj.Object); err != nil {
+			if errors.Is(err, io.EOF) {
+				return findings, nil
+			}
+			return findings, err
+		}
+		if len(obj.Object) == 0 {
+			continue
+		}
+		result, err := l.LintObject(obj)
+		if err != nil {
+			return findings, err
+		}
+		findings = append(findings, result...)
+	}
+}
+
+// LintCluster
+//
+//	@Description: 检查集群中正在运行的工作负载，由控制器管理的 Pod 只检查其控制器
+//	@param ctx
+//	@param client
+//	@param namespace: 为空时检查所有命名空间
+//	@return []LintFinding
+//	@return error
+func (l *Linter) LintCluster(ctx context.Context, client kubernetes.Interface, namespace string) ([]LintFinding, error) {
+	var objects []runtime.Object
+	opts := metaV1.ListOptions{}
+
+	deploys, err := client.AppsV1().Deployments(namespace).List(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("list %s: %w", DEPLOY, err)
+	}
+	for i := range deploys.Items {
+		objects = append(objects, &deploys.Items[i])
+	}
+	statefulSets, err := client.AppsV1().StatefulSets(namespace).List(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("list statefulsets: %w", err)
+	}
+	for i := range statefulSets.Items {
+		objects = append(objects, &statefulSets.Items[i])
+	}
+	daemonSets, err := client.AppsV1().DaemonSets(namespace).List(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("list daemonsets: %w", err)
+	}
+	for i := range daemonSets.Items {
+		objects = append(objects, &daemonSets.Items[i])
+	}
+	cronJobs, err := client.BatchV1().CronJobs(namespace).List(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("list cronjobs: %w", err)
+	}
+	for i := range cronJobs.Items {
+		objects = append(objects, &cronJobs.Items[i])
+	}
+	jobs, err := client.BatchV1().Jobs(namespace).List(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("list %s: %w", JOB, err)
+	}
+	for i := range jobs.Items {
+		if len(jobs.Items[i].OwnerReferences) == 0 {
+			objects = append(objects, &jobs.Items[i])
+		}
+	}
+	pods, err := client.CoreV1().Pods(namespace).List(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("list %s: %w", POD, err)
+	}
+	for i := range pods.Items {
+		if len(pods.Items[i].OwnerReferences) == 0 {
+			objects = append(objects, &pods.Items[i])
+		}
+	}
+
+	var findings []LintFinding
+	for _, obj := range objects {
+		result, err := l.LintObject(obj)
+		if err != nil {
+			return nil, err
+		}
+		findings = append(findings, result...)
+	}
+	return findings, nil
+}
+
+func allContainers(spec *coreV1.PodSpec) ([]coreV1.Container, []string) {
+	var (
+		containers []coreV1.Container
+		paths      []string
+	)
+	for i, c := range spec.InitContainers {
+		containers = append(containers, c)
+		paths = append(paths, fmt.Sprintf("spec.initContainers[%d]", i))
+	}
+	for i, c := range spec.Containers {
+		containers = append(containers, c)
+		paths = append(paths, fmt.Sprintf("spec.containers[%d]", i))
+	}
+	return containers, paths
+}
+
+func checkImageTag(template *coreV1.PodTemplateSpec, _ *LintConfig) []Violation {
+	var violations []Violation
+	containers, paths := allContainers(&template.Spec)
+	for i, c := range containers {
+		if strings.Contains(c.Image, "@") {
+			continue
+		}
+		// 去掉仓库地址中的端口，例如 registry:5000/nginx
+		name := c.Image[strings.LastIndex(c.Image, "/")+1:]
+		tag := ""
+		if idx := strings.LastIndex(name, ":"); idx >= 0 {
+			tag = name[idx+1:]
+		}
+		switch tag {
+		case "":
+			violations = append(violations, Violation{paths[i] + ".image", fmt.Sprintf("镜像 %q 没有指定 tag", c.Image)})
+		case "latest":
+			violations = append(violations, Violation{paths[i] + ".image", fmt.Sprintf("镜像 %q 使用了 latest tag", c.Image)})
+		}
+	}
+	return violations
+}
+
+func checkResources(template *coreV1.PodTemplateSpec, _ *LintConfig) []Violation {
+	var violations []Violation
+	containers, paths := allContainers(&template.Spec)
+	for i, c := range containers {
+		for _, name := range []coreV1.ResourceName{coreV1.ResourceCPU, coreV1.ResourceMemory} {
+			if _, ok := c.Resources.Requests[name]; !ok {
+				violations = append(violations, Violation{paths[i] + ".resources.requests." + string(name), fmt.Sprintf("容器 %s 没有设置 %s request", c.Name, name)})
+			}
+			if _, ok := c.Resources.Limits[name]; !ok {
+				violations = append(violations, Violation{paths[i] + ".resources.limits." + string(name), fmt.Sprintf("容器 %s 没有设置 %s limit", c.Name, name)})
+			}
+		}
+	}
+	return violations
+}
+
+func checkProbes(template *coreV1.PodTemplateSpec, _ *LintConfig) []Violation {
+	// Job、CronJob 的 Pod 模板必须使用 Never 或 OnFailure，运行结束即退出，不需要探针
+	if policy := template.Spec.RestartPolicy; policy == coreV1.RestartPolicyNever || policy == coreV1.RestartPolicyOnFailure {
+		return nil
+	}
+	var violations []Violation
+	for i, c := range template.Spec.Containers {
+		path := fmt.Sprintf("spec.containers[%d]", i)
+		if c.LivenessProbe == nil {
+			violations = append(violations, Violation{path + ".livenessProbe", fmt.Sprintf("容器 %s 没有设置 liveness 探针", c.Name)})
+		}
+		if c.ReadinessProbe == nil {
+			violations = append(violations, Violation{path + ".readinessProbe", fmt.Sprintf("容器 %s 没有设置 readiness 探针", c.Name)})
+		}
+	}
+	return violations
+}
+
+func checkRunAsNonRoot(template *coreV1.PodTemplateSpec, _ *LintConfig) []Violation {
+	podNonRoot := template.Spec.SecurityContext != nil && template.Spec.SecurityContext.RunAsNonRoot != nil && *template.Spec.SecurityContext.RunAsNonRoot
+	var violations []Violation
+	containers, paths := allContainers(&template.Spec)
+	for i, c := range containers {
+		nonRoot := podNonRoot
+		if c.SecurityContext != nil && c.SecurityContext.RunAsNonRoot != nil {
+			nonRoot = *c.SecurityContext.RunAsNonRoot
+		}
+		if !nonRoot {
+			violations = append(violations, Violation{paths[i] + ".securityContext.runAsNonRoot", fmt.Sprintf("容器 %s 没有设置 runAsNonRoot", c.Name)})
+		}
+	}
+	return violations
+}
+
+func checkHostPath(template *coreV1.PodTemplateSpec, _ *LintConfig) []Violation {
+	var violations []Violation
+	for i, vol := range template.Spec.Volumes {
+		if vol.HostPath != nil {
+			violations = append(violations, Violation{fmt.Sprintf("spec.volumes[%d].hostPath", i), fmt.Sprintf("存储卷 %s 挂载了宿主机路径 %s", vol.Name, vol.HostPath.Path)})
+		}
+	}
+	return violations
+}
+
+func checkRequiredLabels(template *coreV1.PodTemplateSpec, config *LintConfig) []Violation {
+	var violations []Violation
+	for _, label := range config.RequiredLabels {
+		if _, ok := template.Labels[label]; !ok {
+			violations = append(violations, Violation{"metadata.labels", fmt.Sprintf("缺少标签 %s", label)})
+		}
+	}
+	return violations
+}
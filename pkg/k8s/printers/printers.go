@@ -0,0 +1,155 @@
+package printers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/yaml"
+	"strings"
+)
+
+// Printer 把对象或对象列表输出到 w
+type Printer interface {
+	PrintObj(obj runtime.Object, w io.Writer) error
+}
+
+// PrinterFunc 函数形式的 Printer
+type PrinterFunc func(obj runtime.Object, w io.Writer) error
+
+func (f PrinterFunc) PrintObj(obj runtime.Object, w io.Writer) error {
+	return f(obj, w)
+}
+
+// New
+//
+//	@Description: 根据 kubectl -o 风格的格式创建 Printer，支持
+//	table(默认)、wide、json、yaml、name、custom-columns=<spec>、jsonpath=<template>、go-template=<template>
+//	@param format
+//	@return Printer
+//	@return error
+func New(format string) (Printer, error) {
+	name, arg, _ := strings.Cut(format, "=")
+	switch name {
+	case "", "table":
+		return &TablePrinter{}, nil
+	case "wide":
+		return &TablePrinter{Wide: true}, nil
+	case "json":
+		return PrinterFunc(printJSON), nil
+	case "yaml":
+		return PrinterFunc(printYAML), nil
+	case "name":
+		return PrinterFunc(printName), nil
+	case "custom-columns":
+		return NewCustomColumnsPrinter(arg)
+	case "jsonpath":
+		return NewJSONPathPrinter(arg)
+	case "go-template":
+		return NewGoTemplatePrinter(arg)
+	}
+	return nil, fmt.Errorf("unsupported output format %q", format)
+}
+
+// withKind 为 clientset 返回的类型化对象补全 apiVersion/kind
+func withKind(obj runtime.Object) runtime.Object {
+	if !obj.GetObjectKind().GroupVersionKind().Empty() {
+		return obj
+	}
+	kinds, _, err := scheme.Scheme.ObjectKinds(obj)
+	if err != nil || len(kinds) == 0 {
+		return obj
+	}
+	obj = obj.DeepCopyObject()
+	obj.GetObjectKind().SetGroupVersionKind(kinds[0])
+	if meta.IsListType(obj) {
+		items, err := meta.ExtractList(obj)
+		if err == nil {
+			for i := range items {
+				items[i] = withKind(items[i])
+			}
+			_ = meta.SetList(obj, items)
+		}
+	}
+	return obj
+}
+
+func printJSON(obj runtime.Object, w io.Writer) error {
+	data, err := json.MarshalIndent(withKind(obj), "", "    ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
+
+func printYAML(obj runtime.Object, w io.Writer) error {
+	data, err := yaml.Marshal(withKind(obj))
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+func printName(obj runtime.Object, w io.Writer) error {
+	if table, ok := obj.(*metaV1.Table); ok {
+		for _, row := range table.Rows {
+			if len(row.Cells) > 0 {
+				if _, err := fmt.Fprintln(w, row.Cells[0]); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	items, err := toItems(obj)
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		if _, err := fmt.Fprintf(w, "%s/%s\n", strings.ToLower(item.GetKind()), item.GetName()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// toItems 把对象或对象列表转换成非结构化对象列表
+func toItems(obj runtime.Object) ([]*unstructured.Unstructured, error) {
+	objects := []runtime.Object{obj}
+	if meta.IsListType(obj) {
+		var err error
+		if objects, err = meta.ExtractList(obj); err != nil {
+			return nil, err
+		}
+	}
+	items := make([]*unstructured.Unstructured, 0, len(objects))
+	for _, o := range objects {
+		if u, ok := o.(*unstructured.Unstructured); ok {
+			items = append(items, u)
+			continue
+		}
+		content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(withKind(o))
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, &unstructured.Unstructured{Object: content})
+	}
+	return items, nil
+}
+
+// toData 把整个对象转换为 jsonpath/模板使用的通用数据
+func toData(obj runtime.Object) (interface{}, error) {
+	data, err := json.Marshal(withKind(obj))
+	if err != nil {
+		return nil, err
+	}
+	var out interface{}
+	err = json.Unmarshal(data, &out)
+	return out, err
+}
@@ -0,0 +1,347 @@
+package printers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"k8s.io/client-go/rest"
+	"path"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// tableAcceptHeader 请求 apiserver 以 Table 格式返回，不支持时退回普通的 json
+const tableAcceptHeader = "application/json;as=Table;v=v1;g=meta.k8s.io,application/json"
+
+// ResourceNamespace
+//
+//	@Description: 根据资源的作用域确定查询的命名空间，集群级别的资源（nodes、namespaces 等）返回空
+//	@param mapper
+//	@param gvr
+//	@param namespace
+//	@return string
+//	@return bool: 资源是否属于命名空间
+//	@return error
+func ResourceNamespace(mapper meta.RESTMapper, gvr schema.GroupVersionResource, namespace string) (string, bool, error) {
+	gvk, err := mapper.KindFor(gvr)
+	if err != nil {
+		return "", false, err
+	}
+	mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return "", false, err
+	}
+	if mapping.Scope.Name() == meta.RESTScopeNameRoot {
+		return "", false, nil
+	}
+	return namespace, true, nil
+}
+
+// GetTable
+//
+//	@Description: 以服务端 Table 格式查询资源，列与 kubectl get 一致
+//	@param ctx
+//	@param client: 任意 rest 客户端，例如 clientset.CoreV1().RESTClient()
+//	@param gvr
+//	@param namespace: 为空时查询所有命名空间，集群级别的资源必须为空
+//	@param opts
+//	@return runtime.Object: 服务端支持 Table 时返回 *metaV1.Table，否则返回 *unstructured.UnstructuredList
+//	@return error
+func GetTable(ctx context.Context, client rest.Interface, gvr schema.GroupVersionResource, namespace string, opts metaV1.ListOptions) (runtime.Object, error) {
+	segments := []string{"/apis", gvr.Group, gvr.Version}
+	if gvr.Group == "" {
+		segments = []string{"/api", gvr.Version}
+	}
+	if namespace != "" {
+		segments = append(segments, "namespaces", namespace)
+	}
+	segments = append(segments, gvr.Resource)
+
+	data, err := client.Get().
+		AbsPath(path.Join(segments...)).
+		SetHeader("Accept", tableAcceptHeader).
+		VersionedParams(&opts, metaV1.ParameterCodec).
+		Do(ctx).
+		Raw()
+	if err != nil {
+		return nil, err
+	}
+	var typeMeta metaV1.TypeMeta
+	if err := json.Unmarshal(data, &typeMeta); err != nil {
+		return nil, err
+	}
+	if typeMeta.Kind == "Table" {
+		table := &metaV1.Table{}
+		return table, json.Unmarshal(data, table)
+	}
+	list := &unstructured.UnstructuredList{}
+	return list, list.UnmarshalJSON(data)
+}
+
+// TablePrinter 以表格输出，*metaV1.Table 使用服务端返回的列，其他对象使用本地定义的列
+type TablePrinter struct {
+	// Wide 输出所有列，与 kubectl -o wide 一致
+	Wide bool
+	// NoHeaders 不输出表头
+	NoHeaders bool
+	// WithNamespace 输出 NAMESPACE 列
+	WithNamespace bool
+}
+
+func (p *TablePrinter) PrintObj(obj runtime.Object, w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 3, ' ', 0)
+	var err error
+	if table, ok := obj.(*metaV1.Table); ok {
+		err = p.printTable(table, tw)
+	} else {
+		err = p.printLocal(obj, tw)
+	}
+	if err != nil {
+		return err
+	}
+	return tw.Flush()
+}
+
+func (p *TablePrinter) printTable(table *metaV1.Table, w io.Writer) error {
+	var columns []int
+	var headers []string
+	if p.WithNamespace {
+		headers = append(headers, "NAMESPACE")
+	}
+	for i, col := range table.ColumnDefinitions {
+		if col.Priority == 0 || p.Wide {
+			columns = append(columns, i)
+			headers = append(headers, strings.ToUpper(col.Name))
+		}
+	}
+	if !p.NoHeaders {
+		fmt.Fprintln(w, strings.Join(headers, "\t"))
+	}
+	for _, row := range table.Rows {
+		var cells []string
+		if p.WithNamespace {
+			cells = append(cells, rowNamespace(row))
+		}
+		for _, i := range columns {
+			if i < len(row.Cells) {
+				cells = append(cells, cellString(row.Cells[i]))
+			}
+		}
+		fmt.Fprintln(w, strings.Join(cells, "\t"))
+	}
+	return nil
+}
+
+func rowNamespace(row metaV1.TableRow) string {
+	if row.Object.Raw == nil {
+		return ""
+	}
+	var partial metaV1.PartialObjectMetadata
+	if err := json.Unmarshal(row.Object.Raw, &partial); err != nil {
+		return ""
+	}
+	return partial.Namespace
+}
+
+func cellString(cell interface{}) string {
+	if cell == nil {
+		return "<none>"
+	}
+	// json 数字解码为 float64，整数按整数输出
+	if f, ok := cell.(float64); ok && f == float64(int64(f)) {
+		return fmt.Sprint(int64(f))
+	}
+	return fmt.Sprint(cell)
+}
+
+func (p *TablePrinter) printLocal(obj runtime.Object, w io.Writer) error {
+	items, err := toItems(obj)
+	if err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		return nil
+	}
+	var columns []column
+	for _, col := range append(append([]column{nameColumn}, kindColumns[items[0].GetKind()]...), ageColumn) {
+		if !col.wide || p.Wide {
+			columns = append(columns, col)
+		}
+	}
+	if !p.NoHeaders {
+		headers := make([]string, 0, len(columns)+1)
+		if p.WithNamespace {
+			headers = append(headers, "NAMESPACE")
+		}
+		for _, col := range columns {
+			headers = append(headers, col.name)
+		}
+		fmt.Fprintln(w, strings.Join(headers, "\t"))
+	}
+	for _, item := range items {
+		cells := make([]string, 0, len(columns)+1)
+		if p.WithNamespace {
+			cells = append(cells, item.GetNamespace())
+		}
+		for _, col := range columns {
+			cells = append(cells, orNone(col.value(item)))
+		}
+		fmt.Fprintln(w, strings.Join(cells, "\t"))
+	}
+	return nil
+}
+
+// column 本地表格的一列，wide 为 true 时只在 -o wide 中输出
+type column struct {
+	name  string
+	wide  bool
+	value func(u *unstructured.Unstructured) string
+}
+
+var (
+	nameColumn = column{name: "NAME", value: func(u *unstructured.Unstructured) string { return u.GetName() }}
+	ageColumn  = column{name: "AGE", value: func(u *unstructured.Unstructured) string {
+		created := u.GetCreationTimestamp()
+		if created.IsZero() {
+			return ""
+		}
+		return duration.HumanDuration(time.Since(created.Time))
+	}}
+)
+
+var kindColumns = map[string][]column{
+	"Pod": {
+		{name: "READY", value: podReady},
+		{name: "STATUS", value: podStatus},
+		{name: "RESTARTS", value: podRestarts},
+		{name: "IP", wide: true, value: field("status", "podIP")},
+		{name: "NODE", wide: true, value: field("spec", "nodeName")},
+	},
+	"Deployment": {
+		{name: "READY", value: func(u *unstructured.Unstructured) string {
+			return fmt.Sprintf("%s/%s", orZero(field("status", "readyReplicas")(u)), orZero(field("spec", "replicas")(u)))
+		}},
+		{name: "UP-TO-DATE", value: func(u *unstructured.Unstructured) string { return orZero(field("status", "updatedReplicas")(u)) }},
+		{name: "AVAILABLE", value: func(u *unstructured.Unstructured) string { return orZero(field("status", "availableReplicas")(u)) }},
+		{name: "CONTAINERS", wide: true, value: containerField("name")},
+		{name: "IMAGES", wide: true, value: containerField("image")},
+	},
+	"Service": {
+		{name: "TYPE", value: field("spec", "type")},
+		{name: "CLUSTER-IP", value: field("spec", "clusterIP")},
+		{name: "PORT(S)", value: servicePorts},
+		{name: "SELECTOR", wide: true, value: func(u *unstructured.Unstructured) string {
+			selector, _, _ := unstructured.NestedStringMap(u.Object, "spec", "selector")
+			return metaV1.FormatLabelSelector(&metaV1.LabelSelector{MatchLabels: selector})
+		}},
+	},
+}
+
+func field(fields ...string) func(u *unstructured.Unstructured) string {
+	return func(u *unstructured.Unstructured) string {
+		value, ok, _ := unstructured.NestedFieldNoCopy(u.Object, fields...)
+		if !ok || value == nil {
+			return ""
+		}
+		return fmt.Sprint(value)
+	}
+}
+
+func containerField(name string) func(u *unstructured.Unstructured) string {
+	return func(u *unstructured.Unstructured) string {
+		containers, _, _ := unstructured.NestedSlice(u.Object, "spec", "template", "spec", "containers")
+		values := make([]string, 0, len(containers))
+		for _, c := range containers {
+			if m, ok := c.(map[string]interface{}); ok {
+				values = append(values, fmt.Sprint(m[name]))
+			}
+		}
+		return strings.Join(values, ",")
+	}
+}
+
+func podReady(u *unstructured.Unstructured) string {
+	containers, _, _ := unstructured.NestedSlice(u.Object, "spec", "containers")
+	statuses, _, _ := unstructured.NestedSlice(u.Object, "status", "containerStatuses")
+	ready := 0
+	for _, s := range statuses {
+		if m, ok := s.(map[string]interface{}); ok && m["ready"] == true {
+			ready++
+		}
+	}
+	return fmt.Sprintf("%d/%d", ready, len(containers))
+}
+
+func podStatus(u *unstructured.Unstructured) string {
+	if u.GetDeletionTimestamp() != nil {
+		return "Terminating"
+	}
+	statuses, _, _ := unstructured.NestedSlice(u.Object, "status", "containerStatuses")
+	for _, s := range statuses {
+		if reason, ok, _ := unstructured.NestedString(s.(map[string]interface{}), "state", "waiting", "reason"); ok && reason != "" {
+			return reason
+		}
+		if reason, ok, _ := unstructured.NestedString(s.(map[string]interface{}), "state", "terminated", "reason"); ok && reason != "" {
+			return reason
+		}
+	}
+	if reason := field("status", "reason")(u); reason != "" {
+		return reason
+	}
+	return field("status", "phase")(u)
+}
+
+func podRestarts(u *unstructured.Unstructured) string {
+	statuses, _, _ := unstructured.NestedSlice(u.Object, "status", "containerStatuses")
+	var restarts int64
+	for _, s := range statuses {
+		if count, ok, _ := unstructured.NestedFieldNoCopy(s.(map[string]interface{}), "restartCount"); ok {
+			switch n := count.(type) {
+			case int64:
+				restarts += n
+			case float64:
+				restarts += int64(n)
+			}
+		}
+	}
+	return fmt.Sprint(restarts)
+}
+
+func servicePorts(u *unstructured.Unstructured) string {
+	ports, _, _ := unstructured.NestedSlice(u.Object, "spec", "ports")
+	values := make([]string, 0, len(ports))
+	for _, p := range ports {
+		m, ok := p.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		value := fmt.Sprintf("%v/%v", m["port"], m["protocol"])
+		if nodePort, ok := m["nodePort"]; ok {
+			value = fmt.Sprintf("%v:%v/%v", m["port"], nodePort, m["protocol"])
+		}
+		values = append(values, value)
+	}
+	return strings.Join(values, ",")
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "<none>"
+	}
+	return s
+}
+
+func orZero(s string) string {
+	if s == "" {
+		return "0"
+	}
+	return s
+}
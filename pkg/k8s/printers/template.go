@@ -0,0 +1,156 @@
+package printers
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/util/jsonpath"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+)
+
+var relaxedJSONPath = regexp.MustCompile(`^\{?(\.?[^{}]*)\}?$`)
+
+// relaxedJSONPathExpression 与 kubectl 一致，允许省略 {} 和开头的 .
+func relaxedJSONPathExpression(expression string) (string, error) {
+	if strings.Contains(expression, "{") && !strings.HasPrefix(expression, "{") {
+		return expression, nil
+	}
+	submatches := relaxedJSONPath.FindStringSubmatch(expression)
+	if submatches == nil {
+		return "", fmt.Errorf("unexpected path string, expected a 'name1.name2' or '.name1.name2' or '{name1.name2}' or '{.name1.name2}'")
+	}
+	path := submatches[1]
+	if !strings.HasPrefix(path, ".") {
+		path = "." + path
+	}
+	return "{" + path + "}", nil
+}
+
+// JSONPathPrinter 使用 jsonpath 模板输出整个对象，例如 {.items[*].metadata.name}
+type JSONPathPrinter struct {
+	template string
+	parser   *jsonpath.JSONPath
+}
+
+func NewJSONPathPrinter(tmpl string) (*JSONPathPrinter, error) {
+	parser := jsonpath.New("out").AllowMissingKeys(true)
+	if err := parser.Parse(tmpl); err != nil {
+		return nil, fmt.Errorf("parse jsonpath %q: %w", tmpl, err)
+	}
+	return &JSONPathPrinter{template: tmpl, parser: parser}, nil
+}
+
+func (p *JSONPathPrinter) PrintObj(obj runtime.Object, w io.Writer) error {
+	data, err := toData(obj)
+	if err != nil {
+		return err
+	}
+	if err := p.parser.Execute(w, data); err != nil {
+		return fmt.Errorf("execute jsonpath %q: %w", p.template, err)
+	}
+	return nil
+}
+
+// CustomColumnsPrinter 按 NAME:.metadata.name,IMAGE:.spec.containers[*].image 形式的列定义输出表格
+type CustomColumnsPrinter struct {
+	NoHeaders bool
+	headers   []string
+	parsers   []*jsonpath.JSONPath
+}
+
+func NewCustomColumnsPrinter(spec string) (*CustomColumnsPrinter, error) {
+	if spec == "" {
+		return nil, fmt.Errorf("custom-columns format specified but no custom columns given")
+	}
+	p := &CustomColumnsPrinter{}
+	for _, part := range strings.Split(spec, ",") {
+		header, expression, ok := strings.Cut(part, ":")
+		if !ok {
+			return nil, fmt.Errorf("unexpected custom-columns spec: %s, expected <header>:<json-path-expr>", part)
+		}
+		expression, err := relaxedJSONPathExpression(expression)
+		if err != nil {
+			return nil, err
+		}
+		parser := jsonpath.New(header).AllowMissingKeys(true)
+		if err := parser.Parse(expression); err != nil {
+			return nil, fmt.Errorf("parse column %s: %w", header, err)
+		}
+		p.headers = append(p.headers, header)
+		p.parsers = append(p.parsers, parser)
+	}
+	return p, nil
+}
+
+func (p *CustomColumnsPrinter) PrintObj(obj runtime.Object, w io.Writer) error {
+	items, err := toItems(obj)
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 5, 8, 3, ' ', 0)
+	if !p.NoHeaders {
+		fmt.Fprintln(tw, strings.Join(p.headers, "\t"))
+	}
+	for _, item := range items {
+		cells := make([]string, 0, len(p.parsers))
+		for _, parser := range p.parsers {
+			results, err := parser.FindResults(item.Object)
+			if err != nil {
+				return err
+			}
+			var values []string
+			for _, result := range results {
+				for _, value := range result {
+					values = append(values, fmt.Sprint(value.Interface()))
+				}
+			}
+			cells = append(cells, orNone(strings.Join(values, ",")))
+		}
+		fmt.Fprintln(tw, strings.Join(cells, "\t"))
+	}
+	return tw.Flush()
+}
+
+// GoTemplatePrinter 使用 text/template 输出整个对象
+type GoTemplatePrinter struct {
+	template *template.Template
+}
+
+func NewGoTemplatePrinter(tmpl string) (*GoTemplatePrinter, error) {
+	t, err := template.New("output").Funcs(template.FuncMap{"exists": exists}).Parse(tmpl)
+	if err != nil {
+		return nil, fmt.Errorf("parse go-template: %w", err)
+	}
+	return &GoTemplatePrinter{template: t}, nil
+}
+
+func (p *GoTemplatePrinter) PrintObj(obj runtime.Object, w io.Writer) error {
+	data, err := toData(obj)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := p.template.Execute(&buf, data); err != nil {
+		return fmt.Errorf("execute go-template: %w", err)
+	}
+	_, err = buf.WriteTo(w)
+	return err
+}
+
+// exists 模板函数，判断嵌套的 map 字段是否存在，例如 {{if exists . "metadata" "labels"}}
+func exists(data interface{}, fields ...string) bool {
+	for _, name := range fields {
+		m, ok := data.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		if data, ok = m[name]; !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -11,4 +11,11 @@ const (
 	POD              = Resource("pods")
 	SVC              = Resource("services")
 	DEPLOY           = Resource("deployments")
+	NODE             = Resource("nodes")
+	NS               = Resource("namespaces")
+	JOB              = Resource("jobs")
+	CM               = Resource("configmaps")
+	SECRET           = Resource("secrets")
+	PVC              = Resource("persistentvolumeclaims")
+	EVENT            = Resource("events")
 )
@@ -0,0 +1,217 @@
+package k8s
+
+import (
+	"encoding/json"
+	"fmt"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"os"
+	"sigs.k8s.io/yaml"
+	"strings"
+)
+
+const (
+	schemaRefPrefix  = "#/components/schemas/"
+	objectMetaSchema = "io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta"
+)
+
+// Schema OpenAPI v3 schema 中校验所需的字段，同时兼容 CRD 的 openAPIV3Schema
+type Schema struct {
+	Ref                   string                    `json:"$ref,omitempty"`
+	Type                  string                    `json:"type,omitempty"`
+	Format                string                    `json:"format,omitempty"`
+	Properties            map[string]*Schema        `json:"properties,omitempty"`
+	AdditionalProperties  *SchemaOrBool             `json:"additionalProperties,omitempty"`
+	Items                 *Schema                   `json:"items,omitempty"`
+	Required              []string                  `json:"required,omitempty"`
+	Enum                  []interface{}             `json:"enum,omitempty"`
+	Nullable              bool                      `json:"nullable,omitempty"`
+	AllOf                 []*Schema                 `json:"allOf,omitempty"`
+	AnyOf                 []*Schema                 `json:"anyOf,omitempty"`
+	OneOf                 []*Schema                 `json:"oneOf,omitempty"`
+	PreserveUnknownFields bool                      `json:"x-kubernetes-preserve-unknown-fields,omitempty"`
+	IntOrString           bool                      `json:"x-kubernetes-int-or-string,omitempty"`
+	EmbeddedResource      bool                      `json:"x-kubernetes-embedded-resource,omitempty"`
+	GroupVersionKinds     []schema.GroupVersionKind `json:"x-kubernetes-group-version-kind,omitempty"`
+}
+
+// SchemaOrBool additionalProperties 既可以是 bool，也可以是 schema
+type SchemaOrBool struct {
+	Allows bool
+	Schema *Schema
+}
+
+func (s *SchemaOrBool) UnmarshalJSON(data []byte) error {
+	var allows bool
+	if err := json.Unmarshal(data, &allows); err == nil {
+		s.Allows = allows
+		return nil
+	}
+	s.Allows = true
+	return json.Unmarshal(data, &s.Schema)
+}
+
+func (s SchemaOrBool) MarshalJSON() ([]byte, error) {
+	if s.Schema != nil {
+		return json.Marshal(s.Schema)
+	}
+	return json.Marshal(s.Allows)
+}
+
+// openAPIDocument OpenAPI v3 文档中用到的部分
+type openAPIDocument struct {
+	OpenAPI    string `json:"openapi,omitempty"`
+	Components struct {
+		Schemas map[string]*Schema `json:"schemas"`
+	} `json:"components"`
+}
+
+// SchemaSet 按 GVK 索引的 OpenAPI v3 schema 集合，可以从集群获取一次后保存到本地离线使用
+type SchemaSet struct {
+	schemas map[string]*Schema
+	kinds   map[schema.GroupVersionKind]string
+}
+
+// NewSchemaSet 创建一个空的 SchemaSet
+func NewSchemaSet() *SchemaSet {
+	return &SchemaSet{
+		schemas: map[string]*Schema{},
+		kinds:   map[schema.GroupVersionKind]string{},
+	}
+}
+
+// FetchSchemas
+//
+//	@Description: 通过 discovery 获取集群所有 GroupVersion 的 OpenAPI v3 schema（包括已安装的 CRD）
+//	@param client
+//	@return *SchemaSet
+//	@return error
+func FetchSchemas(client discovery.OpenAPIV3SchemaInterface) (*SchemaSet, error) {
+	paths, err := client.OpenAPIV3().Paths()
+	if err != nil {
+		return nil, fmt.Errorf("discover openapi v3 paths: %w", err)
+	}
+	set := NewSchemaSet()
+	for path, gv := range paths {
+		data, err := gv.Schema(runtime.ContentTypeJSON)
+		if err != nil {
+			return nil, fmt.Errorf("fetch openapi v3 schema %s: %w", path, err)
+		}
+		if err := set.AddDocument(data); err != nil {
+			return nil, fmt.Errorf("parse openapi v3 schema %s: %w", path, err)
+		}
+	}
+	return set, nil
+}
+
+// LoadSchemas
+//
+//	@Description: 从本地文件加载 SchemaSet，文件为 SaveSchemas 保存的内容或者任意 OpenAPI v3 文档
+//	@param path
+//	@return *SchemaSet
+//	@return error
+func LoadSchemas(path string) (*SchemaSet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	set := NewSchemaSet()
+	if err := set.AddDocument(data); err != nil {
+		return nil, fmt.Errorf("load %s: %w", path, err)
+	}
+	return set, nil
+}
+
+// Save 把 SchemaSet 以 OpenAPI v3 文档的格式保存到本地文件
+func (s *SchemaSet) Save(path string) error {
+	doc := openAPIDocument{OpenAPI: "3.0.0"}
+	doc.Components.Schemas = s.schemas
+	data, err := json.Marshal(&doc)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// AddDocument 合并一个 OpenAPI v3 文档中的所有 schema
+func (s *SchemaSet) AddDocument(data []byte) error {
+	var doc openAPIDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	for name, sch := range doc.Components.Schemas {
+		s.add(name, sch)
+	}
+	return nil
+}
+
+func (s *SchemaSet) add(name string, sch *Schema) {
+	s.schemas[name] = sch
+	for _, gvk := range sch.GroupVersionKinds {
+		s.kinds[gvk] = name
+	}
+}
+
+// AddCRD
+//
+//	@Description: 从 CRD 清单（yaml 或 json）中注册各版本的 openAPIV3Schema，用于离线校验自定义资源，例如 Nginx
+//	@param data
+//	@return error
+func (s *SchemaSet) AddCRD(data []byte) error {
+	var crd struct {
+		Kind string `json:"kind"`
+		Spec struct {
+			Group string `json:"group"`
+			Names struct {
+				Kind string `json:"kind"`
+			} `json:"names"`
+			Versions []struct {
+				Name   string `json:"name"`
+				Schema *struct {
+					OpenAPIV3Schema *Schema `json:"openAPIV3Schema"`
+				} `json:"schema"`
+			} `json:"versions"`
+		} `json:"spec"`
+	}
+	if err := yaml.Unmarshal(data, &crd); err != nil {
+		return err
+	}
+	if crd.Kind != "CustomResourceDefinition" {
+		return fmt.Errorf("expected CustomResourceDefinition, got %q", crd.Kind)
+	}
+	for _, version := range crd.Spec.Versions {
+		if version.Schema == nil || version.Schema.OpenAPIV3Schema == nil {
+			continue
+		}
+		gvk := schema.GroupVersionKind{Group: crd.Spec.Group, Version: version.Name, Kind: crd.Spec.Names.Kind}
+		root := version.Schema.OpenAPIV3Schema
+		if root.Properties == nil {
+			root.Properties = map[string]*Schema{}
+		}
+		// 与 apiserver 一致，apiVersion/kind/metadata 由系统补全
+		root.Properties["apiVersion"] = &Schema{Type: "string"}
+		root.Properties["kind"] = &Schema{Type: "string"}
+		root.Properties["metadata"] = &Schema{Ref: schemaRefPrefix + objectMetaSchema}
+		root.GroupVersionKinds = []schema.GroupVersionKind{gvk}
+		s.add(strings.Join([]string{crd.Spec.Group, version.Name, crd.Spec.Names.Kind}, "."), root)
+	}
+	return nil
+}
+
+// ForKind 返回 GVK 对应的 schema
+func (s *SchemaSet) ForKind(gvk schema.GroupVersionKind) (*Schema, bool) {
+	name, ok := s.kinds[gvk]
+	if !ok {
+		return nil, false
+	}
+	return s.schemas[name], true
+}
+
+// resolve 解析 $ref，引用不存在时返回 nil，表示不做校验
+func (s *SchemaSet) resolve(sch *Schema) *Schema {
+	for sch != nil && sch.Ref != "" {
+		sch = s.schemas[strings.TrimPrefix(sch.Ref, schemaRefPrefix)]
+	}
+	return sch
+}
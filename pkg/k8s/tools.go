@@ -61,3 +61,30 @@ func GetListWatchByDefaultConfig(resource Resource, namespace string) *cache.Lis
 func GetListWatchByDefaultNamespace(resource Resource) *cache.ListWatch {
 	return GetListWatchByDefaultConfig(resource, DefaultNamespace)
 }
+
+// GetNamespacedListWatch
+//
+//	@Description: 返回按命名空间创建 ListWatch 的函数，用于 controller.NamespaceSelector，只支持 core/v1 资源
+//	@param client
+//	@param resource
+//	@return func(namespace string) cache.ListerWatcher
+func GetNamespacedListWatch(client kubernetes.Interface, resource Resource) func(namespace string) cache.ListerWatcher {
+	return func(namespace string) cache.ListerWatcher {
+		return cache.NewListWatchFromClient(client.CoreV1().RESTClient(), resource.toString(), namespace, fields.Everything())
+	}
+}
+
+// GetK8SConfigForContext
+//
+//	@Description: 构建 kubeconfig 中 context 对应的k8s配置，用于同时访问多个集群
+//	@param kubeConfigPath: .kube/config 系统路径，为空时使用默认加载规则（$KUBECONFIG 或 $HOME/.kube/config）
+//	@param context: kubeconfig 中的 context 名称，为空时使用 current-context
+//	@return *rest.Config
+//	@return error
+func GetK8SConfigForContext(kubeConfigPath, context string) (*rest.Config, error) {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	if kubeConfigPath != "" {
+		rules.ExplicitPath = kubeConfigPath
+	}
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, &clientcmd.ConfigOverrides{CurrentContext: context}).ClientConfig()
+}
@@ -0,0 +1,268 @@
+package k8s
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"reflect"
+	"sort"
+)
+
+type ValidationErrorType string
+
+const (
+	ErrUnknownField  = ValidationErrorType("UnknownField")
+	ErrWrongType     = ValidationErrorType("WrongType")
+	ErrRequired      = ValidationErrorType("Required")
+	ErrNotSupported  = ValidationErrorType("NotSupported")
+	ErrUnknownSchema = ValidationErrorType("UnknownSchema")
+)
+
+// ValidationError 一条字段校验错误，Path 形如 spec.template.spec.containers[0].image
+type ValidationError struct {
+	Object string              `json:"object,omitempty"`
+	Path   string              `json:"path"`
+	Type   ValidationErrorType `json:"type"`
+	Detail string              `json:"detail"`
+}
+
+func (e ValidationError) Error() string {
+	if e.Object == "" {
+		return fmt.Sprintf("%s: %s: %s", e.Path, e.Type, e.Detail)
+	}
+	return fmt.Sprintf("%s: %s: %s: %s", e.Object, e.Path, e.Type, e.Detail)
+}
+
+// Validate
+//
+//	@Description: 使用 schema 离线校验对象，返回所有未知字段、类型错误和缺失的必填字段
+//	@param obj
+//	@return []ValidationError
+func (s *SchemaSet) Validate(obj *unstructured.Unstructured) []ValidationError {
+	id := obj.GroupVersionKind().String()
+	if obj.GetName() != "" {
+		id = fmt.Sprintf("%s %s", id, objectName(obj.GetNamespace(), obj.GetName()))
+	}
+	root, ok := s.ForKind(obj.GroupVersionKind())
+	if !ok {
+		return []ValidationError{{Object: id, Type: ErrUnknownSchema, Detail: fmt.Sprintf("no schema for %s", obj.GroupVersionKind())}}
+	}
+	v := &validator{set: s}
+	v.validate("", root, obj.Object)
+	for i := range v.errs {
+		v.errs[i].Object = id
+	}
+	return v.errs
+}
+
+// ValidateManifest
+//
+//	@Description: 校验一个 yaml/json 清单，支持以 --- 分隔的多个文档
+//	@param data
+//	@return []ValidationError
+//	@return error: 清单无法解析时返回
+func (s *SchemaSet) ValidateManifest(data []byte) ([]ValidationError, error) {
+	var errs []ValidationError
+	decoder := utilyaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096)
+	for {
+		obj := &unstructured.Unstructured{}
+		if err := decoder.Decode(&obj.Object); err != nil {
+			if errors.Is(err, io.EOF) {
+				return errs, nil
+			}
+			return errs, err
+		}
+		if len(obj.Object) == 0 {
+			continue
+		}
+		errs = append(errs, s.Validate(obj)...)
+	}
+}
+
+func objectName(namespace, name string) string {
+	if namespace == "" {
+		return name
+	}
+	return namespace + "/" + name
+}
+
+type validator struct {
+	set  *SchemaSet
+	errs []ValidationError
+}
+
+func (v *validator) errorf(path string, t ValidationErrorType, format string, args ...interface{}) {
+	v.errs = append(v.errs, ValidationError{Path: path, Type: t, Detail: fmt.Sprintf(format, args...)})
+}
+
+// try 在独立的 validator 中校验，用于 anyOf/oneOf
+func (v *validator) try(path string, sch *Schema, value interface{}) []ValidationError {
+	sub := &validator{set: v.set}
+	sub.validate(path, sch, value)
+	return sub.errs
+}
+
+func (v *validator) validate(path string, sch *Schema, value interface{}) {
+	sch = v.set.resolve(sch)
+	if sch == nil || value == nil {
+		// 引用缺失时不做校验；null 与未设置等价
+		return
+	}
+	for _, sub := range sch.AllOf {
+		v.validate(path, sub, value)
+	}
+	if sch.IntOrString {
+		if !isInteger(value) && !isString(value) {
+			v.errorf(path, ErrWrongType, "expected integer or string, got %s", typeName(value))
+		}
+		return
+	}
+	if alternatives := append(append([]*Schema{}, sch.AnyOf...), sch.OneOf...); len(alternatives) > 0 && sch.Type == "" {
+		var first []ValidationError
+		for i, alt := range alternatives {
+			errs := v.try(path, alt, value)
+			if len(errs) == 0 {
+				return
+			}
+			if i == 0 {
+				first = errs
+			}
+		}
+		v.errs = append(v.errs, first...)
+		return
+	}
+
+	switch sch.Type {
+	case "object":
+		v.validateObject(path, sch, value)
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			v.errorf(path, ErrWrongType, "expected array, got %s", typeName(value))
+			return
+		}
+		for i, item := range items {
+			v.validate(fmt.Sprintf("%s[%d]", path, i), sch.Items, item)
+		}
+	case "string":
+		if !isString(value) && !(sch.Format == "int-or-string" && isInteger(value)) {
+			v.errorf(path, ErrWrongType, "expected string, got %s", typeName(value))
+		}
+	case "integer":
+		if !isInteger(value) {
+			v.errorf(path, ErrWrongType, "expected integer, got %s", typeName(value))
+		}
+	case "number":
+		if !isNumber(value) {
+			v.errorf(path, ErrWrongType, "expected number, got %s", typeName(value))
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			v.errorf(path, ErrWrongType, "expected boolean, got %s", typeName(value))
+		}
+	case "":
+		if len(sch.Properties) > 0 {
+			v.validateObject(path, sch, value)
+		}
+	}
+	if len(sch.Enum) > 0 && !inEnum(sch.Enum, value) {
+		v.errorf(path, ErrNotSupported, "unsupported value %v, supported values: %v", value, sch.Enum)
+	}
+}
+
+func (v *validator) validateObject(path string, sch *Schema, value interface{}) {
+	obj, ok := value.(map[string]interface{})
+	if !ok {
+		v.errorf(path, ErrWrongType, "expected object, got %s", typeName(value))
+		return
+	}
+	for _, name := range sch.Required {
+		if field, ok := obj[name]; !ok || field == nil {
+			v.errorf(joinPath(path, name), ErrRequired, "required field %q is missing", name)
+		}
+	}
+	// 没有定义任何属性的 object 为自由格式，例如 RawExtension
+	freeForm := sch.PreserveUnknownFields || len(sch.Properties) == 0 && sch.AdditionalProperties == nil
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fieldPath := joinPath(path, key)
+		if prop, ok := sch.Properties[key]; ok {
+			v.validate(fieldPath, prop, obj[key])
+			continue
+		}
+		switch {
+		case sch.AdditionalProperties != nil && sch.AdditionalProperties.Schema != nil:
+			v.validate(fieldPath, sch.AdditionalProperties.Schema, obj[key])
+		case sch.AdditionalProperties != nil && sch.AdditionalProperties.Allows, freeForm:
+		case sch.EmbeddedResource && (key == "apiVersion" || key == "kind" || key == "metadata"):
+		default:
+			v.errorf(fieldPath, ErrUnknownField, "unknown field %q", key)
+		}
+	}
+}
+
+func joinPath(path, field string) string {
+	if path == "" {
+		return field
+	}
+	return path + "." + field
+}
+
+func isString(value interface{}) bool {
+	_, ok := value.(string)
+	return ok
+}
+
+func isNumber(value interface{}) bool {
+	switch value.(type) {
+	case float64, float32, int, int32, int64:
+		return true
+	}
+	return false
+}
+
+func isInteger(value interface{}) bool {
+	switch n := value.(type) {
+	case int, int32, int64:
+		return true
+	case float64:
+		return n == float64(int64(n))
+	}
+	return false
+}
+
+func typeName(value interface{}) string {
+	switch value.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	}
+	if isInteger(value) {
+		return "integer"
+	}
+	if isNumber(value) {
+		return "number"
+	}
+	return reflect.TypeOf(value).String()
+}
+
+func inEnum(enum []interface{}, value interface{}) bool {
+	for _, e := range enum {
+		if reflect.DeepEqual(e, value) || fmt.Sprint(e) == fmt.Sprint(value) {
+			return true
+		}
+	}
+	return false
+}
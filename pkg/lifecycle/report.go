@@ -0,0 +1,129 @@
+package lifecycle
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// 报告输出格式
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// DefaultReportInterval 定期报告的默认间隔
+const DefaultReportInterval = time.Minute
+
+// Report 生命周期统计报告
+type Report struct {
+	GeneratedAt time.Time `json:"generatedAt"`
+	Total       Stats     `json:"total"`
+	Namespaces  []Stats   `json:"namespaces"`
+	Owners      []Stats   `json:"owners"`
+}
+
+// Report 生成当前的统计报告
+func (t *Tracker) Report() *Report {
+	return &Report{
+		GeneratedAt: t.opts.Clock.Now(),
+		Total:       t.Total(),
+		Namespaces:  t.ByNamespace(),
+		Owners:      t.ByOwner(),
+	}
+}
+
+// WriteReports
+//
+//	@Description: 每隔 interval 清理过期的时间线并输出一次报告，直到 ctx 取消
+//	@param ctx
+//	@param w
+//	@param interval: 小于等于 0 时使用 DefaultReportInterval
+//	@param format: text|json
+//	@return error
+func (t *Tracker) WriteReports(ctx context.Context, w io.Writer, interval time.Duration, format string) error {
+	if interval <= 0 {
+		interval = DefaultReportInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			t.Prune()
+			if err := WriteReport(w, t.Report(), format); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// WriteReport
+//
+//	@Description: 以 text 或 json 格式输出报告
+//	@param w
+//	@param report
+//	@param format
+//	@return error
+func WriteReport(w io.Writer, report *Report, format string) error {
+	switch format {
+	case FormatJSON:
+		return json.NewEncoder(w).Encode(report)
+	case FormatText, "":
+		return writeReportText(w, report)
+	default:
+		return fmt.Errorf("unsupported output format %q", format)
+	}
+}
+
+func writeReportText(w io.Writer, report *Report) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "Pod lifecycle at %s, %d pods\n", report.GeneratedAt.Format(time.RFC3339), report.Total.Pods)
+	sections := []struct {
+		title string
+		stats []Stats
+	}{
+		{"NAMESPACE", report.Namespaces},
+		{"OWNER", report.Owners},
+	}
+	for _, section := range sections {
+		if len(section.stats) == 0 {
+			continue
+		}
+		fmt.Fprintf(tw, "\n%s\tPODS\tSCHEDULE P50/P90/P99\tREADY P50/P90/P99\tRESTARTS\tTERMINATIONS\n", section.title)
+		for _, stats := range section.stats {
+			fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%d\t%s\n", stats.Group, stats.Pods,
+				formatPercentiles(stats.TimeToSchedule), formatPercentiles(stats.TimeToReady), stats.Restarts, formatTerminations(stats.Terminations))
+		}
+	}
+	return tw.Flush()
+}
+
+func formatPercentiles(p Percentiles) string {
+	if p.Count == 0 {
+		return "-"
+	}
+	round := func(d time.Duration) string {
+		return d.Round(100 * time.Millisecond).String()
+	}
+	return round(p.P50) + "/" + round(p.P90) + "/" + round(p.P99)
+}
+
+func formatTerminations(terminations map[string]int) string {
+	if len(terminations) == 0 {
+		return "-"
+	}
+	reasons := make([]string, 0, len(terminations))
+	for reason, count := range terminations {
+		reasons = append(reasons, fmt.Sprintf("%s=%d", reason, count))
+	}
+	sort.Strings(reasons)
+	return strings.Join(reasons, ",")
+}
@@ -0,0 +1,115 @@
+package lifecycle
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Percentiles 一组耗时的分位数
+type Percentiles struct {
+	Count int           `json:"count"`
+	P50   time.Duration `json:"p50"`
+	P90   time.Duration `json:"p90"`
+	P99   time.Duration `json:"p99"`
+	Max   time.Duration `json:"max"`
+}
+
+// percentiles 使用 nearest-rank 计算分位数
+func percentiles(values []time.Duration) Percentiles {
+	if len(values) == 0 {
+		return Percentiles{}
+	}
+	sorted := append([]time.Duration(nil), values...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	rank := func(p float64) time.Duration {
+		i := int(math.Ceil(p*float64(len(sorted)))) - 1
+		if i < 0 {
+			i = 0
+		}
+		return sorted[i]
+	}
+	return Percentiles{
+		Count: len(sorted),
+		P50:   rank(0.5),
+		P90:   rank(0.9),
+		P99:   rank(0.99),
+		Max:   sorted[len(sorted)-1],
+	}
+}
+
+// Stats 一组 Pod 的统计
+type Stats struct {
+	// Group 命名空间，或 namespace/Kind/Name 格式的控制器，汇总时为空
+	Group          string      `json:"group,omitempty"`
+	Pods           int         `json:"pods"`
+	TimeToSchedule Percentiles `json:"timeToSchedule"`
+	TimeToReady    Percentiles `json:"timeToReady"`
+	Restarts       int32       `json:"restarts"`
+	// Terminations 按原因统计的容器终止次数
+	Terminations map[string]int `json:"terminations,omitempty"`
+}
+
+// aggregate 按 group 汇总时间线，group 返回 false 的 Pod 不参与统计
+func aggregate(timelines []Timeline, group func(timeline *Timeline) (string, bool)) []Stats {
+	type values struct {
+		stats           Stats
+		schedule, ready []time.Duration
+	}
+	groups := map[string]*values{}
+	for i := range timelines {
+		timeline := &timelines[i]
+		name, ok := group(timeline)
+		if !ok {
+			continue
+		}
+		v, ok := groups[name]
+		if !ok {
+			v = &values{stats: Stats{Group: name}}
+			groups[name] = v
+		}
+		v.stats.Pods++
+		v.stats.Restarts += timeline.Restarts
+		if d, ok := timeline.TimeToSchedule(); ok {
+			v.schedule = append(v.schedule, d)
+		}
+		if d, ok := timeline.TimeToReady(); ok {
+			v.ready = append(v.ready, d)
+		}
+		for _, termination := range timeline.Terminations {
+			if v.stats.Terminations == nil {
+				v.stats.Terminations = map[string]int{}
+			}
+			v.stats.Terminations[termination.Reason]++
+		}
+	}
+	stats := make([]Stats, 0, len(groups))
+	for _, v := range groups {
+		v.stats.TimeToSchedule = percentiles(v.schedule)
+		v.stats.TimeToReady = percentiles(v.ready)
+		stats = append(stats, v.stats)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Group < stats[j].Group })
+	return stats
+}
+
+// Total 所有 Pod 的统计
+func (t *Tracker) Total() Stats {
+	total := aggregate(t.List(), func(*Timeline) (string, bool) { return "", true })
+	if len(total) == 0 {
+		return Stats{}
+	}
+	return total[0]
+}
+
+// ByNamespace 按命名空间统计
+func (t *Tracker) ByNamespace() []Stats {
+	return aggregate(t.List(), func(timeline *Timeline) (string, bool) { return timeline.Namespace, true })
+}
+
+// ByOwner 按控制器统计，没有控制器的 Pod 不参与统计
+func (t *Tracker) ByOwner() []Stats {
+	return aggregate(t.List(), func(timeline *Timeline) (string, bool) {
+		return timeline.Namespace + "/" + timeline.Owner, timeline.Owner != ""
+	})
+}
@@ -0,0 +1,286 @@
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"k8s-dev/pkg/controller"
+	appsV1 "k8s.io/api/apps/v1"
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/utils/clock"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultRetention 已删除 Pod 的时间线默认保留时长
+const DefaultRetention = time.Hour
+
+// 时间线中的变更类型
+const (
+	TransitionPhase     = "Phase"
+	TransitionCondition = "Condition"
+)
+
+// Transition 一次 phase 或 condition 变更。condition 使用 Pod 状态中的 LastTransitionTime，
+// phase 没有记录变更时间，使用观察到变更的时间
+type Transition struct {
+	Time   time.Time `json:"time"`
+	Type   string    `json:"type"`
+	Name   string    `json:"name"`
+	Status string    `json:"status"`
+}
+
+// Termination 一次容器终止
+type Termination struct {
+	Container string    `json:"container"`
+	Reason    string    `json:"reason"`
+	ExitCode  int32     `json:"exitCode"`
+	Time      time.Time `json:"time"`
+}
+
+// Timeline 一个 Pod 的生命周期
+type Timeline struct {
+	Key       string `json:"key"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	UID       string `json:"uid"`
+	// Owner 控制器，格式为 Kind/Name，ReplicaSet 会还原为所属的 Deployment
+	Owner string `json:"owner,omitempty"`
+	Phase string `json:"phase"`
+
+	Created         time.Time `json:"created"`
+	Scheduled       time.Time `json:"scheduled,omitempty"`
+	Initialized     time.Time `json:"initialized,omitempty"`
+	ContainersReady time.Time `json:"containersReady,omitempty"`
+	Ready           time.Time `json:"ready,omitempty"`
+	Deleted         time.Time `json:"deleted,omitempty"`
+
+	Transitions  []Transition  `json:"transitions"`
+	Restarts     int32         `json:"restarts"`
+	Terminations []Termination `json:"terminations,omitempty"`
+
+	conditions map[coreV1.PodConditionType]coreV1.ConditionStatus
+	seen       map[string]bool
+}
+
+// TimeToSchedule 从创建到调度完成的耗时，尚未调度时返回 false
+func (t *Timeline) TimeToSchedule() (time.Duration, bool) {
+	return since(t.Created, t.Scheduled)
+}
+
+// TimeToReady 从创建到第一次 Ready 的耗时，尚未 Ready 时返回 false
+func (t *Timeline) TimeToReady() (time.Duration, bool) {
+	return since(t.Created, t.Ready)
+}
+
+func since(from, to time.Time) (time.Duration, bool) {
+	if from.IsZero() || to.IsZero() {
+		return 0, false
+	}
+	if to.Before(from) {
+		return 0, true
+	}
+	return to.Sub(from), true
+}
+
+func (t *Timeline) clone() Timeline {
+	c := *t
+	c.Transitions = append([]Transition(nil), t.Transitions...)
+	c.Terminations = append([]Termination(nil), t.Terminations...)
+	c.conditions, c.seen = nil, nil
+	return c
+}
+
+// Options Tracker 参数
+type Options struct {
+	// Retention 已删除 Pod 的时间线保留时长，默认 DefaultRetention
+	Retention time.Duration
+	// Clock 默认使用系统时钟
+	Clock clock.PassiveClock
+}
+
+// Tracker 根据 Pod 事件记录生命周期，Reconcile 可以直接作为控制器的 Reconciler
+type Tracker struct {
+	opts Options
+	lock sync.RWMutex
+	pods map[string]*Timeline
+}
+
+// NewTracker 创建 Tracker
+func NewTracker(opts Options) *Tracker {
+	if opts.Retention <= 0 {
+		opts.Retention = DefaultRetention
+	}
+	if opts.Clock == nil {
+		opts.Clock = clock.RealClock{}
+	}
+	return &Tracker{opts: opts, pods: map[string]*Timeline{}}
+}
+
+// Reconcile
+//
+//	@Description: 记录 Pod 的变更，删除的 Pod 在 Retention 内仍参与统计
+//	@param ctx
+//	@param event
+//	@return error
+func (t *Tracker) Reconcile(_ context.Context, event controller.Event[*coreV1.Pod]) error {
+	pod := event.Object
+	if pod == nil {
+		pod = event.Old
+	}
+	if pod == nil {
+		return nil
+	}
+	now := t.opts.Clock.Now()
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	timeline := t.observe(event.Key, pod, now)
+	if event.Type == cache.Deleted && timeline.Deleted.IsZero() {
+		timeline.Deleted = now
+	}
+	return nil
+}
+
+// observe 把 Pod 的当前状态合并到时间线，调用方需持有锁
+func (t *Tracker) observe(key string, pod *coreV1.Pod, now time.Time) *Timeline {
+	timeline, ok := t.pods[key]
+	// 同名 Pod 被重建，例如 StatefulSet，重新开始记录
+	if !ok || timeline.UID != string(pod.UID) {
+		timeline = &Timeline{
+			Key:        key,
+			Namespace:  pod.Namespace,
+			Name:       pod.Name,
+			UID:        string(pod.UID),
+			Owner:      owner(pod),
+			Created:    pod.CreationTimestamp.Time,
+			conditions: map[coreV1.PodConditionType]coreV1.ConditionStatus{},
+			seen:       map[string]bool{},
+		}
+		t.pods[key] = timeline
+	}
+
+	if phase := string(pod.Status.Phase); phase != "" && phase != timeline.Phase {
+		at := now
+		// 第一次观察到 Pending 时可以确定发生在创建时
+		if timeline.Phase == "" && pod.Status.Phase == coreV1.PodPending && !timeline.Created.IsZero() {
+			at = timeline.Created
+		}
+		timeline.Transitions = append(timeline.Transitions, Transition{Time: at, Type: TransitionPhase, Name: TransitionPhase, Status: phase})
+		timeline.Phase = phase
+	}
+
+	for _, condition := range pod.Status.Conditions {
+		if timeline.conditions[condition.Type] == condition.Status {
+			continue
+		}
+		timeline.conditions[condition.Type] = condition.Status
+		at := condition.LastTransitionTime.Time
+		if at.IsZero() {
+			at = now
+		}
+		timeline.Transitions = append(timeline.Transitions, Transition{Time: at, Type: TransitionCondition, Name: string(condition.Type), Status: string(condition.Status)})
+		if condition.Status != coreV1.ConditionTrue {
+			continue
+		}
+		// 只记录第一次变为 True 的时间
+		switch condition.Type {
+		case coreV1.PodScheduled:
+			setOnce(&timeline.Scheduled, at)
+		case coreV1.PodInitialized:
+			setOnce(&timeline.Initialized, at)
+		case coreV1.ContainersReady:
+			setOnce(&timeline.ContainersReady, at)
+		case coreV1.PodReady:
+			setOnce(&timeline.Ready, at)
+		}
+	}
+	sort.SliceStable(timeline.Transitions, func(i, j int) bool {
+		return timeline.Transitions[i].Time.Before(timeline.Transitions[j].Time)
+	})
+
+	timeline.Restarts = 0
+	for _, statuses := range [][]coreV1.ContainerStatus{pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses} {
+		for _, status := range statuses {
+			timeline.Restarts += status.RestartCount
+			timeline.terminated(status.Name, status.LastTerminationState.Terminated)
+			timeline.terminated(status.Name, status.State.Terminated)
+		}
+	}
+	return timeline
+}
+
+func setOnce(t *time.Time, at time.Time) {
+	if t.IsZero() {
+		*t = at
+	}
+}
+
+// terminated 记录容器终止，同一次终止会同时出现在 State 和之后的 LastTerminationState 中，按结束时间去重
+func (t *Timeline) terminated(container string, state *coreV1.ContainerStateTerminated) {
+	if state == nil {
+		return
+	}
+	id := fmt.Sprintf("%s/%s/%d", container, state.FinishedAt.UTC().Format(time.RFC3339), state.ExitCode)
+	if t.seen[id] {
+		return
+	}
+	t.seen[id] = true
+	reason := state.Reason
+	if reason == "" {
+		reason = fmt.Sprintf("ExitCode:%d", state.ExitCode)
+	}
+	t.Terminations = append(t.Terminations, Termination{Container: container, Reason: reason, ExitCode: state.ExitCode, Time: state.FinishedAt.Time})
+}
+
+// owner 返回 Pod 的控制器，ReplicaSet 按 pod-template-hash 还原为 Deployment
+func owner(pod *coreV1.Pod) string {
+	ref := metaV1.GetControllerOf(pod)
+	if ref == nil {
+		return ""
+	}
+	if hash := pod.Labels[appsV1.DefaultDeploymentUniqueLabelKey]; ref.Kind == "ReplicaSet" && hash != "" && strings.HasSuffix(ref.Name, "-"+hash) {
+		return "Deployment/" + strings.TrimSuffix(ref.Name, "-"+hash)
+	}
+	return ref.Kind + "/" + ref.Name
+}
+
+// Get 返回 key 对应 Pod 的时间线
+func (t *Tracker) Get(key string) (Timeline, bool) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	timeline, ok := t.pods[key]
+	if !ok {
+		return Timeline{}, false
+	}
+	return timeline.clone(), true
+}
+
+// List 返回按 key 排序的所有时间线
+func (t *Tracker) List() []Timeline {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	timelines := make([]Timeline, 0, len(t.pods))
+	for _, timeline := range t.pods {
+		timelines = append(timelines, timeline.clone())
+	}
+	sort.Slice(timelines, func(i, j int) bool { return timelines[i].Key < timelines[j].Key })
+	return timelines
+}
+
+// Prune 删除超过 Retention 的已删除 Pod，返回删除的数量
+func (t *Tracker) Prune() int {
+	now := t.opts.Clock.Now()
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	n := 0
+	for key, timeline := range t.pods {
+		if !timeline.Deleted.IsZero() && now.Sub(timeline.Deleted) > t.opts.Retention {
+			delete(t.pods, key)
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,219 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// CloudEvents HTTP 绑定的内容模式
+const (
+	// CloudEventsBinary 属性放在 ce- 请求头中，请求体为 data
+	CloudEventsBinary = "binary"
+	// CloudEventsStructured 属性和 data 一起编码为 application/cloudevents+json 请求体
+	CloudEventsStructured = "structured"
+)
+
+// CloudEvents 的默认参数
+const (
+	DefaultCloudEventsSource     = "/k8s-dev"
+	DefaultCloudEventsTypePrefix = "k8s-dev"
+)
+
+const (
+	cloudEventsSpecVersion = "1.0"
+	cloudEventsJSON        = "application/cloudevents+json"
+)
+
+// CloudEventsOptions CloudEvents sink 的参数
+type CloudEventsOptions struct {
+	// URL 接收事件的地址
+	URL string
+	// Mode CloudEventsBinary 或 CloudEventsStructured，默认 CloudEventsBinary
+	Mode string
+	// Source 事件的 source 属性，记录带集群名称时为 <Source>/<cluster>，默认 DefaultCloudEventsSource
+	Source string
+	// TypePrefix 事件的 type 为 <TypePrefix>.<kind>.<变更类型>，例如 k8s-dev.pod.added，默认 DefaultCloudEventsTypePrefix
+	TypePrefix string
+	// Headers 附加的请求头，例如 Authorization
+	Headers map[string]string
+	// Client 发送请求的客户端，默认 10 秒超时
+	Client *http.Client
+}
+
+// cloudEvents 把每条记录作为一个 CloudEvents v1.0 事件 POST 到 HTTP 地址。
+// Send 同步发送，失败时由控制器的重试策略重试，重试的事件 id 不变，接收方可以据此去重
+type cloudEvents struct {
+	opts CloudEventsOptions
+}
+
+// cloudEvent 一个 CloudEvents 事件，data 为记录的 JSON
+type cloudEvent struct {
+	id      string
+	source  string
+	typ     string
+	subject string
+	time    time.Time
+	data    json.RawMessage
+	// extensions 扩展属性，名称只能包含小写字母和数字
+	extensions map[string]string
+}
+
+// NewCloudEvents
+//
+//	@Description: 创建 CloudEvents sink，事件的 data 为 Record 的 JSON
+//	@param opts
+//	@return Sink
+//	@return error
+func NewCloudEvents(opts CloudEventsOptions) (Sink, error) {
+	if opts.URL == "" {
+		return nil, fmt.Errorf("cloudevents sink: URL is required")
+	}
+	switch opts.Mode {
+	case "":
+		opts.Mode = CloudEventsBinary
+	case CloudEventsBinary, CloudEventsStructured:
+	default:
+		return nil, fmt.Errorf("cloudevents sink: unknown mode %q", opts.Mode)
+	}
+	if opts.Source == "" {
+		opts.Source = DefaultCloudEventsSource
+	}
+	if opts.TypePrefix == "" {
+		opts.TypePrefix = DefaultCloudEventsTypePrefix
+	}
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &cloudEvents{opts: opts}, nil
+}
+
+func (s *cloudEvents) Send(ctx context.Context, record Record) error {
+	event, err := s.event(record)
+	if err != nil {
+		return fmt.Errorf("cloudevents sink: encode %s: %w", record.Key, err)
+	}
+	var req *http.Request
+	if s.opts.Mode == CloudEventsStructured {
+		body, err := event.structured()
+		if err != nil {
+			return fmt.Errorf("cloudevents sink: encode %s: %w", record.Key, err)
+		}
+		if req, err = http.NewRequestWithContext(ctx, http.MethodPost, s.opts.URL, bytes.NewReader(body)); err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", cloudEventsJSON)
+	} else {
+		if req, err = http.NewRequestWithContext(ctx, http.MethodPost, s.opts.URL, bytes.NewReader(event.data)); err != nil {
+			return err
+		}
+		// 二进制模式中 datacontenttype 对应 Content-Type
+		req.Header.Set("Content-Type", "application/json")
+		for name, value := range event.attributes() {
+			if name != "datacontenttype" {
+				req.Header.Set("ce-"+name, encodeHeaderValue(value))
+			}
+		}
+	}
+	for k, v := range s.opts.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := s.opts.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("cloudevents sink: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("cloudevents sink: POST %s: %s", s.opts.URL, resp.Status)
+	}
+	return nil
+}
+
+func (s *cloudEvents) Close() error {
+	return nil
+}
+
+// event 把记录转换为事件。
+// id 由 uid、resourceVersion 和变更类型组成，同一次变更重试时不变；缺少 uid 或 resourceVersion 时随机生成
+func (s *cloudEvents) event(record Record) (*cloudEvent, error) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	action := strings.ToLower(record.Type)
+	kind := strings.ToLower(record.Kind)
+	if kind == "" {
+		kind = "object"
+	}
+	event := &cloudEvent{
+		id:      string(uuid.NewUUID()),
+		source:  s.opts.Source,
+		typ:     s.opts.TypePrefix + "." + kind + "." + action,
+		subject: record.Key,
+		time:    record.Time,
+		data:    data,
+	}
+	if record.UID != "" && record.ResourceVersion != "" {
+		event.id = record.UID + "/" + record.ResourceVersion + "/" + action
+	}
+	if record.Name != "" {
+		event.subject = record.Name
+		if record.Namespace != "" {
+			event.subject = record.Namespace + "/" + record.Name
+		}
+	}
+	if record.Cluster != "" {
+		event.source = strings.TrimSuffix(s.opts.Source, "/") + "/" + record.Cluster
+		event.extensions = map[string]string{"cluster": record.Cluster}
+	}
+	return event, nil
+}
+
+// attributes 返回除 data 以外的所有属性
+func (e *cloudEvent) attributes() map[string]string {
+	attributes := map[string]string{
+		"specversion":     cloudEventsSpecVersion,
+		"id":              e.id,
+		"source":          e.source,
+		"type":            e.typ,
+		"subject":         e.subject,
+		"datacontenttype": "application/json",
+	}
+	if !e.time.IsZero() {
+		attributes["time"] = e.time.UTC().Format(time.RFC3339Nano)
+	}
+	for name, value := range e.extensions {
+		attributes[name] = value
+	}
+	return attributes
+}
+
+// structured 结构化模式的 JSON 编码
+func (e *cloudEvent) structured() ([]byte, error) {
+	event := map[string]interface{}{"data": e.data}
+	for name, value := range e.attributes() {
+		event[name] = value
+	}
+	return json.Marshal(event)
+}
+
+// encodeHeaderValue 按 HTTP 绑定的要求对空格、双引号、百分号和可打印 ASCII 以外的字节做百分号编码
+func encodeHeaderValue(value string) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if c <= ' ' || c >= 0x7f || c == '"' || c == '%' {
+			fmt.Fprintf(&b, "%%%02X", c)
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
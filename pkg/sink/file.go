@@ -0,0 +1,135 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// 文件轮转的默认值
+const (
+	DefaultMaxFileSize = 100 << 20
+	DefaultMaxBackups  = 3
+)
+
+// FileOptions 轮转文件的参数
+type FileOptions struct {
+	// Path 当前写入的文件，轮转后的文件依次为 Path.1、Path.2 ...
+	Path string
+	// MaxSize 文件超过该字节数后轮转，默认 DefaultMaxFileSize
+	MaxSize int64
+	// MaxBackups 保留的历史文件数，默认 DefaultMaxBackups
+	MaxBackups int
+}
+
+// file 以 JSON Lines 格式写入文件并按大小轮转
+type file struct {
+	lock   sync.Mutex
+	opts   FileOptions
+	f      *os.File
+	size   int64
+	closed bool
+}
+
+// NewFile
+//
+//	@Description: 以 JSON Lines 格式追加写入 opts.Path，超过 MaxSize 后轮转
+//	@param opts
+//	@return Sink
+//	@return error
+func NewFile(opts FileOptions) (Sink, error) {
+	if opts.Path == "" {
+		return nil, fmt.Errorf("file sink: Path is required")
+	}
+	if opts.MaxSize <= 0 {
+		opts.MaxSize = DefaultMaxFileSize
+	}
+	if opts.MaxBackups <= 0 {
+		opts.MaxBackups = DefaultMaxBackups
+	}
+	s := &file{opts: opts}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *file) open() error {
+	f, err := os.OpenFile(s.opts.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("file sink: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return fmt.Errorf("file sink: %w", err)
+	}
+	s.f, s.size = f, info.Size()
+	return nil
+}
+
+// rotate 关闭当前文件，Path.n 依次改名为 Path.n+1，超出 MaxBackups 的文件被覆盖。
+// 失败时当前文件已关闭，s.f 为 nil，下次 Send 重新打开 Path 并再次轮转
+func (s *file) rotate() error {
+	err := s.f.Close()
+	s.f = nil
+	if err != nil {
+		return fmt.Errorf("file sink: %w", err)
+	}
+	for i := s.opts.MaxBackups - 1; i >= 1; i-- {
+		from := fmt.Sprintf("%s.%d", s.opts.Path, i)
+		if _, err := os.Stat(from); err == nil {
+			if err := os.Rename(from, fmt.Sprintf("%s.%d", s.opts.Path, i+1)); err != nil {
+				return fmt.Errorf("file sink: %w", err)
+			}
+		}
+	}
+	if err := os.Rename(s.opts.Path, s.opts.Path+".1"); err != nil {
+		return fmt.Errorf("file sink: %w", err)
+	}
+	return s.open()
+}
+
+func (s *file) Send(_ context.Context, record Record) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("encode %s: %w", record.Key, err)
+	}
+	data = append(data, '\n')
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.closed {
+		return fmt.Errorf("file sink: closed")
+	}
+	if s.f == nil {
+		if err := s.open(); err != nil {
+			return err
+		}
+	}
+	if s.size > 0 && s.size+int64(len(data)) > s.opts.MaxSize {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := s.f.Write(data)
+	s.size += int64(n)
+	return err
+}
+
+func (s *file) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	if s.f == nil {
+		return nil
+	}
+	err := s.f.Close()
+	s.f = nil
+	return err
+}
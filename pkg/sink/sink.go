@@ -0,0 +1,130 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+	"k8s-dev/pkg/diff"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilErrors "k8s.io/apimachinery/pkg/util/errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Record 一条对象变更记录，是所有 Sink 的输入
+type Record struct {
+	Time time.Time `json:"time"`
+	Type string    `json:"type"`
+	Kind string    `json:"kind,omitempty"`
+	Key  string    `json:"key"`
+	// Cluster 对象所在的集群，只在控制器 watch 多个集群时设置
+	Cluster         string         `json:"cluster,omitempty"`
+	Namespace       string         `json:"namespace,omitempty"`
+	Name            string         `json:"name"`
+	UID             string         `json:"uid,omitempty"`
+	ResourceVersion string         `json:"resourceVersion,omitempty"`
+	Tombstone       bool           `json:"tombstone,omitempty"`
+	Object          runtime.Object `json:"object,omitempty"`
+	Old             runtime.Object `json:"old,omitempty"`
+	// Diff Updated 事件的字段差异
+	Diff []diff.Change `json:"diff,omitempty"`
+}
+
+// Sink 变更记录的输出目标
+type Sink interface {
+	// Send 输出一条记录，返回错误时由控制器的重试策略决定是否重新投递
+	Send(ctx context.Context, record Record) error
+	// Close 刷新缓冲并释放资源
+	Close() error
+}
+
+// maxPendingRecords multi 最多记住的部分发送失败的记录数，超出后丢弃最早的记录，重试时可能重复发送
+const maxPendingRecords = 10000
+
+// multi 同时输出到多个 Sink
+type multi struct {
+	sinks []Sink
+	lock  sync.Mutex
+	// delivered 部分 sink 发送失败的记录已经发送成功的 sink，控制器重试该记录时跳过这些 sink
+	delivered map[string]map[int]bool
+	order     []string
+}
+
+// Multi 把记录依次发送给所有 sinks，任意一个失败都会返回错误。
+// 控制器重试同一条记录（类型、对象和 resourceVersion 相同）时只发送给上次失败的 sink
+func Multi(sinks ...Sink) Sink {
+	return &multi{sinks: sinks, delivered: map[string]map[int]bool{}}
+}
+
+func (m *multi) Send(ctx context.Context, record Record) error {
+	id := recordID(record)
+	m.lock.Lock()
+	delivered := m.delivered[id]
+	m.lock.Unlock()
+
+	var errs []error
+	succeeded := map[int]bool{}
+	for i, s := range m.sinks {
+		if delivered[i] {
+			succeeded[i] = true
+			continue
+		}
+		if err := s.Send(ctx, record); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		succeeded[i] = true
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if len(errs) == 0 {
+		delete(m.delivered, id)
+		return nil
+	}
+	if _, ok := m.delivered[id]; !ok {
+		m.order = append(m.order, id)
+	}
+	m.delivered[id] = succeeded
+	for len(m.order) > maxPendingRecords {
+		delete(m.delivered, m.order[0])
+		m.order = m.order[1:]
+	}
+	return utilErrors.NewAggregate(errs)
+}
+
+// recordID 标识同一条记录，不包含每次转换都会变化的 Time
+func recordID(record Record) string {
+	return strings.Join([]string{record.Type, record.Kind, record.Key, record.UID, record.ResourceVersion}, "/")
+}
+
+func (m *multi) Close() error {
+	var errs []error
+	for _, s := range m.sinks {
+		if err := s.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return utilErrors.NewAggregate(errs)
+}
+
+// channel 把记录发送到 Go channel，便于嵌入其他程序
+type channel chan<- Record
+
+// Channel 把记录发送到 ch，ch 已满时阻塞直到 ctx 取消；Close 不会关闭 ch
+func Channel(ch chan<- Record) Sink {
+	return channel(ch)
+}
+
+func (c channel) Send(ctx context.Context, record Record) error {
+	select {
+	case c <- record:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("channel sink: %w", ctx.Err())
+	}
+}
+
+func (c channel) Close() error {
+	return nil
+}
@@ -0,0 +1,226 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// webhook 的默认参数
+const (
+	DefaultBatchSize     = 100
+	DefaultMaxBuffered   = 10000
+	DefaultFlushInterval = time.Second
+	DefaultMaxRetries    = 3
+	DefaultRetryBackoff  = 500 * time.Millisecond
+)
+
+// WebhookOptions webhook 的参数
+type WebhookOptions struct {
+	// URL 接收记录的地址，请求体为记录的 JSON 数组
+	URL string
+	// Headers 附加的请求头，例如 Authorization
+	Headers map[string]string
+	// BatchSize 缓冲的记录数达到该值时立即发送，默认 DefaultBatchSize
+	BatchSize int
+	// MaxBuffered 缓冲的最大记录数，缓冲已满时 Send 返回错误，由控制器稍后重试，默认 DefaultMaxBuffered
+	MaxBuffered int
+	// FlushInterval 缓冲的最长时间，默认 DefaultFlushInterval
+	FlushInterval time.Duration
+	// MaxRetries 发送失败后的重试次数，默认 DefaultMaxRetries，小于 0 时不重试
+	MaxRetries int
+	// RetryBackoff 首次重试的等待时间，之后每次翻倍，默认 DefaultRetryBackoff
+	RetryBackoff time.Duration
+	// Client 发送请求的客户端，默认 10 秒超时
+	Client *http.Client
+}
+
+// webhook 批量 POST 记录到 HTTP 地址。Send 只写入缓冲，发送在后台进行，因此投递是 at-most-once 的：
+// Send 返回 nil 只表示记录已进入缓冲，重试后仍失败的批次会被丢弃，通过 utilruntime.HandleError 报告，
+// 并由 Close 汇总返回。需要失败时由控制器重试的场景应使用同步发送的 CloudEvents sink
+type webhook struct {
+	opts   WebhookOptions
+	lock   sync.Mutex
+	batch  []Record
+	closed bool
+	flush  chan struct{}
+	// stop 在 Close 时关闭，重试不再等待退避时间
+	stop chan struct{}
+	done chan struct{}
+	// dropped 丢弃的记录数，lastErr 最后一次发送失败的错误
+	dropped int
+	lastErr error
+}
+
+// NewWebhook
+//
+//	@Description: 创建 webhook sink 并启动后台发送协程，使用完需要 Close 发送剩余的记录
+//	@param opts
+//	@return Sink
+//	@return error
+func NewWebhook(opts WebhookOptions) (Sink, error) {
+	if opts.URL == "" {
+		return nil, fmt.Errorf("webhook sink: URL is required")
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+	if opts.MaxBuffered <= 0 {
+		opts.MaxBuffered = DefaultMaxBuffered
+	}
+	if opts.MaxBuffered < opts.BatchSize {
+		opts.MaxBuffered = opts.BatchSize
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = DefaultFlushInterval
+	}
+	if opts.MaxRetries < 0 {
+		opts.MaxRetries = 0
+	} else if opts.MaxRetries == 0 {
+		opts.MaxRetries = DefaultMaxRetries
+	}
+	if opts.RetryBackoff <= 0 {
+		opts.RetryBackoff = DefaultRetryBackoff
+	}
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	s := &webhook{opts: opts, flush: make(chan struct{}, 1), stop: make(chan struct{}), done: make(chan struct{})}
+	go s.loop()
+	return s, nil
+}
+
+func (s *webhook) Send(_ context.Context, record Record) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.closed {
+		return fmt.Errorf("webhook sink: closed")
+	}
+	if len(s.batch) >= s.opts.MaxBuffered {
+		return fmt.Errorf("webhook sink: buffer is full (%d records)", len(s.batch))
+	}
+	s.batch = append(s.batch, record)
+	if len(s.batch) >= s.opts.BatchSize {
+		select {
+		case s.flush <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Close 停止后台协程并发送剩余的记录，有记录被丢弃时返回错误
+func (s *webhook) Close() error {
+	s.lock.Lock()
+	if s.closed {
+		s.lock.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.lock.Unlock()
+	close(s.stop)
+	close(s.flush)
+	<-s.done
+	if s.dropped > 0 {
+		return fmt.Errorf("webhook sink: dropped %d records, last error: %w", s.dropped, s.lastErr)
+	}
+	return nil
+}
+
+func (s *webhook) loop() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.opts.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case _, ok := <-s.flush:
+			if !ok {
+				s.drain()
+				return
+			}
+		case <-ticker.C:
+		}
+		s.drain()
+	}
+}
+
+// drain 分批发送缓冲中的所有记录
+func (s *webhook) drain() {
+	for batch := s.take(); len(batch) > 0; batch = s.take() {
+		if err := s.send(batch); err != nil {
+			s.dropped += len(batch)
+			s.lastErr = err
+			utilruntime.HandleError(err)
+		}
+	}
+}
+
+// take 从缓冲中取出最多 BatchSize 条记录
+func (s *webhook) take() []Record {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	n := len(s.batch)
+	if n > s.opts.BatchSize {
+		n = s.opts.BatchSize
+	}
+	batch := s.batch[:n:n]
+	s.batch = s.batch[n:]
+	return batch
+}
+
+// send 发送一个批次，失败时按指数退避重试，Close 后剩余的重试立即进行
+func (s *webhook) send(batch []Record) error {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return fmt.Errorf("webhook sink: %w", err)
+	}
+	backoff := s.opts.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		err = s.post(body)
+		if err == nil {
+			return nil
+		}
+		if attempt >= s.opts.MaxRetries {
+			return fmt.Errorf("webhook sink: dropping %d records after %d attempts: %w", len(batch), attempt+1, err)
+		}
+		s.wait(backoff)
+		backoff *= 2
+	}
+}
+
+// wait 等待 d，Close 时立即返回
+func (s *webhook) wait(d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-s.stop:
+	}
+}
+
+func (s *webhook) post(body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, s.opts.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range s.opts.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := s.opts.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("POST %s: %s", s.opts.URL, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// 输出格式
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// writer 以文本或 JSON Lines 格式写入 io.Writer
+type writer struct {
+	lock   sync.Mutex
+	w      io.Writer
+	format string
+}
+
+// NewWriter
+//
+//	@Description: 以 format 格式把记录写入 w，每条记录一行
+//	@param w
+//	@param format: text|json
+//	@return Sink
+//	@return error
+func NewWriter(w io.Writer, format string) (Sink, error) {
+	if format != FormatText && format != FormatJSON {
+		return nil, fmt.Errorf("unknown sink format %q", format)
+	}
+	return &writer{w: w, format: format}, nil
+}
+
+// NewStdout 以 format 格式把记录写入标准输出
+func NewStdout(format string) (Sink, error) {
+	return NewWriter(os.Stdout, format)
+}
+
+func (s *writer) Send(_ context.Context, record Record) error {
+	var line []byte
+	if s.format == FormatJSON {
+		data, err := json.Marshal(record)
+		if err != nil {
+			return fmt.Errorf("encode %s: %w", record.Key, err)
+		}
+		line = append(data, '\n')
+	} else {
+		line = []byte(formatText(record))
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	_, err := s.w.Write(line)
+	return err
+}
+
+func (s *writer) Close() error {
+	return nil
+}
+
+// formatText 以 logfmt 风格格式化一条记录
+func formatText(record Record) string {
+	line := fmt.Sprintf("time=%s type=%s", record.Time.Format(time.RFC3339), record.Type)
+	if record.Kind != "" {
+		line += " kind=" + record.Kind
+	}
+	if record.Cluster != "" {
+		line += " cluster=" + record.Cluster
+	}
+	line += " key=" + record.Key
+	if record.ResourceVersion != "" {
+		line += " resourceVersion=" + record.ResourceVersion
+	}
+	if record.Tombstone {
+		line += " tombstone=true"
+	}
+	line += "\n"
+	for _, change := range record.Diff {
+		line += "  " + change.String() + "\n"
+	}
+	return line
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	dev "k8s-dev/pkg/k8s"
+	"k8s-dev/pkg/k8s/printers"
 	coreV1 "k8s.io/api/core/v1"
 	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -13,13 +14,15 @@ import (
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
+	"os"
 	"testing"
 )
 
 func output(pods *coreV1.PodList, s string) {
 	fmt.Println("*******************", s, "*******************")
-	for _, pod := range pods.Items {
-		fmt.Println(pod.Name)
+	printer := &printers.TablePrinter{Wide: true}
+	if err := printer.PrintObj(pods, os.Stdout); err != nil {
+		fmt.Println("输出pods异常：", err)
 	}
 	fmt.Println()
 }
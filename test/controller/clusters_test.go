@@ -0,0 +1,133 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"k8s-dev/pkg/controller"
+	"k8s-dev/pkg/index"
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+	fcache "k8s.io/client-go/tools/cache/testing"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// unreachableListWatch 模拟无法连接的集群
+func unreachableListWatch() cache.ListerWatcher {
+	return &cache.ListWatch{
+		ListFunc: func(metaV1.ListOptions) (runtime.Object, error) {
+			return nil, errors.New("connection refused")
+		},
+		WatchFunc: func(metaV1.ListOptions) (watch.Interface, error) {
+			return nil, errors.New("connection refused")
+		},
+	}
+}
+
+func TestClusters(t *testing.T) {
+	east, west := fcache.NewFakeControllerSource(), fcache.NewFakeControllerSource()
+	east.Add(newPod("web"))
+	west.Add(newPod("web"))
+	west.Add(namespacedPod("kube-system", "dns"))
+	events := make(chan controller.Event[*coreV1.Pod], 10)
+	ctrl, err := controller.New(controller.Options[*coreV1.Pod]{
+		Name: "clusters",
+		Clusters: []controller.Cluster{
+			{Name: "west", ListWatch: west},
+			{Name: "east", ListWatch: east},
+			{Name: "dead", ListWatch: unreachableListWatch()},
+		},
+		ClusterSyncTimeout: 200 * time.Millisecond,
+		Indexers:           cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc},
+		Predicates:         []controller.Predicate{controller.InNamespaces("default")},
+		Reconcile: func(_ context.Context, event controller.Event[*coreV1.Pod]) error {
+			events <- event
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go ctrl.Run(ctx)
+
+	// 不可达的集群不阻塞其他集群
+	var got []string
+	for i := 0; i < 2; i++ {
+		event := receive(t, events)
+		if event.Object == nil || event.Cluster+"/default/"+event.Object.Name != event.Key {
+			t.Errorf("key 应带集群名称: %+v", event)
+		}
+		got = append(got, event.Key)
+	}
+	sort.Strings(got)
+	if want := []string{"east/default/web", "west/default/web"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("应处理所有可达集群的对象: %v", got)
+	}
+	if !ctrl.HasSynced() {
+		t.Error("不可达的集群超时后应视为已同步")
+	}
+	statuses := ctrl.Clusters()
+	if len(statuses) != 3 || statuses[0].Name != "dead" || !statuses[0].Unreachable || statuses[0].Synced || !statuses[1].Synced || !statuses[2].Synced {
+		t.Errorf("集群状态不正确: %+v", statuses)
+	}
+
+	// 合并的缓存支持查询，key 带集群名称
+	keys := ctrl.Indexer().ListKeys()
+	sort.Strings(keys)
+	if !reflect.DeepEqual(keys, []string{"east/default/web", "west/default/web", "west/kube-system/dns"}) {
+		t.Errorf("合并的缓存应包含所有集群: %v", keys)
+	}
+	keys, err = index.NewQuery[*coreV1.Pod](ctrl.Indexer()).InNamespace("kube-system").Keys()
+	if err != nil || !reflect.DeepEqual(keys, []string{"west/kube-system/dns"}) {
+		t.Errorf("合并的缓存应支持索引查询: %v %v", keys, err)
+	}
+	// 没有索引条件时使用合并缓存的 key
+	pods, err := index.NewQuery[*coreV1.Pod](ctrl.Indexer()).List()
+	if err != nil || len(pods) != 3 {
+		t.Errorf("没有索引条件时应返回所有集群的对象: %d %v", len(pods), err)
+	}
+	if indexer, ok := ctrl.ClusterIndexer("east"); !ok || !reflect.DeepEqual(indexer.ListKeys(), []string{"default/web"}) {
+		t.Error("集群自己的缓存中 key 不带集群名称")
+	}
+
+	if err := ctrl.Enqueue("east/default/web"); err != nil {
+		t.Fatal(err)
+	}
+	if event := receive(t, events); event.Type != cache.Sync || event.Cluster != "east" {
+		t.Errorf("手动入队应保留集群名称: %+v", event)
+	}
+	west.Delete(newPod("web"))
+	if event := receive(t, events); event.Type != cache.Deleted || event.Key != "west/default/web" || event.Cluster != "west" {
+		t.Errorf("删除事件应带集群名称: %+v", event)
+	}
+}
+
+func TestClustersValidates(t *testing.T) {
+	reconcile := func(context.Context, controller.Event[*coreV1.Pod]) error { return nil }
+	for name, clusters := range map[string][]controller.Cluster{
+		"duplicate": {{Name: "a", ListWatch: &cache.ListWatch{}}, {Name: "a", ListWatch: &cache.ListWatch{}}},
+		"slash":     {{Name: "a/b", ListWatch: &cache.ListWatch{}}},
+		"listwatch": {{Name: "a"}},
+	} {
+		_, err := controller.New(controller.Options[*coreV1.Pod]{Name: "clusters", Clusters: clusters, Reconcile: reconcile})
+		if err == nil {
+			t.Errorf("%s: 应返回错误", name)
+		}
+	}
+	_, err := controller.New(controller.Options[*coreV1.Pod]{
+		Name:      "clusters",
+		ListWatch: &cache.ListWatch{},
+		Clusters:  []controller.Cluster{{Name: "a", ListWatch: &cache.ListWatch{}}},
+		Reconcile: reconcile,
+	})
+	if err == nil {
+		t.Error("同时设置 ListWatch 和 Clusters 时应返回错误")
+	}
+}
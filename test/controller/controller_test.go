@@ -0,0 +1,298 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"k8s-dev/pkg/controller"
+	coreV1 "k8s.io/api/core/v1"
+	rbacV1 "k8s.io/api/rbac/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+	fcache "k8s.io/client-go/tools/cache/testing"
+	"testing"
+	"time"
+)
+
+type call struct {
+	action cache.DeltaType
+	key    string
+	label  string
+	old    string
+}
+
+func record(event controller.Event[*coreV1.Pod]) call {
+	c := call{action: event.Type, key: event.Key}
+	if event.Object != nil {
+		c.label = event.Object.Labels["v"]
+	}
+	if event.Old != nil {
+		c.old = event.Old.Labels["v"]
+	}
+	return c
+}
+
+func newPod(name string) *coreV1.Pod {
+	return &coreV1.Pod{ObjectMeta: metaV1.ObjectMeta{Name: name, Namespace: "default", Labels: map[string]string{"v": "1"}}}
+}
+
+func waitCall(t *testing.T, calls <-chan call) call {
+	t.Helper()
+	select {
+	case c := <-calls:
+		return c
+	case <-time.After(5 * time.Second):
+		t.Fatal("等待 Reconcile 超时")
+	}
+	return call{}
+}
+
+// eventually 每 10ms 检查一次 cond，5s 内没有返回 nil 时以最后一次的错误失败
+func eventually(t *testing.T, cond func() error) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		err := cond()
+		if err == nil {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestControllerReconcile(t *testing.T) {
+	source := fcache.NewFakeControllerSource()
+	calls := make(chan call, 10)
+	ctrl, err := controller.New(controller.Options[*coreV1.Pod]{
+		Name:      "test",
+		ListWatch: source,
+		Reconcile: func(_ context.Context, event controller.Event[*coreV1.Pod]) error {
+			calls <- record(event)
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go ctrl.Run(ctx)
+
+	pod := newPod("web")
+	source.Add(pod)
+	if c := waitCall(t, calls); c != (call{cache.Added, "default/web", "1", ""}) {
+		t.Errorf("未预期的调用: %+v", c)
+	}
+	pod = pod.DeepCopy()
+	pod.Labels["v"] = "2"
+	source.Modify(pod)
+	if c := waitCall(t, calls); c != (call{cache.Updated, "default/web", "2", "1"}) {
+		t.Errorf("未预期的调用: %+v", c)
+	}
+	// 删除事件携带最后已知的状态
+	source.Delete(pod)
+	if c := waitCall(t, calls); c != (call{cache.Deleted, "default/web", "2", ""}) {
+		t.Errorf("未预期的调用: %+v", c)
+	}
+}
+
+func TestControllerKeyWithColon(t *testing.T) {
+	source := fcache.NewFakeControllerSource()
+	keys := make(chan string, 10)
+	ctrl, err := controller.New(controller.Options[*rbacV1.ClusterRole]{
+		Name:      "clusterroles",
+		ListWatch: source,
+		Reconcile: func(_ context.Context, event controller.Event[*rbacV1.ClusterRole]) error {
+			keys <- event.Key + " " + event.Object.Name
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go ctrl.Run(ctx)
+
+	source.Add(&rbacV1.ClusterRole{ObjectMeta: metaV1.ObjectMeta{Name: "system:controller:job-controller"}})
+	select {
+	case key := <-keys:
+		if key != "system:controller:job-controller system:controller:job-controller" {
+			t.Errorf("key 不应被拆分: %s", key)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("等待 Reconcile 超时")
+	}
+}
+
+func TestControllerRetry(t *testing.T) {
+	source := fcache.NewFakeControllerSource()
+	calls := make(chan call, 10)
+	ctrl, err := controller.New(controller.Options[*coreV1.Pod]{
+		Name:      "retry",
+		ListWatch: source,
+		Reconcile: func(_ context.Context, event controller.Event[*coreV1.Pod]) error {
+			calls <- record(event)
+			return errors.New("failed")
+		},
+		RetryPolicy: controller.MaxRetries(2, controller.ExponentialBackoff(time.Millisecond, time.Millisecond)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go ctrl.Run(ctx)
+
+	source.Add(newPod("web"))
+	// 首次处理加上2次重试
+	for i := 0; i < 3; i++ {
+		if c := waitCall(t, calls); c.action != cache.Added {
+			t.Errorf("重试时应保留原始事件: %+v", c)
+		}
+	}
+	select {
+	case c := <-calls:
+		t.Errorf("超过最大重试次数后不应再处理: %+v", c)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestNewValidatesOptions(t *testing.T) {
+	if _, err := controller.New(controller.Options[*coreV1.Pod]{Name: "invalid", ListWatch: fcache.NewFakeControllerSource()}); err == nil {
+		t.Error("缺少 Reconcile 应返回错误")
+	}
+}
+
+func TestRunDrainsInFlight(t *testing.T) {
+	source := fcache.NewFakeControllerSource()
+	started := make(chan struct{})
+	release := make(chan struct{})
+	ctrl, err := controller.New(controller.Options[*coreV1.Pod]{
+		Name:      "drain",
+		ListWatch: source,
+		Reconcile: func(ctx context.Context, event controller.Event[*coreV1.Pod]) error {
+			close(started)
+			select {
+			case <-release:
+			case <-ctx.Done():
+			}
+			return nil
+		},
+		DrainTimeout: 5 * time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error, 1)
+	go func() { result <- ctrl.Run(ctx) }()
+
+	source.Add(newPod("web"))
+	<-started
+	cancel()
+	select {
+	case err := <-result:
+		t.Fatalf("应等待正在处理的事件完成后再返回: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Errorf("正常停止不应返回错误: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("等待 Run 返回超时")
+	}
+}
+
+func TestRunDrainTimeout(t *testing.T) {
+	source := fcache.NewFakeControllerSource()
+	started := make(chan struct{})
+	ctrl, err := controller.New(controller.Options[*coreV1.Pod]{
+		Name:      "drain-timeout",
+		ListWatch: source,
+		Reconcile: func(ctx context.Context, event controller.Event[*coreV1.Pod]) error {
+			close(started)
+			<-ctx.Done()
+			return ctx.Err()
+		},
+		DrainTimeout: 50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error, 1)
+	go func() { result <- ctrl.Run(ctx) }()
+
+	source.Add(newPod("web"))
+	<-started
+	cancel()
+	select {
+	case err := <-result:
+		if err == nil {
+			t.Error("等待超时应返回错误")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("等待 Run 返回超时")
+	}
+}
+
+func TestRunCacheSyncFailure(t *testing.T) {
+	ctrl, err := controller.New(controller.Options[*coreV1.Pod]{
+		Name:      "sync",
+		ListWatch: fcache.NewFakeControllerSource(),
+		Reconcile: func(ctx context.Context, event controller.Event[*coreV1.Pod]) error { return nil },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := ctrl.Run(ctx); err == nil {
+		t.Error("缓存未同步时应返回错误")
+	}
+}
+
+func TestControllerDiff(t *testing.T) {
+	source := fcache.NewFakeControllerSource()
+	diffs := make(chan []string, 10)
+	ctrl, err := controller.New(controller.Options[*coreV1.Pod]{
+		Name:      "diff",
+		ListWatch: source,
+		Reconcile: func(_ context.Context, event controller.Event[*coreV1.Pod]) error {
+			var paths []string
+			for _, change := range event.Diff {
+				paths = append(paths, change.String())
+			}
+			diffs <- paths
+			return nil
+		},
+		Diff: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go ctrl.Run(ctx)
+
+	pod := newPod("web")
+	source.Add(pod)
+	if paths := <-diffs; len(paths) != 0 {
+		t.Errorf("新增事件不应计算差异: %v", paths)
+	}
+	pod = pod.DeepCopy()
+	pod.Labels["v"] = "2"
+	source.Modify(pod)
+	select {
+	case paths := <-diffs:
+		if len(paths) != 1 || paths[0] != `metadata.labels.v: "1" -> "2"` {
+			t.Errorf("更新事件的差异不正确: %v", paths)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("等待 Reconcile 超时")
+	}
+}
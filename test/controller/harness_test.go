@@ -0,0 +1,266 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"k8s-dev/pkg/controller"
+	"k8s-dev/pkg/controller/controllertest"
+	"k8s-dev/pkg/sink"
+	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newHarness(t *testing.T, opts controller.Options[*coreV1.Pod]) *controllertest.Harness[*coreV1.Pod] {
+	t.Helper()
+	h, err := controllertest.NewHarness(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(h.Close)
+	return h
+}
+
+func TestHarnessReconcile(t *testing.T) {
+	h := newHarness(t, controller.Options[*coreV1.Pod]{
+		Name: "harness",
+		Reconcile: func(context.Context, controller.Event[*coreV1.Pod]) error {
+			return nil
+		},
+	})
+
+	pod := newPod("web")
+	if err := h.Add(pod); err != nil {
+		t.Fatal(err)
+	}
+	if n := h.RunUntilIdle(); n != 1 {
+		t.Fatalf("应处理 1 个 key，实际为 %d", n)
+	}
+
+	// 处理前的多次变更合并为一次 Reconcile
+	updated := pod.DeepCopy()
+	updated.Labels["v"] = "2"
+	if err := h.Update(updated); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Delete(updated); err != nil {
+		t.Fatal(err)
+	}
+	if !h.Step() || h.Step() {
+		t.Fatal("合并后的事件应只处理一次")
+	}
+
+	calls := h.Calls()
+	if len(calls) != 2 {
+		t.Fatalf("应调用 2 次 Reconcile: %v", calls)
+	}
+	if calls[0].Event.Type != cache.Added || calls[0].Event.Key != "default/web" {
+		t.Errorf("第一次调用错误: %+v", calls[0].Event)
+	}
+	if calls[1].Event.Type != cache.Deleted || calls[1].Event.Old.Labels["v"] != "1" {
+		t.Errorf("合并后应为 Deleted 并保留最初的 Old: %+v", calls[1].Event)
+	}
+	if _, exists, _ := h.Controller.Indexer().GetByKey("default/web"); exists {
+		t.Error("删除后缓存中不应存在对象")
+	}
+}
+
+func TestHarnessRetries(t *testing.T) {
+	failures := 2
+	h := newHarness(t, controller.Options[*coreV1.Pod]{
+		Name: "harness-retry",
+		Reconcile: func(context.Context, controller.Event[*coreV1.Pod]) error {
+			if failures > 0 {
+				failures--
+				return errors.New("boom")
+			}
+			return nil
+		},
+		RetryPolicy: controller.MaxRetries(5, controller.ExponentialBackoff(time.Second, time.Minute)),
+	})
+
+	if err := h.Add(newPod("web")); err != nil {
+		t.Fatal(err)
+	}
+	start := h.Clock.Now()
+	if n := h.RunUntilIdle(); n != 1 {
+		t.Fatalf("应处理 1 个 key，实际为 %d", n)
+	}
+	if readyAt := h.Waiting()["default/web"]; !readyAt.Equal(start.Add(time.Second)) {
+		t.Fatalf("第一次重试应在 1s 后: %v", h.Waiting())
+	}
+
+	// 时间未到时不会重试
+	h.Advance(999 * time.Millisecond)
+	if h.Step() {
+		t.Fatal("退避时间未到不应重试")
+	}
+	h.Advance(time.Millisecond)
+	if !h.Step() || h.Retries("default/web") != 2 {
+		t.Fatalf("第二次失败后重试次数应为 2，实际为 %d", h.Retries("default/web"))
+	}
+	if readyAt := h.Waiting()["default/web"]; !readyAt.Equal(start.Add(3 * time.Second)) {
+		t.Fatalf("第二次重试应再等待 2s: %v", h.Waiting())
+	}
+
+	if n := h.Settle(); n != 1 {
+		t.Fatalf("应再处理 1 次，实际为 %d", n)
+	}
+	if h.Retries("default/web") != 0 || len(h.Waiting()) != 0 {
+		t.Error("成功后应清除重试记录")
+	}
+	if calls := h.Calls(); len(calls) != 3 || calls[2].Err != nil {
+		t.Errorf("应调用 3 次且最后一次成功: %v", calls)
+	}
+}
+
+func TestHarnessDeadLetter(t *testing.T) {
+	h := newHarness(t, controller.Options[*coreV1.Pod]{
+		Name: "harness-dead-letter",
+		Reconcile: func(context.Context, controller.Event[*coreV1.Pod]) error {
+			return errors.New("boom")
+		},
+		RetryPolicy: controller.MaxRetries(3, controller.ExponentialBackoff(time.Second, time.Minute)),
+	})
+
+	if err := h.Add(newPod("web")); err != nil {
+		t.Fatal(err)
+	}
+	start := h.Clock.Now()
+	if n := h.Settle(); n != 4 {
+		t.Fatalf("应处理 4 次，实际为 %d", n)
+	}
+	letter, ok := h.Controller.DeadLetters().Get("default/web")
+	if !ok || letter.Attempts != 4 {
+		t.Fatalf("应进入死信且尝试 4 次: %+v", letter)
+	}
+	// 1s + 2s + 4s 的退避完全由假时钟推进
+	if !letter.DroppedAt.Equal(start.Add(7 * time.Second)) {
+		t.Errorf("DroppedAt 应使用假时钟: %v", letter.DroppedAt.Sub(start))
+	}
+}
+
+func TestHarnessSink(t *testing.T) {
+	records := make(chan sink.Record, 10)
+	h := newHarness(t, controller.Options[*coreV1.Pod]{
+		Name:      "harness-sink",
+		Reconcile: controller.SinkReconciler[*coreV1.Pod](sink.Channel(records)),
+		Diff:      true,
+	})
+
+	pod := newPod("web")
+	if err := h.Add(pod); err != nil {
+		t.Fatal(err)
+	}
+	h.RunUntilIdle()
+	updated := pod.DeepCopy()
+	updated.Labels["v"] = "2"
+	if err := h.Update(updated); err != nil {
+		t.Fatal(err)
+	}
+	h.RunUntilIdle()
+
+	close(records)
+	var got []sink.Record
+	for record := range records {
+		got = append(got, record)
+	}
+	if len(got) != 2 || got[0].Type != string(cache.Added) || got[1].Type != string(cache.Updated) {
+		t.Fatalf("sink 输出错误: %+v", got)
+	}
+	if len(got[1].Diff) != 1 || got[1].Diff[0].Path != "metadata.labels.v" {
+		t.Errorf("Updated 记录应包含差异: %v", got[1].Diff)
+	}
+}
+
+func TestHarnessRelist(t *testing.T) {
+	h := newHarness(t, controller.Options[*coreV1.Pod]{
+		Name:      "harness-relist",
+		Reconcile: func(context.Context, controller.Event[*coreV1.Pod]) error { return nil },
+	})
+	// 额外注册的 handler 与控制器收到相同的通知
+	var lock sync.Mutex
+	var notified []string
+	if _, err := h.Controller.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		DeleteFunc: func(obj interface{}) {
+			lock.Lock()
+			defer lock.Unlock()
+			_, tombstone := obj.(cache.DeletedFinalStateUnknown)
+			notified = append(notified, fmt.Sprintf("delete tombstone=%v", tombstone))
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	web, db := newPod("web"), newPod("db")
+	for _, pod := range []*coreV1.Pod{web, db} {
+		if err := h.Add(pod); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h.RunUntilIdle()
+
+	// watch 断开期间 web 被删除、cache 被创建，relist 后 web 以 tombstone 删除，db 收到 Replaced 通知
+	h.Source.DeleteDropWatch(web)
+	h.Source.AddDropWatch(newPod("cache"))
+	if err := h.Relist(); err != nil {
+		t.Fatal(err)
+	}
+	if n := h.RunUntilIdle(); n != 3 {
+		t.Fatalf("relist 后应处理 3 个 key，实际为 %d", n)
+	}
+	events := map[string]controller.Event[*coreV1.Pod]{}
+	for _, call := range h.Calls()[2:] {
+		events[call.Event.Key] = call.Event
+	}
+	if event := events["default/web"]; event.Type != cache.Deleted || !event.Tombstone {
+		t.Errorf("web 应以 tombstone 删除: %+v", event)
+	}
+	if event := events["default/db"]; event.Type != cache.Updated || event.Old.ResourceVersion != event.Object.ResourceVersion {
+		t.Errorf("db 应收到 resourceVersion 不变的 Replaced 通知: %+v", event)
+	}
+	if event := events["default/cache"]; event.Type != cache.Added {
+		t.Errorf("cache 应作为 Added 事件: %+v", event)
+	}
+
+	// informer 异步通知额外注册的 handler
+	eventually(t, func() error {
+		lock.Lock()
+		defer lock.Unlock()
+		if got := strings.Join(notified, ","); got != "delete tombstone=true" {
+			return fmt.Errorf("额外注册的 handler 应收到 tombstone: %s", got)
+		}
+		return nil
+	})
+}
+
+func TestNewHarnessValidates(t *testing.T) {
+	_, err := controllertest.NewHarness(controller.Options[*coreV1.Pod]{
+		Name:      "harness",
+		ListWatch: &cache.ListWatch{},
+		Reconcile: func(context.Context, controller.Event[*coreV1.Pod]) error { return nil },
+	})
+	if err == nil {
+		t.Error("设置 ListWatch 时应返回错误")
+	}
+	_, err = controllertest.NewHarness(controller.Options[*coreV1.Pod]{
+		Name:         "harness",
+		ResyncPeriod: time.Minute,
+		Reconcile:    func(context.Context, controller.Event[*coreV1.Pod]) error { return nil },
+	})
+	if err == nil {
+		t.Error("设置 ResyncPeriod 时应返回错误")
+	}
+	_, err = controllertest.NewHarness(controller.Options[*coreV1.Pod]{
+		Name:      "harness",
+		Notified:  func() {},
+		Reconcile: func(context.Context, controller.Event[*coreV1.Pod]) error { return nil },
+	})
+	if err == nil {
+		t.Error("设置 Notified 时应返回错误")
+	}
+}
@@ -0,0 +1,105 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"k8s-dev/pkg/controller"
+	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	fcache "k8s.io/client-go/tools/cache/testing"
+	"testing"
+	"time"
+)
+
+func newElected(t *testing.T, source *fcache.FakeControllerSource, client *fake.Clientset, identity string, calls chan<- string) *controller.Controller[*coreV1.Pod] {
+	t.Helper()
+	ctrl, err := controller.New(controller.Options[*coreV1.Pod]{
+		Name:      identity,
+		ListWatch: source,
+		Reconcile: func(_ context.Context, event controller.Event[*coreV1.Pod]) error {
+			calls <- identity + " " + event.Key
+			return nil
+		},
+		LeaderElection: &controller.LeaderElection{
+			Client:          client,
+			Name:            "pods",
+			Identity:        identity,
+			LeaseDuration:   time.Second,
+			RenewDeadline:   500 * time.Millisecond,
+			RetryPeriod:     100 * time.Millisecond,
+			ReleaseOnCancel: true,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ctrl
+}
+
+func TestLeaderElectionFailover(t *testing.T) {
+	source := fcache.NewFakeControllerSource()
+	client := fake.NewSimpleClientset()
+	calls := make(chan string, 10)
+
+	first := newElected(t, source, client, "first", calls)
+	firstCtx, stopFirst := context.WithCancel(context.Background())
+	firstResult := make(chan error, 1)
+	go func() { firstResult <- first.Run(firstCtx) }()
+	eventually(t, func() error {
+		if !first.IsLeader() {
+			return errors.New("first 应当选 leader")
+		}
+		return nil
+	})
+
+	second := newElected(t, source, client, "second", calls)
+	secondCtx, stopSecond := context.WithCancel(context.Background())
+	defer stopSecond()
+	go second.Run(secondCtx)
+
+	source.Add(newPod("web"))
+	select {
+	case c := <-calls:
+		if c != "first default/web" {
+			t.Errorf("只有 leader 处理事件: %s", c)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("等待 Reconcile 超时")
+	}
+	// 备用实例保持缓存同步
+	eventually(t, func() error {
+		if _, exists, _ := second.Indexer().GetByKey("default/web"); !exists || !second.HasSynced() {
+			return errors.New("备用实例的缓存应保持同步")
+		}
+		return nil
+	})
+	if second.IsLeader() {
+		t.Error("second 不应同时成为 leader")
+	}
+
+	// first 停止并释放 Lease，second 接管并处理积压的事件
+	stopFirst()
+	if err := <-firstResult; err != nil {
+		t.Errorf("主动停止不应返回错误: %v", err)
+	}
+	select {
+	case c := <-calls:
+		if c != "second default/web" {
+			t.Errorf("接管后应由 second 处理: %s", c)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("second 未接管")
+	}
+}
+
+func TestLeaderElectionValidates(t *testing.T) {
+	_, err := controller.New(controller.Options[*coreV1.Pod]{
+		Name:           "invalid",
+		ListWatch:      fcache.NewFakeControllerSource(),
+		Reconcile:      func(context.Context, controller.Event[*coreV1.Pod]) error { return nil },
+		LeaderElection: &controller.LeaderElection{Name: "pods"},
+	})
+	if err == nil {
+		t.Error("缺少 Client 应返回错误")
+	}
+}
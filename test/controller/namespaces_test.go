@@ -0,0 +1,131 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"k8s-dev/pkg/controller"
+	"k8s-dev/pkg/index"
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+	"reflect"
+	"testing"
+)
+
+func namespacedPod(namespace, name string) *coreV1.Pod {
+	return &coreV1.Pod{ObjectMeta: metaV1.ObjectMeta{Namespace: namespace, Name: name}}
+}
+
+func waitNamespaces(t *testing.T, ctrl *controller.Controller[*coreV1.Pod], want ...string) {
+	t.Helper()
+	eventually(t, func() error {
+		if got := ctrl.Namespaces(); !reflect.DeepEqual(got, want) || !ctrl.HasSynced() {
+			return fmt.Errorf("watch 的命名空间应为 %v，实际为 %v", want, got)
+		}
+		return nil
+	})
+}
+
+func TestNamespaceSelector(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client := fake.NewSimpleClientset(
+		&coreV1.Namespace{ObjectMeta: metaV1.ObjectMeta{Name: "a", Labels: map[string]string{"team": "x"}}},
+		&coreV1.Namespace{ObjectMeta: metaV1.ObjectMeta{Name: "b"}},
+		namespacedPod("a", "web"),
+		namespacedPod("b", "db"),
+	)
+	calls := make(chan call, 10)
+	// fake clientset 不会补发 watch 建立之前的事件，创建对象前需要等待 watch 建立
+	watching := make(chan string, 10)
+	ctrl, err := controller.New(controller.Options[*coreV1.Pod]{
+		Name: "namespaces",
+		Namespaces: &controller.NamespaceSelector{
+			Client:   client,
+			Selector: labels.SelectorFromSet(labels.Set{"team": "x"}),
+			ListWatch: func(namespace string) cache.ListerWatcher {
+				pods := client.CoreV1().Pods(namespace)
+				return &cache.ListWatch{
+					ListFunc: func(options metaV1.ListOptions) (runtime.Object, error) {
+						return pods.List(ctx, options)
+					},
+					WatchFunc: func(options metaV1.ListOptions) (watch.Interface, error) {
+						w, err := pods.Watch(ctx, options)
+						watching <- namespace
+						return w, err
+					},
+				}
+			},
+		},
+		Indexers: cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc},
+		Reconcile: func(_ context.Context, event controller.Event[*coreV1.Pod]) error {
+			calls <- record(event)
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go ctrl.Run(ctx)
+
+	waitNamespaces(t, ctrl, "a")
+	if c := waitCall(t, calls); c.key != "a/web" {
+		t.Fatalf("只应处理选中命名空间中的对象: %+v", c)
+	}
+
+	// b 加上标签后启动 informer，事件进入同一个队列
+	b, _ := client.CoreV1().Namespaces().Get(ctx, "b", metaV1.GetOptions{})
+	b.Labels = map[string]string{"team": "x"}
+	if _, err := client.CoreV1().Namespaces().Update(ctx, b, metaV1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	waitNamespaces(t, ctrl, "a", "b")
+	for receive(t, watching) != "b" {
+	}
+	if c := waitCall(t, calls); c.key != "b/db" {
+		t.Fatalf("应处理新选中命名空间中的对象: %+v", c)
+	}
+	keys, err := index.NewQuery[*coreV1.Pod](ctrl.Indexer()).InNamespace("b").Keys()
+	if err != nil || !reflect.DeepEqual(keys, []string{"b/db"}) {
+		t.Errorf("合并的缓存应支持索引查询: %v %v", keys, err)
+	}
+	if got := ctrl.Indexer().ListKeys(); !reflect.DeepEqual(got, []string{"a/web", "b/db"}) {
+		t.Errorf("合并的缓存应包含所有命名空间: %v", got)
+	}
+
+	// a 去掉标签后停止 informer，之后的事件不再处理
+	a, _ := client.CoreV1().Namespaces().Get(ctx, "a", metaV1.GetOptions{})
+	a.Labels = nil
+	if _, err := client.CoreV1().Namespaces().Update(ctx, a, metaV1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	waitNamespaces(t, ctrl, "b")
+	if _, exists, _ := ctrl.Indexer().GetByKey("a/web"); exists {
+		t.Error("停止 watch 的命名空间不应出现在缓存中")
+	}
+	if _, err := client.CoreV1().Pods("a").Create(ctx, namespacedPod("a", "late"), metaV1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.CoreV1().Pods("b").Create(ctx, namespacedPod("b", "late"), metaV1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if c := waitCall(t, calls); c.key != "b/late" {
+		t.Errorf("不应处理已去掉标签的命名空间: %+v", c)
+	}
+}
+
+func TestNamespaceSelectorValidates(t *testing.T) {
+	_, err := controller.New(controller.Options[*coreV1.Pod]{
+		Name:       "namespaces",
+		ListWatch:  &cache.ListWatch{},
+		Namespaces: &controller.NamespaceSelector{Client: fake.NewSimpleClientset()},
+		Reconcile:  func(context.Context, controller.Event[*coreV1.Pod]) error { return nil },
+	})
+	if err == nil {
+		t.Error("同时设置 ListWatch 和 Namespaces 时应返回错误")
+	}
+}
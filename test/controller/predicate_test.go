@@ -0,0 +1,185 @@
+package controller
+
+import (
+	"context"
+	"k8s-dev/pkg/controller"
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/cache"
+	fcache "k8s.io/client-go/tools/cache/testing"
+	"testing"
+	"time"
+)
+
+func added(obj *coreV1.Pod) controller.Event[runtime.Object] {
+	return controller.Event[runtime.Object]{Type: cache.Added, Key: obj.Namespace + "/" + obj.Name, Object: obj}
+}
+
+func updated(old, obj *coreV1.Pod) controller.Event[runtime.Object] {
+	return controller.Event[runtime.Object]{Type: cache.Updated, Key: obj.Namespace + "/" + obj.Name, Old: old, Object: obj}
+}
+
+func podWith(mutate func(pod *coreV1.Pod)) *coreV1.Pod {
+	pod := &coreV1.Pod{
+		ObjectMeta: metaV1.ObjectMeta{Name: "web", Namespace: "default", ResourceVersion: "1", Generation: 1, Labels: map[string]string{"app": "web"}},
+		Spec:       coreV1.PodSpec{Containers: []coreV1.Container{{Name: "web", Image: "nginx:1.23"}}},
+	}
+	if mutate != nil {
+		mutate(pod)
+	}
+	return pod
+}
+
+func TestNamespacePredicates(t *testing.T) {
+	pod := podWith(nil)
+	system := podWith(func(pod *coreV1.Pod) { pod.Namespace = "kube-system" })
+	if !controller.InNamespaces("default")(added(pod)) || controller.InNamespaces("default")(added(system)) {
+		t.Error("InNamespaces 结果不正确")
+	}
+	if controller.ExcludeNamespaces("default")(added(pod)) || !controller.ExcludeNamespaces("default")(added(system)) {
+		t.Error("ExcludeNamespaces 结果不正确")
+	}
+	// tombstone 中没有对象时按 key 判断
+	deleted := controller.Event[runtime.Object]{Type: cache.Deleted, Key: "default/web", Tombstone: true}
+	if !controller.InNamespaces("default")(deleted) {
+		t.Error("没有对象时应按 key 中的命名空间判断")
+	}
+}
+
+func TestLabelSelectorPredicate(t *testing.T) {
+	p := controller.LabelSelector(labels.SelectorFromSet(labels.Set{"app": "web"}))
+	other := podWith(func(pod *coreV1.Pod) { pod.Labels = map[string]string{"app": "db"} })
+	if !p(added(podWith(nil))) || p(added(other)) {
+		t.Error("LabelSelector 结果不正确")
+	}
+	if !p(updated(podWith(nil), other)) {
+		t.Error("标签被移除的变更应保留")
+	}
+}
+
+func TestHasAnnotationPredicate(t *testing.T) {
+	p := controller.HasAnnotation("k8s-dev/watch")
+	annotated := podWith(func(pod *coreV1.Pod) { pod.Annotations = map[string]string{"k8s-dev/watch": ""} })
+	if !p(added(annotated)) || p(added(podWith(nil))) {
+		t.Error("HasAnnotation 结果不正确")
+	}
+}
+
+func TestGenerationChangedPredicate(t *testing.T) {
+	p := controller.GenerationChanged()
+	old := podWith(nil)
+	if p(updated(old, podWith(func(pod *coreV1.Pod) { pod.ResourceVersion = "2" }))) {
+		t.Error("generation 没有变化时应丢弃")
+	}
+	if !p(updated(old, podWith(func(pod *coreV1.Pod) { pod.Generation = 2 }))) {
+		t.Error("generation 变化时应保留")
+	}
+	if !p(added(old)) {
+		t.Error("非 Updated 事件应保留")
+	}
+}
+
+func TestResourceVersionChangedPredicate(t *testing.T) {
+	p := controller.ResourceVersionChanged()
+	old := podWith(nil)
+	if p(updated(old, podWith(nil))) {
+		t.Error("resync 事件应丢弃")
+	}
+	if !p(updated(old, podWith(func(pod *coreV1.Pod) { pod.ResourceVersion = "2" }))) {
+		t.Error("resourceVersion 变化时应保留")
+	}
+}
+
+func TestSpecChangedPredicate(t *testing.T) {
+	p := controller.SpecChanged()
+	old := podWith(nil)
+	statusOnly := podWith(func(pod *coreV1.Pod) {
+		pod.ResourceVersion = "2"
+		pod.Labels["v"] = "2"
+		pod.Status.Phase = coreV1.PodRunning
+	})
+	if p(updated(old, statusOnly)) {
+		t.Error("只有 metadata 和 status 变化时应丢弃")
+	}
+	if !p(updated(old, podWith(func(pod *coreV1.Pod) { pod.Spec.Containers[0].Image = "nginx:1.24" }))) {
+		t.Error("spec 变化时应保留")
+	}
+
+	cm := func(value string) *coreV1.ConfigMap {
+		return &coreV1.ConfigMap{ObjectMeta: metaV1.ObjectMeta{Name: "app", Namespace: "default"}, Data: map[string]string{"k": value}}
+	}
+	event := controller.Event[runtime.Object]{Type: cache.Updated, Key: "default/app", Old: cm("1"), Object: cm("2")}
+	if !p(event) {
+		t.Error("没有 spec 的资源应比较其他顶层字段")
+	}
+}
+
+func TestCombinedPredicates(t *testing.T) {
+	yes := controller.Predicate(func(controller.Event[runtime.Object]) bool { return true })
+	no := controller.Not(yes)
+	event := added(podWith(nil))
+	for name, tc := range map[string]struct {
+		p    controller.Predicate
+		want bool
+	}{
+		"and":       {controller.And(yes, yes), true},
+		"and-false": {controller.And(yes, no), false},
+		"or":        {controller.Or(no, yes), true},
+		"or-false":  {controller.Or(no, no), false},
+		"not":       {controller.Not(no), true},
+		"empty-and": {controller.And(), true},
+		"custom": {func(event controller.Event[runtime.Object]) bool {
+			return event.Object.(*coreV1.Pod).Spec.Containers[0].Image == "nginx:1.23"
+		}, true},
+	} {
+		if got := tc.p(event); got != tc.want {
+			t.Errorf("%s: 期望 %v，实际 %v", name, tc.want, got)
+		}
+	}
+}
+
+func TestControllerPredicates(t *testing.T) {
+	source := fcache.NewFakeControllerSource()
+	calls := make(chan call, 10)
+	ctrl, err := controller.New(controller.Options[*coreV1.Pod]{
+		Name:      "predicates",
+		ListWatch: source,
+		Reconcile: func(_ context.Context, event controller.Event[*coreV1.Pod]) error {
+			calls <- record(event)
+			return nil
+		},
+		Predicates: []controller.Predicate{controller.ExcludeNamespaces("kube-system"), controller.SpecChanged()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go ctrl.Run(ctx)
+
+	system := newPod("dns")
+	system.Namespace = "kube-system"
+	source.Add(system)
+	pod := newPod("web")
+	source.Add(pod)
+	if c := waitCall(t, calls); c.key != "default/web" {
+		t.Errorf("被排除的命名空间不应处理: %+v", c)
+	}
+	// 只修改标签不应触发
+	pod = pod.DeepCopy()
+	pod.Labels["v"] = "2"
+	source.Modify(pod)
+	pod = pod.DeepCopy()
+	pod.Spec.NodeName = "node-1"
+	source.Modify(pod)
+	if c := waitCall(t, calls); c != (call{cache.Updated, "default/web", "2", "2"}) {
+		t.Errorf("只应处理 spec 的变更: %+v", c)
+	}
+	select {
+	case c := <-calls:
+		t.Errorf("未预期的调用: %+v", c)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
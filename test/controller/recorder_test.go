@@ -0,0 +1,132 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"k8s-dev/pkg/controller"
+	"k8s-dev/pkg/controller/controllertest"
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	toolsRecord "k8s.io/client-go/tools/record"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestReconcilerRecordsEvents(t *testing.T) {
+	recorder := controller.NewFakeRecorder()
+	h, err := controllertest.NewHarness(controller.Options[*coreV1.Pod]{
+		Name: "recorder",
+		Reconcile: func(ctx context.Context, event controller.Event[*coreV1.Pod]) error {
+			if event.Object.Name == "broken" {
+				return controller.Permanent(errors.New("image not found"))
+			}
+			controller.RecorderFrom(ctx).Eventf(event.Object, coreV1.EventTypeNormal, "Synced", "synced %s", event.Key)
+			return nil
+		},
+		EventRecorder: recorder,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	for _, name := range []string{"web", "broken"} {
+		if err := h.Add(newPod(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// RunUntilIdle 返回时控制器已处理完所有 key，包括放弃重试后记录的事件
+	if n := h.RunUntilIdle(); n != 2 {
+		t.Fatalf("应处理 2 个 key，实际为 %d", n)
+	}
+	var reasons []string
+	for _, event := range recorder.Events() {
+		reasons = append(reasons, event.Type+" "+event.Reason+" "+event.Object.(*coreV1.Pod).Name)
+	}
+	sort.Strings(reasons)
+	if got := strings.Join(reasons, ","); got != "Normal Synced web,Warning ReconcileFailed broken" {
+		t.Errorf("事件不正确: %s", got)
+	}
+
+	// 没有新的通知时不会再处理，也不会再记录事件
+	recorder.Reset()
+	if h.RunUntilIdle() != 0 || len(recorder.Events()) != 0 {
+		t.Errorf("不应再记录事件: %v", recorder.Events())
+	}
+}
+
+func TestRecorderFromWithoutRecorder(t *testing.T) {
+	// 没有配置 EventRecorder 时不应 panic
+	controller.RecorderFrom(context.Background()).Event(newPod("web"), coreV1.EventTypeNormal, "Synced", "synced")
+}
+
+func TestEventRecorderAggregates(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	recorder, stop, err := controller.NewEventRecorder(controller.EventRecorderOptions{Client: client, Component: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+
+	pod := newPod("web")
+	pod.UID = "web-uid"
+	for i := 0; i < 5; i++ {
+		recorder.Event(pod, coreV1.EventTypeWarning, "BackOff", "Back-off restarting failed container")
+	}
+	var events *coreV1.EventList
+	eventually(t, func() error {
+		var err error
+		if events, err = client.CoreV1().Events("default").List(context.Background(), metaV1.ListOptions{}); err != nil {
+			return err
+		}
+		if len(events.Items) != 1 || events.Items[0].Count != 5 {
+			return fmt.Errorf("相同的事件应合并为一条: %+v", events.Items)
+		}
+		return nil
+	})
+	if event := events.Items[0]; event.InvolvedObject.Name != "web" || event.Source.Component != "test" {
+		t.Errorf("事件内容不正确: %+v", event)
+	}
+}
+
+func TestEventRecorderSpamFilter(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	recorder, stop, err := controller.NewEventRecorder(controller.EventRecorderOptions{
+		Client:     client,
+		Component:  "test",
+		Correlator: toolsRecord.CorrelatorOptions{BurstSize: 2, QPS: 1.0 / 3600},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+
+	pod := newPod("web")
+	pod.UID = "web-uid"
+	for i := 0; i < 5; i++ {
+		recorder.Eventf(pod, coreV1.EventTypeWarning, "Failed", "attempt %d", i)
+	}
+	// 事件按顺序写入，另一个对象的事件写入后 web 的事件都已处理完
+	marker := newPod("marker")
+	marker.UID = "marker-uid"
+	recorder.Event(marker, coreV1.EventTypeNormal, "Done", "done")
+	var events *coreV1.EventList
+	eventually(t, func() error {
+		var err error
+		if events, err = client.CoreV1().Events("default").List(context.Background(), metaV1.ListOptions{}); err != nil {
+			return err
+		}
+		for _, event := range events.Items {
+			if event.InvolvedObject.Name == "marker" {
+				return nil
+			}
+		}
+		return errors.New("等待事件写入超时")
+	})
+	if len(events.Items) != 3 {
+		t.Errorf("超过突发上限的事件应被丢弃，web 应只写入 2 条: %d", len(events.Items)-1)
+	}
+}
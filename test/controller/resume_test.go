@@ -0,0 +1,130 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"k8s-dev/pkg/controller"
+	coreV1 "k8s.io/api/core/v1"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"testing"
+	"time"
+)
+
+// scriptedListWatch List 返回固定的列表，每次 Watch 返回一个由测试控制的 FakeWatcher
+type scriptedListWatch struct {
+	lists   chan metaV1.ListOptions
+	watches chan metaV1.ListOptions
+	watcher chan *watch.FakeWatcher
+}
+
+func newScriptedListWatch() *scriptedListWatch {
+	return &scriptedListWatch{
+		lists:   make(chan metaV1.ListOptions, 10),
+		watches: make(chan metaV1.ListOptions, 10),
+		watcher: make(chan *watch.FakeWatcher, 10),
+	}
+}
+
+func (lw *scriptedListWatch) List(options metaV1.ListOptions) (runtime.Object, error) {
+	lw.lists <- options
+	pod := newPod("a")
+	pod.ResourceVersion = "10"
+	return &coreV1.PodList{ListMeta: metaV1.ListMeta{ResourceVersion: "10"}, Items: []coreV1.Pod{*pod}}, nil
+}
+
+func (lw *scriptedListWatch) Watch(options metaV1.ListOptions) (watch.Interface, error) {
+	lw.watches <- options
+	w := watch.NewFakeWithChanSize(10, false)
+	lw.watcher <- w
+	return w, nil
+}
+
+func receive[T any](t *testing.T, ch <-chan T) T {
+	t.Helper()
+	select {
+	case v := <-ch:
+		return v
+	case <-time.After(5 * time.Second):
+		t.Fatal("等待超时")
+	}
+	panic("unreachable")
+}
+
+func waitStats(t *testing.T, ctrl *controller.Controller[*coreV1.Pod], done func(stats controller.WatchStats) bool) controller.WatchStats {
+	t.Helper()
+	var stats controller.WatchStats
+	eventually(t, func() error {
+		if stats = ctrl.WatchStats(); !done(stats) {
+			return fmt.Errorf("等待 WatchStats 超时: %+v", stats)
+		}
+		return nil
+	})
+	return stats
+}
+
+func TestWatchResume(t *testing.T) {
+	lw := newScriptedListWatch()
+	calls := make(chan call, 10)
+	ctrl, err := controller.New(controller.Options[*coreV1.Pod]{
+		Name:      "resume",
+		ListWatch: lw,
+		Reconcile: func(_ context.Context, event controller.Event[*coreV1.Pod]) error {
+			calls <- record(event)
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go ctrl.Run(ctx)
+
+	receive(t, lw.lists)
+	options := receive(t, lw.watches)
+	if !options.AllowWatchBookmarks || options.ResourceVersion != "10" {
+		t.Fatalf("应请求 bookmark 并从 List 的 resourceVersion 开始 watch: %+v", options)
+	}
+	w := receive(t, lw.watcher)
+	waitCall(t, calls)
+
+	// bookmark 推进 resourceVersion
+	added := newPod("b")
+	added.ResourceVersion = "11"
+	w.Add(added)
+	waitCall(t, calls)
+	w.Action(watch.Bookmark, &coreV1.Pod{ObjectMeta: metaV1.ObjectMeta{ResourceVersion: "15"}})
+	waitStats(t, ctrl, func(stats controller.WatchStats) bool { return stats.ResourceVersion == "15" && stats.Bookmarks == 1 })
+
+	// watch 出错后不重新 List，从 bookmark 的 resourceVersion 继续
+	w.Error(&apiErrors.NewInternalError(context.DeadlineExceeded).ErrStatus)
+	options = receive(t, lw.watches)
+	if options.ResourceVersion != "15" {
+		t.Errorf("应从 bookmark 的 resourceVersion 继续 watch: %s", options.ResourceVersion)
+	}
+	w = receive(t, lw.watcher)
+	select {
+	case <-lw.lists:
+		t.Fatal("watch 出错后不应向 ApiServer 发起 List")
+	default:
+	}
+	stats := ctrl.WatchStats()
+	if stats.Relists != 1 || stats.Resumes != 1 {
+		t.Errorf("应 List 1 次、恢复 1 次: %+v", stats)
+	}
+	if keys := ctrl.Indexer().ListKeys(); len(keys) != 2 {
+		t.Errorf("恢复后缓存应保留所有对象: %v", keys)
+	}
+
+	// 410 后重新 List
+	w.Error(&apiErrors.NewResourceExpired("too old resource version").ErrStatus)
+	receive(t, lw.lists)
+	receive(t, lw.watcher)
+	stats = waitStats(t, ctrl, func(stats controller.WatchStats) bool { return stats.Relists == 2 })
+	if stats.Expired != 1 || stats.Resumes != 1 || stats.ResourceVersion != "10" {
+		t.Errorf("410 后应重新 List: %+v", stats)
+	}
+}
@@ -0,0 +1,110 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"k8s-dev/pkg/controller"
+	coreV1 "k8s.io/api/core/v1"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/cache"
+	fcache "k8s.io/client-go/tools/cache/testing"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicies(t *testing.T) {
+	policy := controller.MaxRetries(2, controller.ExponentialBackoff(time.Millisecond, time.Second))
+	failed := errors.New("failed")
+	for i, want := range []time.Duration{time.Millisecond, 2 * time.Millisecond} {
+		if !policy.ShouldRetry("k", failed) {
+			t.Fatalf("第%d次失败应重试", i+1)
+		}
+		if delay := policy.When("k"); delay != want {
+			t.Errorf("第%d次重试的等待时间应为 %v，实际为 %v", i+1, want, delay)
+		}
+	}
+	if policy.ShouldRetry("k", failed) {
+		t.Error("超过最大重试次数后不应重试")
+	}
+	policy.Forget("k")
+	if !policy.ShouldRetry("k", failed) {
+		t.Error("Forget 后应重新计数")
+	}
+
+	if policy.ShouldRetry("k", controller.Permanent(failed)) {
+		t.Error("Permanent 错误不应重试")
+	}
+	if !errors.Is(controller.Permanent(failed), failed) {
+		t.Error("Permanent 应保留原始错误")
+	}
+
+	notFound := apiErrors.NewNotFound(schema.GroupResource{Resource: "configmaps"}, "app")
+	policy = controller.NoRetryOn(policy, apiErrors.IsNotFound)
+	if policy.ShouldRetry("k", fmt.Errorf("get: %w", notFound)) {
+		t.Error("NotFound 错误不应重试")
+	}
+	if !policy.ShouldRetry("k", failed) {
+		t.Error("其他错误应继续重试")
+	}
+}
+
+func TestDeadLetterRequeue(t *testing.T) {
+	source := fcache.NewFakeControllerSource()
+	calls := make(chan call, 10)
+	fail := make(chan bool, 10)
+	ctrl, err := controller.New(controller.Options[*coreV1.Pod]{
+		Name:      "deadletter",
+		ListWatch: source,
+		Reconcile: func(_ context.Context, event controller.Event[*coreV1.Pod]) error {
+			calls <- record(event)
+			if <-fail {
+				return errors.New("failed")
+			}
+			return nil
+		},
+		RetryPolicy: controller.MaxRetries(1, controller.ExponentialBackoff(time.Millisecond, time.Millisecond)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go ctrl.Run(ctx)
+
+	fail <- true
+	fail <- true
+	source.Add(newPod("web"))
+	waitCall(t, calls)
+	waitCall(t, calls)
+
+	var letter controller.DeadLetter[*coreV1.Pod]
+	eventually(t, func() error {
+		var ok bool
+		if letter, ok = ctrl.DeadLetters().Get("default/web"); !ok {
+			return errors.New("放弃重试的事件应进入死信存储")
+		}
+		return nil
+	})
+	if letter.Attempts != 2 || letter.LastError == nil || letter.Event.Type != cache.Added {
+		t.Errorf("死信内容不正确: %+v", letter)
+	}
+	if len(ctrl.DeadLetters().List()) != 1 {
+		t.Errorf("死信数量应为1: %v", ctrl.DeadLetters().List())
+	}
+
+	fail <- false
+	if err := ctrl.Requeue("default/web"); err != nil {
+		t.Fatal(err)
+	}
+	if c := waitCall(t, calls); c != (call{cache.Added, "default/web", "1", ""}) {
+		t.Errorf("重新入队应处理原始事件: %+v", c)
+	}
+	if _, ok := ctrl.DeadLetters().Get("default/web"); ok {
+		t.Error("重新入队后应从死信存储中移除")
+	}
+	if err := ctrl.Requeue("default/web"); err == nil {
+		t.Error("不存在的死信应返回错误")
+	}
+}
@@ -0,0 +1,94 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"k8s-dev/pkg/controller"
+	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+	fcache "k8s.io/client-go/tools/cache/testing"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func get(t *testing.T, url string) (int, string) {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(body)
+}
+
+// serverRuns 指标是进程级的，每次运行 TestServer 使用不同的控制器名称，-count 大于 1 时计数不会累加
+var serverRuns int32
+
+func TestServer(t *testing.T) {
+	name := fmt.Sprintf("metrics-%d", atomic.AddInt32(&serverRuns, 1))
+	source := fcache.NewFakeControllerSource()
+	calls := make(chan call, 10)
+	ctrl, err := controller.New(controller.Options[*coreV1.Pod]{
+		Name:      name,
+		ListWatch: source,
+		Reconcile: func(_ context.Context, event controller.Event[*coreV1.Pod]) error {
+			calls <- record(event)
+			if event.Type == cache.Added {
+				return controller.Permanent(errors.New("failed"))
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(controller.NewServer("", ctrl).Handler())
+	defer server.Close()
+
+	if code, _ := get(t, server.URL+"/healthz"); code != http.StatusOK {
+		t.Errorf("/healthz 应返回 200，实际为 %d", code)
+	}
+	if code, body := get(t, server.URL+"/readyz"); code != http.StatusServiceUnavailable || !strings.Contains(body, name) {
+		t.Errorf("缓存未同步时 /readyz 应返回 503: %d %s", code, body)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go ctrl.Run(ctx)
+	pod := newPod("web")
+	source.Add(pod)
+	waitCall(t, calls)
+	pod = pod.DeepCopy()
+	pod.Labels["v"] = "2"
+	source.Modify(pod)
+	waitCall(t, calls)
+
+	if code, _ := get(t, server.URL+"/readyz"); code != http.StatusOK {
+		t.Errorf("缓存同步后 /readyz 应返回 200，实际为 %d", code)
+	}
+	eventually(t, func() error {
+		_, body := get(t, server.URL+"/metrics")
+		for _, metric := range []string{
+			`controller_reconcile_total{controller="%s",result="success"} 1`,
+			`controller_reconcile_total{controller="%s",result="error"} 1`,
+			`controller_reconcile_duration_seconds_count{controller="%s"} 2`,
+			`controller_dead_letters_total{controller="%s"} 1`,
+			`workqueue_adds_total{name="%s"} 2`,
+			`workqueue_depth{name="%s"}`,
+		} {
+			if metric = fmt.Sprintf(metric, name); !strings.Contains(body, metric) {
+				return fmt.Errorf("/metrics 缺少 %s", metric)
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,323 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"k8s-dev/pkg/controller"
+	coordinationV1 "k8s.io/api/coordination/v1"
+	coreV1 "k8s.io/api/core/v1"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	fcache "k8s.io/client-go/tools/cache/testing"
+	"k8s.io/utils/clock"
+	clocktesting "k8s.io/utils/clock/testing"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRing(t *testing.T) {
+	keys := make([]string, 3000)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("default/pod-%d", i)
+	}
+	before := controller.NewRing([]string{"a", "b", "c"}, 0)
+	counts := map[string]int{}
+	for _, key := range keys {
+		counts[before.Owner(key)]++
+	}
+	for _, member := range before.Members() {
+		if n := counts[member]; n < len(keys)/5 || n > len(keys)/2 {
+			t.Errorf("%s 分到 %d 个 key，分布不均匀: %v", member, n, counts)
+		}
+	}
+
+	after := controller.NewRing([]string{"a", "b", "c", "d"}, 0)
+	moved := 0
+	for _, key := range keys {
+		if owner := after.Owner(key); owner != before.Owner(key) {
+			moved++
+			if owner != "d" {
+				t.Fatalf("%s 只应移动到新成员，实际为 %s", key, owner)
+			}
+		}
+	}
+	if moved > len(keys)*2/5 {
+		t.Errorf("新增成员后移动了 %d 个 key，应约为 1/4", moved)
+	}
+
+	if owner := controller.NewRing(nil, 0).Owner("default/web"); owner != "" {
+		t.Errorf("空的哈希环不应有 owner: %s", owner)
+	}
+}
+
+// shardCalls 记录每个副本处理过的 key
+type shardCalls struct {
+	lock  sync.Mutex
+	calls map[string][]string
+}
+
+func (s *shardCalls) add(identity, key string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.calls[key] = append(s.calls[key], identity)
+}
+
+func (s *shardCalls) snapshot() map[string][]string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	out := map[string][]string{}
+	for k, v := range s.calls {
+		out[k] = append([]string(nil), v...)
+	}
+	return out
+}
+
+func newSharded(t *testing.T, source *fcache.FakeControllerSource, client *fake.Clientset, clk clock.WithTicker, identity string, handoff time.Duration, calls *shardCalls) (*controller.Controller[*coreV1.Pod], *controller.Sharder) {
+	t.Helper()
+	sharder, err := controller.NewSharder(controller.ShardOptions{
+		Client:   client,
+		Group:    "pods",
+		Identity: identity,
+		// 测试期间 Lease 不会过期，成员只随 Run 退出而变化
+		LeaseDuration: time.Minute,
+		RenewInterval: 50 * time.Millisecond,
+		HandoffDelay:  handoff,
+		Clock:         clk,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctrl, err := controller.New(controller.Options[*coreV1.Pod]{
+		Name:      identity,
+		ListWatch: source,
+		Reconcile: func(_ context.Context, event controller.Event[*coreV1.Pod]) error {
+			calls.add(identity, event.Key)
+			return nil
+		},
+		Shard: sharder,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ctrl, sharder
+}
+
+// waitMembers 等待每个 sharder 的成员都变为 members
+func waitMembers(t *testing.T, members []string, sharders ...*controller.Sharder) {
+	t.Helper()
+	eventually(t, func() error {
+		for _, sharder := range sharders {
+			if got := sharder.Members(); !reflect.DeepEqual(got, members) {
+				return fmt.Errorf("%s 的成员应为 %v，实际为 %v", sharder.Identity(), members, got)
+			}
+		}
+		return nil
+	})
+}
+
+// waitSynced 等待每个 sharder 完成首次续约和刷新
+func waitSynced(t *testing.T, sharders ...*controller.Sharder) {
+	t.Helper()
+	eventually(t, func() error {
+		for _, sharder := range sharders {
+			if !sharder.HasSynced() {
+				return fmt.Errorf("等待 %s 加入分片组超时", sharder.Identity())
+			}
+		}
+		return nil
+	})
+}
+
+func TestShardedControllers(t *testing.T) {
+	source := fcache.NewFakeControllerSource()
+	client := fake.NewSimpleClientset()
+	clk := clocktesting.NewFakeClock(time.Now())
+	const pods = 20
+	for i := 0; i < pods; i++ {
+		source.Add(newPod(fmt.Sprintf("pod-%d", i)))
+	}
+	calls := &shardCalls{calls: map[string][]string{}}
+
+	a, sharderA := newSharded(t, source, client, clk, "a", 300*time.Millisecond, calls)
+	aCtx, stopA := context.WithCancel(context.Background())
+	defer stopA()
+	go a.Run(aCtx)
+	b, sharderB := newSharded(t, source, client, clk, "b", 300*time.Millisecond, calls)
+	bCtx, stopB := context.WithCancel(context.Background())
+	bResult := make(chan error, 1)
+	go func() { bResult <- b.Run(bCtx) }()
+
+	// 两个副本在交接完成前发现彼此，交接完成前不处理任何 key
+	waitSynced(t, sharderA, sharderB)
+	clk.Step(50 * time.Millisecond)
+	waitMembers(t, []string{"a", "b"}, sharderA, sharderB)
+	if got := calls.snapshot(); len(got) != 0 {
+		t.Fatalf("交接完成前不应处理 key: %v", got)
+	}
+
+	// 交接完成后每个 key 只由一个副本处理一次。时钟不再前进，之后不会有新的交接
+	clk.Step(300 * time.Millisecond)
+	var got map[string][]string
+	eventually(t, func() error {
+		if got = calls.snapshot(); len(got) != pods {
+			return fmt.Errorf("等待分片处理超时: %v", got)
+		}
+		return nil
+	})
+	ring := controller.NewRing([]string{"a", "b"}, 0)
+	owned := map[string]int{}
+	for key, identities := range got {
+		if len(identities) != 1 {
+			t.Errorf("%s 应只处理一次: %v", key, identities)
+		}
+		if want := ring.Owner(key); identities[0] != want {
+			t.Errorf("%s 应由 %s 处理，实际为 %s", key, want, identities[0])
+		}
+		owned[identities[0]]++
+	}
+	if owned["a"] == 0 || owned["b"] == 0 {
+		t.Fatalf("两个副本都应分到 key: %v", owned)
+	}
+
+	// b 退出并删除 Lease，a 在交接完成后接管 b 的 key
+	stopB()
+	if err := <-bResult; err != nil {
+		t.Fatal(err)
+	}
+	clk.Step(50 * time.Millisecond)
+	waitMembers(t, []string{"a"}, sharderA)
+	clk.Step(300 * time.Millisecond)
+	eventually(t, func() error {
+		got = calls.snapshot()
+		for key, identities := range got {
+			if identities[len(identities)-1] != "a" {
+				return fmt.Errorf("%s 应由 a 接管: %v", key, identities)
+			}
+		}
+		return nil
+	})
+	for key, identities := range got {
+		if len(identities) > 2 {
+			t.Errorf("%s 在交接后应只被 a 处理一次: %v", key, identities)
+		}
+	}
+
+	source.Add(newPod("late"))
+	eventually(t, func() error {
+		if got := calls.snapshot()["default/late"]; len(got) != 1 {
+			return fmt.Errorf("新的 key 应由 a 处理一次: %v", got)
+		}
+		return nil
+	})
+}
+
+func TestShardDeleteDuringHandoff(t *testing.T) {
+	source := fcache.NewFakeControllerSource()
+	client := fake.NewSimpleClientset()
+	clk := clocktesting.NewFakeClock(time.Now())
+	ring := controller.NewRing([]string{"a", "b"}, 0)
+	var moving string
+	for i := 0; moving == ""; i++ {
+		if key := fmt.Sprintf("default/pod-%d", i); ring.Owner(key) == "b" {
+			moving = key
+		}
+	}
+	_, name, _ := strings.Cut(moving, "/")
+	source.Add(newPod(name))
+	calls := &shardCalls{calls: map[string][]string{}}
+	processed := func(n int) func() error {
+		return func() error {
+			if got := calls.snapshot()[moving]; len(got) != n {
+				return fmt.Errorf("%s 应处理 %d 次: %v", moving, n, got)
+			}
+			return nil
+		}
+	}
+
+	a, sharderA := newSharded(t, source, client, clk, "a", 100*time.Millisecond, calls)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go a.Run(ctx)
+	waitSynced(t, sharderA)
+	clk.Step(100 * time.Millisecond)
+	eventually(t, processed(1))
+
+	// b 加入后 key 在交接期间不属于任何副本
+	b, sharderB := newSharded(t, source, client, clk, "b", 2*time.Second, calls)
+	go b.Run(ctx)
+	eventually(t, func() error {
+		if !b.HasSynced() {
+			return fmt.Errorf("等待 b 启动超时")
+		}
+		return nil
+	})
+	clk.Step(50 * time.Millisecond)
+	waitMembers(t, []string{"a", "b"}, sharderA, sharderB)
+	if sharderB.Owns(moving) {
+		t.Fatal("交接完成前 b 不应处理该 key")
+	}
+	source.Delete(newPod(name))
+	eventually(t, func() error {
+		if _, exists, _ := b.Indexer().GetByKey(moving); exists {
+			return fmt.Errorf("等待 b 收到删除事件超时")
+		}
+		return nil
+	})
+
+	// 交接完成后 b 重放删除事件，且只重放一次
+	clk.Step(2 * time.Second)
+	eventually(t, processed(2))
+	if got := calls.snapshot()[moving]; got[1] != "b" {
+		t.Errorf("删除事件应由 b 处理: %v", got)
+	}
+}
+
+func TestShardCollectsExpiredLeases(t *testing.T) {
+	clk := clocktesting.NewFakeClock(time.Now())
+	seconds := int32(10)
+	lease := func(name string, renewed time.Duration) *coordinationV1.Lease {
+		renewTime := metaV1.NewMicroTime(clk.Now().Add(-renewed))
+		return &coordinationV1.Lease{
+			ObjectMeta: metaV1.ObjectMeta{Name: name, Namespace: metaV1.NamespaceDefault, Labels: map[string]string{controller.ShardGroupLabel: "pods"}},
+			Spec: coordinationV1.LeaseSpec{
+				HolderIdentity:       &name,
+				LeaseDurationSeconds: &seconds,
+				RenewTime:            &renewTime,
+			},
+		}
+	}
+	// crashed 过期超过一个 LeaseDuration，expired 刚过期，可能只是续约延迟
+	client := fake.NewSimpleClientset(lease("crashed", 30*time.Second), lease("expired", 15*time.Second), lease("alive", time.Second))
+	sharder, err := controller.NewSharder(controller.ShardOptions{Client: client, Group: "pods", Identity: "self", Clock: clk})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go sharder.Run(ctx)
+	waitSynced(t, sharder)
+
+	if got := sharder.Members(); !reflect.DeepEqual(got, []string{"alive", "self"}) {
+		t.Errorf("过期的 Lease 不应是成员: %v", got)
+	}
+	leases := client.CoordinationV1().Leases(metaV1.NamespaceDefault)
+	if _, err := leases.Get(ctx, "crashed", metaV1.GetOptions{}); !apiErrors.IsNotFound(err) {
+		t.Errorf("过期超过 LeaseDuration 的 Lease 应被删除: %v", err)
+	}
+	if _, err := leases.Get(ctx, "expired", metaV1.GetOptions{}); err != nil {
+		t.Errorf("刚过期的 Lease 不应被删除: %v", err)
+	}
+}
+
+func TestNewSharderValidates(t *testing.T) {
+	if _, err := controller.NewSharder(controller.ShardOptions{Group: "pods"}); err == nil {
+		t.Error("缺少 Client 时应返回错误")
+	}
+	if _, err := controller.NewSharder(controller.ShardOptions{Client: fake.NewSimpleClientset()}); err == nil {
+		t.Error("缺少 Group 时应返回错误")
+	}
+}
@@ -0,0 +1,39 @@
+package controller
+
+import (
+	"context"
+	"k8s-dev/pkg/controller"
+	"k8s-dev/pkg/sink"
+	coreV1 "k8s.io/api/core/v1"
+	fcache "k8s.io/client-go/tools/cache/testing"
+	"testing"
+	"time"
+)
+
+func TestSinkReconciler(t *testing.T) {
+	source := fcache.NewFakeControllerSource()
+	records := make(chan sink.Record, 10)
+	ctrl, err := controller.New(controller.Options[*coreV1.Pod]{
+		Name:      "sink",
+		ListWatch: source,
+		Reconcile: controller.SinkReconciler[*coreV1.Pod](sink.Channel(records)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go ctrl.Run(ctx)
+
+	source.Add(newPod("web"))
+	select {
+	case record := <-records:
+		if record.Type != "Added" || record.Kind != "Pod" || record.Key != "default/web" ||
+			record.Namespace != "default" || record.Name != "web" || record.ResourceVersion == "" ||
+			record.Object == nil || record.Old != nil {
+			t.Errorf("记录内容不正确: %+v", record)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("等待记录超时")
+	}
+}
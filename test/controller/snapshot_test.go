@@ -0,0 +1,160 @@
+package controller
+
+import (
+	"context"
+	"k8s-dev/pkg/controller"
+	"k8s-dev/pkg/index"
+	coreV1 "k8s.io/api/core/v1"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+	fcache "k8s.io/client-go/tools/cache/testing"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingListWatch 记录 List 的次数，Watch 指定的 resourceVersion 时返回 410
+type countingListWatch struct {
+	cache.ListerWatcher
+	lists   atomic.Int32
+	expired string
+}
+
+func (lw *countingListWatch) List(options metaV1.ListOptions) (runtime.Object, error) {
+	lw.lists.Add(1)
+	return lw.ListerWatcher.List(options)
+}
+
+func (lw *countingListWatch) Watch(options metaV1.ListOptions) (watch.Interface, error) {
+	if lw.expired != "" && options.ResourceVersion == lw.expired {
+		return nil, apiErrors.NewResourceExpired("too old resource version")
+	}
+	return lw.ListerWatcher.Watch(options)
+}
+
+func runUntilKeys(t *testing.T, opts controller.Options[*coreV1.Pod], calls <-chan call, want int) (*controller.Controller[*coreV1.Pod], context.CancelFunc, <-chan error) {
+	t.Helper()
+	ctrl, err := controller.New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error, 1)
+	go func() { result <- ctrl.Run(ctx) }()
+	for i := 0; i < want; i++ {
+		waitCall(t, calls)
+	}
+	return ctrl, cancel, result
+}
+
+func TestSnapshotWarmStart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pods.json")
+	source := fcache.NewFakeControllerSource()
+	source.Add(newPod("a"))
+	source.Add(newPod("b"))
+
+	calls := make(chan call, 10)
+	reconcile := func(_ context.Context, event controller.Event[*coreV1.Pod]) error {
+		calls <- record(event)
+		return nil
+	}
+	_, cancel, result := runUntilKeys(t, controller.Options[*coreV1.Pod]{
+		Name:      "snapshot",
+		ListWatch: source,
+		Reconcile: reconcile,
+		Snapshot:  &controller.SnapshotOptions{Path: path, SaveOnShutdown: true},
+	}, calls, 2)
+	cancel()
+	if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+
+	// 离线加载快照并查询
+	snapshot, err := controller.LoadSnapshot[*coreV1.Pod](path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.ResourceVersion != "2" || len(snapshot.Items) != 2 {
+		t.Fatalf("快照内容不正确: rv=%s items=%d", snapshot.ResourceVersion, len(snapshot.Items))
+	}
+	indexer, err := snapshot.Indexer(index.PodIndexers())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pods, _ := index.NewQuery[*coreV1.Pod](indexer).InNamespace("default").List(); len(pods) != 2 {
+		t.Errorf("离线查询结果不正确: %d", len(pods))
+	}
+
+	// 停止期间新增的对象通过 watch 补齐，不需要 List
+	source.Add(newPod("c"))
+	lw := &countingListWatch{ListerWatcher: source}
+	ctrl, cancel, _ := runUntilKeys(t, controller.Options[*coreV1.Pod]{
+		Name:      "snapshot",
+		ListWatch: lw,
+		Reconcile: reconcile,
+		Snapshot:  &controller.SnapshotOptions{Path: path},
+	}, calls, 3)
+	defer cancel()
+	if n := lw.lists.Load(); n != 0 {
+		t.Errorf("从快照启动时不应 List，实际 List %d 次", n)
+	}
+	if keys := ctrl.Indexer().ListKeys(); len(keys) != 3 {
+		t.Errorf("缓存应包含3个对象: %v", keys)
+	}
+}
+
+func TestSnapshotExpired(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pods.json")
+	stale := &controller.Snapshot[*coreV1.Pod]{ResourceVersion: "1", Items: []*coreV1.Pod{newPod("stale")}}
+	if err := stale.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	source := fcache.NewFakeControllerSource()
+	source.Add(newPod("a"))
+	source.Add(newPod("b"))
+
+	calls := make(chan call, 10)
+	lw := &countingListWatch{ListerWatcher: source, expired: "1"}
+	ctrl, err := controller.New(controller.Options[*coreV1.Pod]{
+		Name:      "expired",
+		ListWatch: lw,
+		Reconcile: func(_ context.Context, event controller.Event[*coreV1.Pod]) error {
+			calls <- record(event)
+			return nil
+		},
+		Snapshot: &controller.SnapshotOptions{Path: path},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go ctrl.Run(ctx)
+
+	// 先收到快照中的对象，410 后重新 List，快照中已不存在的对象被删除
+	seen := map[call]bool{}
+	for deadline := time.Now().Add(10 * time.Second); len(seen) < 4; {
+		if time.Now().After(deadline) {
+			t.Fatalf("重新 List 后的事件不完整: %v", seen)
+		}
+		c := waitCall(t, calls)
+		seen[call{action: c.action, key: c.key}] = true
+	}
+	for _, want := range []call{
+		{action: cache.Added, key: "default/stale"},
+		{action: cache.Deleted, key: "default/stale"},
+		{action: cache.Added, key: "default/a"},
+		{action: cache.Added, key: "default/b"},
+	} {
+		if !seen[want] {
+			t.Errorf("缺少事件 %+v: %v", want, seen)
+		}
+	}
+	if n := lw.lists.Load(); n != 1 {
+		t.Errorf("410 后应 List 一次，实际 %d 次", n)
+	}
+}
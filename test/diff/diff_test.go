@@ -0,0 +1,81 @@
+package diff
+
+import (
+	"k8s-dev/pkg/diff"
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"strings"
+	"testing"
+)
+
+func newPod() *coreV1.Pod {
+	return &coreV1.Pod{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:            "web",
+			Namespace:       "default",
+			ResourceVersion: "1",
+			Labels:          map[string]string{"app.kubernetes.io/name": "web"},
+			ManagedFields:   []metaV1.ManagedFieldsEntry{{Manager: "kubectl"}},
+		},
+		Spec: coreV1.PodSpec{Containers: []coreV1.Container{{Name: "web", Image: "nginx:1.23"}}},
+	}
+}
+
+func TestObjects(t *testing.T) {
+	old := newPod()
+	pod := newPod()
+	pod.ResourceVersion = "2"
+	pod.ManagedFields = append(pod.ManagedFields, metaV1.ManagedFieldsEntry{Manager: "kubelet"})
+	pod.Labels["app.kubernetes.io/name"] = "api"
+	pod.Labels["tier"] = "backend"
+	pod.Spec.Containers[0].Image = "nginx:1.24"
+	pod.Spec.Containers = append(pod.Spec.Containers, coreV1.Container{Name: "envoy", Image: "envoy:1.25"})
+	pod.Status.Phase = coreV1.PodRunning
+
+	changes, err := diff.Objects(old, pod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, c := range changes {
+		got = append(got, c.Path)
+	}
+	want := []string{
+		`metadata.labels["app.kubernetes.io/name"]`,
+		`metadata.labels.tier`,
+		`spec.containers[0].image`,
+		`spec.containers[1]`,
+		`status.phase`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("差异路径不正确:\n%s", strings.Join(got, "\n"))
+	}
+	if s := changes[2].String(); s != `spec.containers[0].image: "nginx:1.23" -> "nginx:1.24"` {
+		t.Errorf("格式不正确: %s", s)
+	}
+	if changes[1].Old != nil || changes[1].New != "backend" {
+		t.Errorf("新增字段的 Old 应为空: %+v", changes[1])
+	}
+	if added, ok := changes[3].New.(map[string]interface{}); !ok || added["name"] != "envoy" {
+		t.Errorf("新增的列表元素应整体输出: %+v", changes[3])
+	}
+}
+
+func TestIgnore(t *testing.T) {
+	old := newPod()
+	pod := newPod()
+	pod.Labels["tier"] = "backend"
+	pod.Spec.Containers[0].Image = "nginx:1.24"
+
+	changes, err := diff.Objects(old, pod, "metadata.labels", "spec.containers[0]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 0 {
+		t.Errorf("忽略的字段及其子字段不应输出: %v", changes)
+	}
+
+	if changes := diff.Maps(map[string]interface{}{"a": int64(1)}, map[string]interface{}{"a": int64(1)}); len(changes) != 0 {
+		t.Errorf("相同内容不应有差异: %v", changes)
+	}
+}
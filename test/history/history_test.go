@@ -0,0 +1,245 @@
+package history
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"k8s-dev/pkg/history"
+	"k8s-dev/pkg/sink"
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+	fcache "k8s.io/client-go/tools/cache/testing"
+	clocktesting "k8s.io/utils/clock/testing"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var start = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func newPod(uid, rv, version string) *coreV1.Pod {
+	return &coreV1.Pod{ObjectMeta: metaV1.ObjectMeta{
+		Namespace:       "default",
+		Name:            "web",
+		UID:             types.UID(uid),
+		ResourceVersion: rv,
+		Labels:          map[string]string{"v": version},
+	}}
+}
+
+func newRecord(action string, pod *coreV1.Pod, offset time.Duration) sink.Record {
+	return sink.Record{
+		Time:            start.Add(offset),
+		Type:            action,
+		Kind:            "Pod",
+		Key:             pod.Namespace + "/" + pod.Name,
+		Namespace:       pod.Namespace,
+		Name:            pod.Name,
+		UID:             string(pod.UID),
+		ResourceVersion: pod.ResourceVersion,
+		Object:          pod,
+	}
+}
+
+func open(t *testing.T, opts history.Options) *history.Store {
+	t.Helper()
+	if opts.Path == "" {
+		opts.Path = filepath.Join(t.TempDir(), "history.db")
+	}
+	store, err := history.Open(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+	return store
+}
+
+func send(t *testing.T, store *history.Store, records ...sink.Record) {
+	t.Helper()
+	for _, record := range records {
+		if err := store.Send(context.Background(), record); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func summary(revs []history.Revision) string {
+	var names []string
+	for _, rev := range revs {
+		names = append(names, rev.Type+":"+rev.ResourceVersion)
+	}
+	return strings.Join(names, ",")
+}
+
+func label(t *testing.T, rev history.Revision) string {
+	t.Helper()
+	var pod coreV1.Pod
+	if err := json.Unmarshal(rev.Object, &pod); err != nil {
+		t.Fatal(err)
+	}
+	return pod.Labels["v"]
+}
+
+func TestHistory(t *testing.T) {
+	store := open(t, history.Options{})
+	send(t, store,
+		newRecord("Added", newPod("uid-1", "1", "1"), 0),
+		newRecord("Updated", newPod("uid-1", "2", "2"), time.Minute),
+		// resync 的重复版本只保存一次
+		newRecord("Sync", newPod("uid-1", "2", "2"), 2*time.Minute),
+		newRecord("Deleted", newPod("uid-1", "2", "2"), 3*time.Minute),
+		// 同名重建
+		newRecord("Added", newPod("uid-2", "5", "3"), 4*time.Minute),
+	)
+
+	revs, err := store.History("Pod", "default/web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := summary(revs); got != "Added:1,Updated:2,Deleted:2,Added:5" {
+		t.Errorf("历史版本不正确: %s", got)
+	}
+	if revs, _ := store.Revisions("uid-1"); len(revs) != 3 {
+		t.Errorf("按 uid 查询应只返回该对象的版本: %s", summary(revs))
+	}
+
+	for _, tc := range []struct {
+		at     time.Duration
+		exists bool
+		label  string
+	}{
+		{at: -time.Minute},
+		{at: 90 * time.Second, exists: true, label: "2"},
+		{at: 3*time.Minute + 30*time.Second},
+		{at: time.Hour, exists: true, label: "3"},
+	} {
+		rev, exists, err := store.At("Pod", "default/web", start.Add(tc.at))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if exists != tc.exists || (exists && label(t, rev) != tc.label) {
+			t.Errorf("%s 时对象应为 %v/%s，实际为 %v/%+v", tc.at, tc.exists, tc.label, exists, rev)
+		}
+	}
+
+	changes, err := store.Changes(start.Add(time.Minute), start.Add(4*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := summary(changes); got != "Updated:2,Deleted:2" {
+		t.Errorf("时间段内的变更不正确: %s", got)
+	}
+}
+
+func TestHistoryRetention(t *testing.T) {
+	clock := clocktesting.NewFakeClock(start.Add(time.Hour))
+	store := open(t, history.Options{MaxRevisions: 2, MaxAge: 30 * time.Minute, Clock: clock})
+	send(t, store,
+		newRecord("Added", newPod("uid-1", "1", "1"), 0),
+		newRecord("Updated", newPod("uid-1", "2", "2"), time.Minute),
+		newRecord("Updated", newPod("uid-1", "3", "3"), 40*time.Minute),
+	)
+	other := newPod("uid-2", "9", "1")
+	other.Name = "db"
+	send(t, store, newRecord("Added", other, 10*time.Minute))
+
+	if revs, _ := store.History("Pod", "default/web"); summary(revs) != "Updated:2,Updated:3" {
+		t.Errorf("超出 MaxRevisions 的旧版本应被删除: %s", summary(revs))
+	}
+	n, err := store.Prune()
+	if err != nil || n != 2 {
+		t.Fatalf("应删除 2 个超过 MaxAge 的版本: %d %v", n, err)
+	}
+	if revs, _ := store.History("Pod", "default/web"); summary(revs) != "Updated:3" {
+		t.Errorf("应只保留未过期的版本: %s", summary(revs))
+	}
+	if revs, _ := store.History("Pod", "default/db"); len(revs) != 0 {
+		t.Errorf("所有版本都过期的对象不应再出现: %s", summary(revs))
+	}
+	// 删除的版本重新出现时再次保存
+	send(t, store, newRecord("Sync", other, time.Hour))
+	if revs, _ := store.History("Pod", "default/db"); summary(revs) != "Sync:9" {
+		t.Errorf("清理后应能重新保存: %s", summary(revs))
+	}
+}
+
+func TestHistoryReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.db")
+	store := open(t, history.Options{Path: path})
+	send(t, store, newRecord("Added", newPod("uid-1", "1", "1"), 0))
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	store = open(t, history.Options{Path: path, ReadOnly: true})
+	revs, err := store.History("Pod", "default/web")
+	if err != nil || len(revs) != 1 {
+		t.Fatalf("重新打开后应保留历史: %v %v", revs, err)
+	}
+	var buf bytes.Buffer
+	if err := history.WriteObject(&buf, revs[0], history.FormatYAML); err != nil || !strings.Contains(buf.String(), "name: web") {
+		t.Errorf("应以 YAML 输出对象: %s %v", buf.String(), err)
+	}
+	buf.Reset()
+	if err := history.WriteRevisions(&buf, revs, history.FormatText); err != nil || !strings.Contains(buf.String(), "2026-01-01T00:00:00Z   Added   Pod    default/web") {
+		t.Errorf("版本列表输出不正确:\n%s %v", buf.String(), err)
+	}
+}
+
+func TestHistoryReadWhileRecording(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.db")
+	writer := open(t, history.Options{Path: path})
+	reader := open(t, history.Options{Path: path, ReadOnly: true})
+	send(t, writer, newRecord("Added", newPod("uid-1", "1", "1"), 0))
+	if revs, err := reader.History("Pod", "default/web"); err != nil || len(revs) != 1 {
+		t.Fatalf("记录期间应能查询: %v %v", revs, err)
+	}
+	send(t, writer, newRecord("Updated", newPod("uid-1", "2", "2"), time.Minute))
+	if revs, err := reader.Changes(start, start.Add(time.Hour)); err != nil || summary(revs) != "Added:1,Updated:2" {
+		t.Errorf("查询应看到最新的写入: %s %v", summary(revs), err)
+	}
+}
+
+func TestHistoryRecorder(t *testing.T) {
+	clock := clocktesting.NewFakeClock(start)
+	store := open(t, history.Options{Clock: clock})
+	recorder := history.NewRecorder(store)
+	source := fcache.NewFakeControllerSource()
+	informer := cache.NewSharedIndexInformer(source, &coreV1.Pod{}, 0, cache.Indexers{})
+	if _, err := informer.AddEventHandler(recorder.Handler()); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go informer.Run(ctx.Done())
+	go recorder.Run(ctx)
+	if !cache.WaitForCacheSync(ctx.Done(), informer.HasSynced) {
+		t.Fatal("informer 未同步")
+	}
+
+	// 连续的更新不会被合并，resourceVersion 由事件源分配
+	source.Add(newPod("uid-1", "", "1"))
+	source.Modify(newPod("uid-1", "", "2"))
+	source.Modify(newPod("uid-1", "", "3"))
+	source.Delete(newPod("uid-1", "", "3"))
+
+	var revs []history.Revision
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		var err error
+		if revs, err = store.History("Pod", "default/web"); err != nil {
+			t.Fatal(err)
+		}
+		if len(revs) == 4 || time.Now().After(deadline) {
+			break
+		}
+	}
+	if got := summary(revs); got != "Added:1,Updated:2,Updated:3,Deleted:4" {
+		t.Fatalf("应保存 informer 观察到的每次变更: %s", got)
+	}
+	if !revs[1].Time.Equal(start) || label(t, revs[1]) != "2" {
+		t.Errorf("版本应使用观察到的时间和当时的对象: %v %s", revs[1].Time, label(t, revs[1]))
+	}
+}
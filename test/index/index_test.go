@@ -0,0 +1,122 @@
+package index
+
+import (
+	"k8s-dev/pkg/index"
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+	"testing"
+)
+
+func newPod(ns, name, node, owner string, phase coreV1.PodPhase, lbls map[string]string, images ...string) *coreV1.Pod {
+	pod := &coreV1.Pod{
+		ObjectMeta: metaV1.ObjectMeta{Namespace: ns, Name: name, Labels: lbls},
+		Spec:       coreV1.PodSpec{NodeName: node},
+		Status:     coreV1.PodStatus{Phase: phase},
+	}
+	if owner != "" {
+		pod.OwnerReferences = []metaV1.OwnerReference{{Kind: "ReplicaSet", Name: owner, UID: types.UID(owner + "-uid")}}
+	}
+	for _, image := range images {
+		pod.Spec.Containers = append(pod.Spec.Containers, coreV1.Container{Image: image})
+	}
+	return pod
+}
+
+func newIndexer(t *testing.T) cache.Indexer {
+	t.Helper()
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, index.PodIndexers())
+	for _, pod := range []*coreV1.Pod{
+		newPod("default", "web-1", "node-1", "web", coreV1.PodRunning, map[string]string{"app": "web", "tier": "frontend"}, "nginx:1.23", "envoy:1.25"),
+		newPod("default", "web-2", "node-2", "web", coreV1.PodRunning, map[string]string{"app": "web", "tier": "frontend"}, "nginx:1.23"),
+		newPod("default", "db-1", "node-1", "db", coreV1.PodPending, map[string]string{"app": "db"}, "mysql:8"),
+		newPod("kube-system", "dns-1", "node-1", "dns", coreV1.PodRunning, map[string]string{"app": "dns"}, "coredns:1.9", "coredns:1.9"),
+		newPod("default", "pending", "", "", coreV1.PodPending, nil, "nginx:1.23"),
+	} {
+		if err := indexer.Add(pod); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return indexer
+}
+
+func names(pods []*coreV1.Pod) []string {
+	var result []string
+	for _, pod := range pods {
+		result = append(result, pod.Namespace+"/"+pod.Name)
+	}
+	return result
+}
+
+func TestIndexers(t *testing.T) {
+	indexer := newIndexer(t)
+	for _, tc := range []struct {
+		index, value string
+		want         int
+	}{
+		{index.PodNode, "node-1", 3},
+		{index.PodPhase, string(coreV1.PodPending), 2},
+		{index.PodImage, "nginx:1.23", 3},
+		{index.PodImage, "coredns:1.9", 1},
+		{index.Owner, "web-uid", 2},
+		{index.Label, index.LabelValue("app", "web"), 2},
+		{cache.NamespaceIndex, "kube-system", 1},
+	} {
+		pods, err := index.ByIndex[*coreV1.Pod](indexer, tc.index, tc.value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pods) != tc.want {
+			t.Errorf("%s=%s 应有 %d 个 pod，实际为 %v", tc.index, tc.value, tc.want, names(pods))
+		}
+	}
+}
+
+func TestQuery(t *testing.T) {
+	indexer := newIndexer(t)
+
+	// node-1 上属于 web 的 pod
+	pods, err := index.NewQuery[*coreV1.Pod](indexer).Where(index.PodNode, "node-1").OwnedBy("web-uid").List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := names(pods); len(got) != 1 || got[0] != "default/web-1" {
+		t.Errorf("交集查询结果不正确: %v", got)
+	}
+
+	selector, _ := labels.Parse("app in (web,db),tier!=frontend")
+	pods, err = index.NewQuery[*coreV1.Pod](indexer).InNamespace("default").Selector(selector).List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := names(pods); len(got) != 1 || got[0] != "default/db-1" {
+		t.Errorf("标签选择器查询结果不正确: %v", got)
+	}
+
+	pods, _ = index.NewQuery[*coreV1.Pod](indexer).Selector(labels.SelectorFromSet(labels.Set{"app": "dns"})).List()
+	if got := names(pods); len(got) != 1 || got[0] != "kube-system/dns-1" {
+		t.Errorf("没有索引条件时应查询所有对象: %v", got)
+	}
+
+	pods, _ = index.NewQuery[*coreV1.Pod](indexer).Where(index.PodNode, "node-2").Where(index.PodPhase, string(coreV1.PodPending)).List()
+	if len(pods) != 0 {
+		t.Errorf("交集为空时不应返回对象: %v", names(pods))
+	}
+
+	if _, err := index.NewQuery[*coreV1.Pod](indexer).Where("unknown", "x").List(); err == nil {
+		t.Error("未注册的索引应返回错误")
+	}
+
+	// 只实现 Reader 的缓存按对象计算 key
+	pods, _ = index.NewQuery[*coreV1.Pod](readerOnly{indexer}).Selector(labels.SelectorFromSet(labels.Set{"app": "dns"})).List()
+	if got := names(pods); len(got) != 1 || got[0] != "kube-system/dns-1" {
+		t.Errorf("没有 ListKeys 时应按对象计算 key: %v", got)
+	}
+}
+
+// readerOnly 隐藏 cache.Indexer 除 Reader 之外的方法
+type readerOnly struct {
+	index.Reader
+}
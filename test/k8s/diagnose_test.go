@@ -0,0 +1,171 @@
+package k8s
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	dev "k8s-dev/pkg/k8s"
+	coreV1 "k8s.io/api/core/v1"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"strings"
+	"testing"
+)
+
+func TestDiagnoseMissingReferences(t *testing.T) {
+	pod := &coreV1.Pod{
+		ObjectMeta: metaV1.ObjectMeta{Name: "web", Namespace: dev.DefaultNamespace, UID: "uid-1"},
+		Spec: coreV1.PodSpec{
+			Volumes: []coreV1.Volume{
+				{Name: "conf", VolumeSource: coreV1.VolumeSource{ConfigMap: &coreV1.ConfigMapVolumeSource{LocalObjectReference: coreV1.LocalObjectReference{Name: "web-conf"}}}},
+				{Name: "data", VolumeSource: coreV1.VolumeSource{PersistentVolumeClaim: &coreV1.PersistentVolumeClaimVolumeSource{ClaimName: "web-data"}}},
+			},
+			Containers: []coreV1.Container{{
+				Name:  "app",
+				Image: "nginx:1.14.2",
+				Env: []coreV1.EnvVar{{Name: "TOKEN", ValueFrom: &coreV1.EnvVarSource{
+					SecretKeyRef: &coreV1.SecretKeySelector{LocalObjectReference: coreV1.LocalObjectReference{Name: "web-token"}, Key: "token"},
+				}}},
+			}},
+		},
+		Status: coreV1.PodStatus{
+			Phase: coreV1.PodPending,
+			ContainerStatuses: []coreV1.ContainerStatus{{
+				Name:  "app",
+				State: coreV1.ContainerState{Waiting: &coreV1.ContainerStateWaiting{Reason: "CreateContainerConfigError", Message: `secret "web-token" not found`}},
+			}},
+		},
+	}
+	client := fake.NewSimpleClientset(
+		pod,
+		&coreV1.ConfigMap{ObjectMeta: metaV1.ObjectMeta{Name: "web-conf", Namespace: dev.DefaultNamespace}},
+		&coreV1.PersistentVolumeClaim{
+			ObjectMeta: metaV1.ObjectMeta{Name: "web-data", Namespace: dev.DefaultNamespace},
+			Status:     coreV1.PersistentVolumeClaimStatus{Phase: coreV1.ClaimPending},
+		},
+		&coreV1.Event{
+			ObjectMeta:     metaV1.ObjectMeta{Name: "web.1", Namespace: dev.DefaultNamespace},
+			InvolvedObject: coreV1.ObjectReference{Kind: "Pod", Name: "web", UID: "uid-1"},
+			Type:           coreV1.EventTypeWarning,
+			Reason:         "FailedMount",
+			Message:        "Unable to attach or mount volumes",
+		},
+	)
+
+	diagnosis, err := dev.DiagnosePod(context.TODO(), client, dev.DefaultNamespace, "web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	top := diagnosis.Top()
+	if top == nil || top.Reason != "MissingSecret" || top.Score != dev.ScoreDefinite {
+		t.Fatalf("最可能的原因应为 MissingSecret: %+v", diagnosis.Causes)
+	}
+	reasons := map[string]bool{}
+	for _, cause := range diagnosis.Causes {
+		reasons[cause.Reason] = true
+	}
+	for _, reason := range []string{"CreateContainerConfigError", "PVCNotBound", "FailedMount"} {
+		if !reasons[reason] {
+			t.Errorf("缺少原因 %s: %+v", reason, diagnosis.Causes)
+		}
+	}
+	if reasons["MissingConfigMap"] {
+		t.Error("ConfigMap 存在，不应报告缺失")
+	}
+}
+
+func TestDiagnoseOOMKilled(t *testing.T) {
+	pod := &coreV1.Pod{
+		ObjectMeta: metaV1.ObjectMeta{Name: "worker", Namespace: dev.DefaultNamespace},
+		Status: coreV1.PodStatus{
+			Phase: coreV1.PodRunning,
+			ContainerStatuses: []coreV1.ContainerStatus{{
+				Name:                 "app",
+				RestartCount:         4,
+				State:                coreV1.ContainerState{Waiting: &coreV1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+				LastTerminationState: coreV1.ContainerState{Terminated: &coreV1.ContainerStateTerminated{Reason: "OOMKilled", ExitCode: 137}},
+			}},
+		},
+	}
+	diagnosis, err := dev.Diagnose(context.TODO(), fake.NewSimpleClientset(), pod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diagnosis.Causes) != 2 || diagnosis.Causes[0].Reason != "OOMKilled" || diagnosis.Causes[1].Reason != "CrashLoopBackOff" {
+		t.Fatalf("原因排序不符合预期: %+v", diagnosis.Causes)
+	}
+
+	var buf bytes.Buffer
+	if err := dev.WriteDiagnosis(&buf, diagnosis); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "exitCode=137") {
+		t.Errorf("输出缺少退出码证据: %s", buf.String())
+	}
+}
+
+func TestDiagnoseForbiddenReference(t *testing.T) {
+	pod := &coreV1.Pod{
+		ObjectMeta: metaV1.ObjectMeta{Name: "web", Namespace: dev.DefaultNamespace},
+		Spec: coreV1.PodSpec{
+			Volumes: []coreV1.Volume{
+				{Name: "conf", VolumeSource: coreV1.VolumeSource{ConfigMap: &coreV1.ConfigMapVolumeSource{LocalObjectReference: coreV1.LocalObjectReference{Name: "web-conf"}}}},
+				{Name: "token", VolumeSource: coreV1.VolumeSource{Secret: &coreV1.SecretVolumeSource{SecretName: "web-token"}}},
+			},
+		},
+		Status: coreV1.PodStatus{Phase: coreV1.PodPending},
+	}
+	client := fake.NewSimpleClientset(pod)
+	// 没有读取 Secret 的权限
+	client.PrependReactor("get", "secrets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apiErrors.NewForbidden(schema.GroupResource{Resource: "secrets"}, "web-token", errors.New("rbac"))
+	})
+
+	diagnosis, err := dev.DiagnosePod(context.TODO(), client, dev.DefaultNamespace, "web")
+	if err != nil {
+		t.Fatalf("没有权限时不应中断诊断: %v", err)
+	}
+	reasons := map[string]int{}
+	for _, cause := range diagnosis.Causes {
+		reasons[cause.Reason] = cause.Score
+	}
+	if reasons["MissingConfigMap"] != dev.ScoreDefinite {
+		t.Errorf("其他引用应继续检查: %+v", diagnosis.Causes)
+	}
+	if score, ok := reasons["UnverifiedReference"]; !ok || score != dev.ScoreLow {
+		t.Errorf("无法检查的引用应作为低可信度的证据: %+v", diagnosis.Causes)
+	}
+}
+
+func TestDiagnoseForbiddenEvents(t *testing.T) {
+	pod := &coreV1.Pod{
+		ObjectMeta: metaV1.ObjectMeta{Name: "worker", Namespace: dev.DefaultNamespace},
+		Status: coreV1.PodStatus{
+			Phase: coreV1.PodRunning,
+			ContainerStatuses: []coreV1.ContainerStatus{{
+				Name:                 "app",
+				LastTerminationState: coreV1.ContainerState{Terminated: &coreV1.ContainerStateTerminated{Reason: "OOMKilled", ExitCode: 137}},
+			}},
+		},
+	}
+	client := fake.NewSimpleClientset()
+	// 没有查询事件的权限
+	client.PrependReactor("list", "events", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apiErrors.NewForbidden(schema.GroupResource{Resource: "events"}, "", errors.New("rbac"))
+	})
+
+	diagnosis, err := dev.Diagnose(context.TODO(), client, pod)
+	if err != nil {
+		t.Fatalf("无法查询事件时不应中断诊断: %v", err)
+	}
+	if len(diagnosis.Causes) != 2 || diagnosis.Causes[0].Reason != "OOMKilled" {
+		t.Fatalf("应保留根据状态推断的原因: %+v", diagnosis.Causes)
+	}
+	if cause := diagnosis.Causes[1]; cause.Reason != "EventsUnavailable" || cause.Score != dev.ScoreLow {
+		t.Errorf("无法查询事件应作为低可信度的证据: %+v", cause)
+	}
+}
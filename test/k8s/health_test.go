@@ -0,0 +1,112 @@
+package k8s
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	dev "k8s-dev/pkg/k8s"
+	appsV1 "k8s.io/api/apps/v1"
+	batchV1 "k8s.io/api/batch/v1"
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"strings"
+	"testing"
+	"time"
+)
+
+func unhealthyCluster() *fake.Clientset {
+	replicas := int32(3)
+	deleted := metaV1.NewTime(time.Now().Add(-time.Hour))
+	return fake.NewSimpleClientset(
+		&coreV1.Node{
+			ObjectMeta: metaV1.ObjectMeta{Name: "node-1"},
+			Status: coreV1.NodeStatus{Conditions: []coreV1.NodeCondition{
+				{Type: coreV1.NodeReady, Status: coreV1.ConditionFalse, Reason: "KubeletNotReady"},
+				{Type: coreV1.NodeMemoryPressure, Status: coreV1.ConditionTrue},
+			}},
+		},
+		&coreV1.Node{
+			ObjectMeta: metaV1.ObjectMeta{Name: "node-2"},
+			Status:     coreV1.NodeStatus{Conditions: []coreV1.NodeCondition{{Type: coreV1.NodeReady, Status: coreV1.ConditionTrue}}},
+		},
+		&coreV1.Pod{
+			ObjectMeta: metaV1.ObjectMeta{Name: "crash", Namespace: dev.DefaultNamespace},
+			Status: coreV1.PodStatus{Phase: coreV1.PodRunning, ContainerStatuses: []coreV1.ContainerStatus{{
+				Name:  "app",
+				State: coreV1.ContainerState{Waiting: &coreV1.ContainerStateWaiting{Reason: "CrashLoopBackOff", Message: "back-off 5m0s"}},
+			}}},
+		},
+		&coreV1.Pod{
+			ObjectMeta: metaV1.ObjectMeta{Name: "pending", Namespace: dev.DefaultNamespace},
+			Status: coreV1.PodStatus{Phase: coreV1.PodPending, Conditions: []coreV1.PodCondition{{
+				Type: coreV1.PodScheduled, Status: coreV1.ConditionFalse, Reason: "Unschedulable", Message: "0/2 nodes are available",
+			}}},
+		},
+		&appsV1.Deployment{
+			ObjectMeta: metaV1.ObjectMeta{Name: "web", Namespace: dev.DefaultNamespace},
+			Spec:       appsV1.DeploymentSpec{Replicas: &replicas},
+			Status:     appsV1.DeploymentStatus{AvailableReplicas: 1},
+		},
+		&batchV1.Job{
+			ObjectMeta: metaV1.ObjectMeta{Name: "migrate", Namespace: dev.DefaultNamespace},
+			Status: batchV1.JobStatus{Failed: 6, Conditions: []batchV1.JobCondition{{
+				Type: batchV1.JobFailed, Status: coreV1.ConditionTrue, Reason: "BackoffLimitExceeded",
+			}}},
+		},
+		&coreV1.Namespace{
+			ObjectMeta: metaV1.ObjectMeta{Name: "old", DeletionTimestamp: &deleted},
+			Status:     coreV1.NamespaceStatus{Phase: coreV1.NamespaceTerminating},
+		},
+	)
+}
+
+func TestHealth(t *testing.T) {
+	report, err := dev.Health(context.TODO(), unhealthyCluster(), dev.HealthOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Healthy() {
+		t.Fatal("集群存在异常，报告不应为健康")
+	}
+	if len(report.Nodes) != 2 || report.Nodes[0].Status != "NotReady" || report.Nodes[1].Status != "MemoryPressure" {
+		t.Errorf("节点异常不符合预期: %+v", report.Nodes)
+	}
+	if len(report.Pods) != 2 || report.Pods[0].Status != "CrashLoopBackOff" || report.Pods[1].Status != "Pending" {
+		t.Errorf("Pod异常不符合预期: %+v", report.Pods)
+	}
+	if !strings.Contains(report.Pods[1].Reason, "Unschedulable") {
+		t.Errorf("Pending 原因缺失: %q", report.Pods[1].Reason)
+	}
+	if len(report.Deployments) != 1 || !strings.HasPrefix(report.Deployments[0].Reason, "1/3") {
+		t.Errorf("Deployment异常不符合预期: %+v", report.Deployments)
+	}
+	if len(report.Jobs) != 1 || report.Jobs[0].Status != "Failed" {
+		t.Errorf("Job异常不符合预期: %+v", report.Jobs)
+	}
+	if len(report.Namespaces) != 1 || report.Namespaces[0].Name != "old" {
+		t.Errorf("命名空间异常不符合预期: %+v", report.Namespaces)
+	}
+}
+
+func TestWriteHealthReport(t *testing.T) {
+	report, err := dev.Health(context.TODO(), unhealthyCluster(), dev.HealthOptions{Namespace: dev.DefaultNamespace})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, format := range []string{dev.FormatText, dev.FormatJSON, dev.FormatMarkdown} {
+		var buf bytes.Buffer
+		if err := dev.WriteHealthReport(&buf, report, format); err != nil {
+			t.Fatal(format, err)
+		}
+		if !strings.Contains(buf.String(), "migrate") {
+			t.Errorf("%s 输出缺少 Job: %s", format, buf.String())
+		}
+		if format == dev.FormatJSON && !json.Valid(buf.Bytes()) {
+			t.Errorf("json 输出无效: %s", buf.String())
+		}
+	}
+	if err := dev.WriteHealthReport(&bytes.Buffer{}, report, "xml"); err == nil {
+		t.Error("不支持的格式应返回错误")
+	}
+}
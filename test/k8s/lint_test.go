@@ -0,0 +1,183 @@
+package k8s
+
+import (
+	"context"
+	dev "k8s-dev/pkg/k8s"
+	appsV1 "k8s.io/api/apps/v1"
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"testing"
+)
+
+const workloadManifest = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: default
+spec:
+  template:
+    metadata:
+      labels: {app: web}
+    spec:
+      securityContext:
+        runAsNonRoot: true
+      volumes:
+      - name: logs
+        hostPath: {path: /var/log}
+      containers:
+      - name: app
+        image: nginx:latest
+        resources:
+          requests: {cpu: 100m, memory: 64Mi}
+          limits: {cpu: 200m, memory: 128Mi}
+        livenessProbe: {tcpSocket: {port: 80}}
+        readinessProbe: {tcpSocket: {port: 80}}
+      - name: sidecar
+        image: registry:5000/busybox
+        securityContext:
+          runAsNonRoot: false
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: web-conf
+`
+
+func findingSet(findings []dev.LintFinding) map[string]dev.Severity {
+	set := map[string]dev.Severity{}
+	for _, f := range findings {
+		set[f.Rule+" "+f.Path] = f.Severity
+	}
+	return set
+}
+
+func TestLintManifest(t *testing.T) {
+	linter := dev.NewLinter(dev.LintConfig{
+		RequiredLabels: []string{"app", "team"},
+		Rules:          map[string]dev.LintRuleConfig{"probes": {Disabled: true}, "host-path": {Severity: dev.SeverityWarning}},
+	})
+	findings, err := linter.LintManifest([]byte(workloadManifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	set := findingSet(findings)
+	expected := map[string]dev.Severity{
+		"image-tag spec.template.spec.containers[0].image":                              dev.SeverityError,
+		"image-tag spec.template.spec.containers[1].image":                              dev.SeverityError,
+		"host-path spec.template.spec.volumes[0].hostPath":                              dev.SeverityWarning,
+		"run-as-non-root spec.template.spec.containers[1].securityContext.runAsNonRoot": dev.SeverityError,
+		"resources spec.template.spec.containers[1].resources.requests.cpu":             dev.SeverityWarning,
+		"required-labels spec.template.metadata.labels":                                 dev.SeverityWarning,
+	}
+	for key, severity := range expected {
+		if set[key] != severity {
+			t.Errorf("缺少 %s (%s): %v", key, severity, findings)
+		}
+	}
+	if _, ok := set["probes spec.template.spec.containers[1].livenessProbe"]; ok {
+		t.Error("probes 规则已禁用")
+	}
+	if findings[0].Object != "Deployment default/web" {
+		t.Errorf("对象标识不符合预期: %s", findings[0].Object)
+	}
+}
+
+func TestLintProbesSkipBatch(t *testing.T) {
+	manifest := `
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: migrate
+spec:
+  template:
+    spec:
+      restartPolicy: Never
+      containers:
+      - name: app
+        image: migrate:1.0
+---
+apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: backup
+spec:
+  schedule: "0 * * * *"
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          restartPolicy: OnFailure
+          containers:
+          - name: app
+            image: backup:1.0
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: web
+spec:
+  containers:
+  - name: app
+    image: nginx:1.25
+`
+	findings, err := dev.NewLinter(dev.LintConfig{}).LintManifest([]byte(manifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var probes []string
+	for _, f := range findings {
+		if f.Rule == "probes" {
+			probes = append(probes, f.Object+" "+f.Path)
+		}
+	}
+	if len(probes) != 2 || probes[0] != "Pod web spec.containers[0].livenessProbe" {
+		t.Errorf("批处理负载不应检查探针: %v", probes)
+	}
+}
+
+func TestLintCluster(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&appsV1.Deployment{
+			ObjectMeta: metaV1.ObjectMeta{Name: "web", Namespace: dev.DefaultNamespace},
+			Spec: appsV1.DeploymentSpec{Template: coreV1.PodTemplateSpec{Spec: coreV1.PodSpec{
+				Containers: []coreV1.Container{{Name: "app", Image: "nginx"}},
+			}}},
+		},
+		&coreV1.Pod{
+			ObjectMeta: metaV1.ObjectMeta{Name: "web-1", Namespace: dev.DefaultNamespace, OwnerReferences: []metaV1.OwnerReference{{Kind: "ReplicaSet", Name: "web"}}},
+			Spec:       coreV1.PodSpec{Containers: []coreV1.Container{{Name: "app", Image: "nginx"}}},
+		},
+		&coreV1.Pod{
+			ObjectMeta: metaV1.ObjectMeta{Name: "debug", Namespace: dev.DefaultNamespace},
+			Spec:       coreV1.PodSpec{Containers: []coreV1.Container{{Name: "app", Image: "busybox:1.36"}}},
+		},
+	)
+	linter := dev.NewLinter(dev.LintConfig{Rules: map[string]dev.LintRuleConfig{
+		"resources": {Disabled: true}, "probes": {Disabled: true}, "run-as-non-root": {Disabled: true},
+	}})
+	linter.AddRule(dev.LintRule{
+		Name:     "no-debug",
+		Severity: dev.SeverityInfo,
+		Check: func(template *coreV1.PodTemplateSpec, _ *dev.LintConfig) []dev.Violation {
+			if template.Name == "debug" {
+				return []dev.Violation{{Path: "metadata.name", Message: "调试 Pod"}}
+			}
+			return nil
+		},
+	})
+	findings, err := linter.LintCluster(context.TODO(), client, dev.DefaultNamespace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(findings) != 2 {
+		t.Fatalf("期望 2 条记录，实际: %v", findings)
+	}
+	if findings[0].Object != "Deployment default/web" || findings[0].Rule != "image-tag" {
+		t.Errorf("未预期的记录: %v", findings[0])
+	}
+	if findings[1].Object != "Pod default/debug" || findings[1].Rule != "no-debug" {
+		t.Errorf("未预期的记录: %v", findings[1])
+	}
+}
@@ -0,0 +1,150 @@
+package k8s
+
+import (
+	"bytes"
+	"context"
+	"k8s-dev/pkg/k8s/printers"
+	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func samplePods() *coreV1.PodList {
+	return &coreV1.PodList{Items: []coreV1.Pod{
+		{
+			ObjectMeta: metaV1.ObjectMeta{Name: "web-1", Namespace: "default", Labels: map[string]string{"app": "web"}},
+			Spec:       coreV1.PodSpec{NodeName: "node-1", Containers: []coreV1.Container{{Name: "app", Image: "nginx:1.14.2"}}},
+			Status: coreV1.PodStatus{Phase: coreV1.PodRunning, PodIP: "10.0.0.1", ContainerStatuses: []coreV1.ContainerStatus{
+				{Name: "app", Ready: true, RestartCount: 2},
+			}},
+		},
+		{
+			ObjectMeta: metaV1.ObjectMeta{Name: "web-2", Namespace: "default"},
+			Spec:       coreV1.PodSpec{Containers: []coreV1.Container{{Name: "app", Image: "nginx:1.14.2"}}},
+			Status: coreV1.PodStatus{Phase: coreV1.PodPending, ContainerStatuses: []coreV1.ContainerStatus{
+				{Name: "app", State: coreV1.ContainerState{Waiting: &coreV1.ContainerStateWaiting{Reason: "ImagePullBackOff"}}},
+			}},
+		},
+	}}
+}
+
+func printObj(t *testing.T, format string, obj runtime.Object) string {
+	t.Helper()
+	printer, err := printers.New(format)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := printer.PrintObj(obj, &buf); err != nil {
+		t.Fatal(format, err)
+	}
+	return buf.String()
+}
+
+func TestPrinters(t *testing.T) {
+	pods := samplePods()
+	cases := []struct {
+		format   string
+		expected []string
+	}{
+		{"", []string{"NAME", "READY", "web-1", "1/1", "Running", "ImagePullBackOff"}},
+		{"wide", []string{"NODE", "node-1", "10.0.0.1"}},
+		{"json", []string{`"kind": "PodList"`, `"kind": "Pod"`, `"name": "web-1"`}},
+		{"yaml", []string{"kind: PodList", "name: web-2"}},
+		{"name", []string{"pod/web-1\npod/web-2\n"}},
+		{"custom-columns=NAME:.metadata.name,IMAGE:.spec.containers[*].image,APP:metadata.labels.app", []string{"IMAGE", "nginx:1.14.2", "<none>"}},
+		{"jsonpath={range .items[*]}{.metadata.name}={.status.phase};{end}", []string{"web-1=Running;web-2=Pending;"}},
+		{`go-template={{range .items}}{{.metadata.name}}{{if exists . "metadata" "labels"}}*{{end}} {{end}}`, []string{"web-1* web-2 "}},
+	}
+	for _, c := range cases {
+		out := printObj(t, c.format, pods)
+		for _, expected := range c.expected {
+			if !strings.Contains(out, expected) {
+				t.Errorf("%q 输出缺少 %q:\n%s", c.format, expected, out)
+			}
+		}
+	}
+	if _, err := printers.New("xml"); err == nil {
+		t.Error("不支持的格式应返回错误")
+	}
+}
+
+func TestGetTable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/apis/apps/v1/namespaces/default/deployments" || !strings.Contains(r.Header.Get("Accept"), "as=Table") {
+			t.Errorf("未预期的请求: %s %s", r.URL.Path, r.Header.Get("Accept"))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"kind": "Table", "apiVersion": "meta.k8s.io/v1",
+			"columnDefinitions": [{"name": "Name", "type": "string"}, {"name": "Ready", "type": "string"}, {"name": "Images", "type": "string", "priority": 1}],
+			"rows": [{"cells": ["web", "1/3", "nginx:1.14.2"], "object": {"kind": "PartialObjectMetadata", "metadata": {"name": "web", "namespace": "default"}}}]}`))
+	}))
+	defer server.Close()
+
+	client, err := kubernetes.NewForConfig(&rest.Config{Host: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gvr := schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+	obj, err := printers.GetTable(context.TODO(), client.CoreV1().RESTClient(), gvr, "default", metaV1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out := printObj(t, "", obj); strings.Contains(out, "IMAGES") || !strings.Contains(out, "1/3") {
+		t.Errorf("table 输出不符合预期:\n%s", out)
+	}
+	var buf bytes.Buffer
+	printer := &printers.TablePrinter{Wide: true, WithNamespace: true}
+	if err := printer.PrintObj(obj, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if out := buf.String(); !strings.Contains(out, "NAMESPACE") || !strings.Contains(out, "nginx:1.14.2") {
+		t.Errorf("wide 输出不符合预期:\n%s", out)
+	}
+}
+
+func TestGetTableClusterScoped(t *testing.T) {
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(coreV1.SchemeGroupVersion.WithKind("Node"), meta.RESTScopeRoot)
+	mapper.Add(coreV1.SchemeGroupVersion.WithKind("Pod"), meta.RESTScopeNamespace)
+
+	nodes := coreV1.SchemeGroupVersion.WithResource("nodes")
+	namespace, namespaced, err := printers.ResourceNamespace(mapper, nodes, "default")
+	if err != nil || namespace != "" || namespaced {
+		t.Fatalf("集群级别的资源不应使用命名空间: %q %v %v", namespace, namespaced, err)
+	}
+	if namespace, namespaced, _ := printers.ResourceNamespace(mapper, coreV1.SchemeGroupVersion.WithResource("pods"), "default"); namespace != "default" || !namespaced {
+		t.Errorf("命名空间级别的资源应保留命名空间: %q %v", namespace, namespaced)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/nodes" {
+			t.Errorf("未预期的请求: %s", r.URL.Path)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"kind": "Table", "apiVersion": "meta.k8s.io/v1",
+			"columnDefinitions": [{"name": "Name", "type": "string"}, {"name": "Status", "type": "string"}],
+			"rows": [{"cells": ["node-1", "Ready"], "object": {"kind": "PartialObjectMetadata", "metadata": {"name": "node-1"}}}]}`))
+	}))
+	defer server.Close()
+
+	client, err := kubernetes.NewForConfig(&rest.Config{Host: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj, err := printers.GetTable(context.TODO(), client.CoreV1().RESTClient(), nodes, namespace, metaV1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out := printObj(t, "", obj); !strings.Contains(out, "node-1") || !strings.Contains(out, "Ready") {
+		t.Errorf("table 输出不符合预期:\n%s", out)
+	}
+}
@@ -0,0 +1,191 @@
+package k8s
+
+import (
+	dev "k8s-dev/pkg/k8s"
+	"path/filepath"
+	"testing"
+)
+
+// openAPIDoc 从 /openapi/v3/api/v1 中裁剪出来的 Pod 相关 schema
+const openAPIDoc = `{
+  "openapi": "3.0.0",
+  "components": {"schemas": {
+    "io.k8s.api.core.v1.Pod": {
+      "type": "object",
+      "properties": {
+        "apiVersion": {"type": "string"},
+        "kind": {"type": "string"},
+        "metadata": {"allOf": [{"$ref": "#/components/schemas/io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta"}], "default": {}},
+        "spec": {"allOf": [{"$ref": "#/components/schemas/io.k8s.api.core.v1.PodSpec"}], "default": {}}
+      },
+      "x-kubernetes-group-version-kind": [{"group": "", "kind": "Pod", "version": "v1"}]
+    },
+    "io.k8s.api.core.v1.PodSpec": {
+      "type": "object",
+      "required": ["containers"],
+      "properties": {
+        "containers": {"type": "array", "items": {"allOf": [{"$ref": "#/components/schemas/io.k8s.api.core.v1.Container"}], "default": {}}},
+        "nodeSelector": {"type": "object", "additionalProperties": {"type": "string", "default": ""}},
+        "restartPolicy": {"type": "string", "enum": ["Always", "OnFailure", "Never"]}
+      }
+    },
+    "io.k8s.api.core.v1.Container": {
+      "type": "object",
+      "required": ["name"],
+      "properties": {
+        "name": {"type": "string"},
+        "image": {"type": "string"},
+        "ports": {"type": "array", "items": {"type": "object", "properties": {"containerPort": {"type": "integer", "format": "int32"}}}},
+        "resources": {"type": "object", "properties": {"limits": {"type": "object", "additionalProperties": {"allOf": [{"$ref": "#/components/schemas/io.k8s.apimachinery.pkg.api.resource.Quantity"}]}}}}
+      }
+    },
+    "io.k8s.apimachinery.pkg.api.resource.Quantity": {"oneOf": [{"type": "string"}, {"type": "number"}]},
+    "io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta": {
+      "type": "object",
+      "properties": {
+        "name": {"type": "string"},
+        "namespace": {"type": "string"},
+        "labels": {"type": "object", "additionalProperties": {"type": "string", "default": ""}}
+      }
+    }
+  }}
+}`
+
+const nginxCRD = `
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: nginxes.devops.tomoncle.com
+spec:
+  group: devops.tomoncle.com
+  names:
+    kind: Nginx
+    plural: nginxes
+  scope: Namespaced
+  versions:
+  - name: v1
+    served: true
+    storage: true
+    schema:
+      openAPIV3Schema:
+        type: object
+        properties:
+          spec:
+            type: object
+            required: [image]
+            properties:
+              image:
+                type: string
+              replicas:
+                type: integer
+              port:
+                x-kubernetes-int-or-string: true
+                anyOf: [{type: integer}, {type: string}]
+              tls:
+                type: array
+                items:
+                  type: object
+                  properties:
+                    hosts:
+                      type: array
+                      items: {type: string}
+                    secretName:
+                      type: string
+          status:
+            type: object
+            x-kubernetes-preserve-unknown-fields: true
+`
+
+func newSchemaSet(t *testing.T) *dev.SchemaSet {
+	schemas := dev.NewSchemaSet()
+	if err := schemas.AddDocument([]byte(openAPIDoc)); err != nil {
+		t.Fatal(err)
+	}
+	if err := schemas.AddCRD([]byte(nginxCRD)); err != nil {
+		t.Fatal(err)
+	}
+	return schemas
+}
+
+func assertValidation(t *testing.T, errs []dev.ValidationError, expected map[string]dev.ValidationErrorType) {
+	t.Helper()
+	if len(errs) != len(expected) {
+		t.Fatalf("期望 %d 个错误，实际: %v", len(expected), errs)
+	}
+	for _, e := range errs {
+		if expected[e.Path] != e.Type {
+			t.Errorf("未预期的错误: %v", e)
+		}
+	}
+}
+
+func TestValidateManifest(t *testing.T) {
+	manifest := `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: web
+  namespace: default
+  lables: {app: web}
+spec:
+  restartPolicy: Sometimes
+  nodeSelector:
+    disk: ssd
+  containers:
+  - name: app
+    image: nginx:1.14.2
+    ports:
+    - containerPort: "80"
+    resources:
+      limits: {cpu: 1, memory: 128Mi}
+  - image: busybox
+---
+apiVersion: devops.tomoncle.com/v1
+kind: Nginx
+metadata:
+  name: nginx-sample
+spec:
+  replicas: 1.5
+  port: http
+  tls:
+  - hosts: [dev-01.devops.com]
+    secret: tls-cert
+status:
+  anything: goes
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+`
+	errs, err := newSchemaSet(t).ValidateManifest([]byte(manifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertValidation(t, errs, map[string]dev.ValidationErrorType{
+		"metadata.lables":                           dev.ErrUnknownField,
+		"spec.restartPolicy":                        dev.ErrNotSupported,
+		"spec.containers[0].ports[0].containerPort": dev.ErrWrongType,
+		"spec.containers[1].name":                   dev.ErrRequired,
+		"spec.image":                                dev.ErrRequired,
+		"spec.replicas":                             dev.ErrWrongType,
+		"spec.tls[0].secret":                        dev.ErrUnknownField,
+		"":                                          dev.ErrUnknownSchema,
+	})
+}
+
+func TestSaveAndLoadSchemas(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schemas.json")
+	if err := newSchemaSet(t).Save(path); err != nil {
+		t.Fatal(err)
+	}
+	schemas, err := dev.LoadSchemas(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	errs, err := schemas.ValidateManifest([]byte(`{"apiVersion": "devops.tomoncle.com/v1", "kind": "Nginx", "metadata": {"name": "n"}, "spec": {"image": "nginx:1.14.2", "port": 80, "replica": 1}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertValidation(t, errs, map[string]dev.ValidationErrorType{"spec.replica": dev.ErrUnknownField})
+}
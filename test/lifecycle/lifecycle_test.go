@@ -0,0 +1,193 @@
+package lifecycle
+
+import (
+	"bytes"
+	"k8s-dev/pkg/controller"
+	"k8s-dev/pkg/controller/controllertest"
+	"k8s-dev/pkg/lifecycle"
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clocktesting "k8s.io/utils/clock/testing"
+	"strings"
+	"testing"
+	"time"
+)
+
+var created = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func newPod(namespace, name, owner string) *coreV1.Pod {
+	pod := &coreV1.Pod{
+		ObjectMeta: metaV1.ObjectMeta{
+			Namespace:         namespace,
+			Name:              name,
+			UID:               types.UID(name + "-uid"),
+			CreationTimestamp: metaV1.NewTime(created),
+			Labels:            map[string]string{"pod-template-hash": "5d4f8"},
+		},
+		Status: coreV1.PodStatus{Phase: coreV1.PodPending},
+	}
+	if owner != "" {
+		isController := true
+		pod.OwnerReferences = []metaV1.OwnerReference{{Kind: "ReplicaSet", Name: owner + "-5d4f8", Controller: &isController}}
+	}
+	return pod
+}
+
+// progress 把 Pod 推进到 Running，各 condition 在创建后 offset 秒变为 True
+func progress(pod *coreV1.Pod, scheduled, ready time.Duration) *coreV1.Pod {
+	pod = pod.DeepCopy()
+	pod.Status.Phase = coreV1.PodRunning
+	condition := func(t coreV1.PodConditionType, offset time.Duration) coreV1.PodCondition {
+		return coreV1.PodCondition{Type: t, Status: coreV1.ConditionTrue, LastTransitionTime: metaV1.NewTime(created.Add(offset))}
+	}
+	pod.Status.Conditions = []coreV1.PodCondition{
+		condition(coreV1.PodScheduled, scheduled),
+		condition(coreV1.PodInitialized, scheduled),
+		condition(coreV1.ContainersReady, ready),
+		condition(coreV1.PodReady, ready),
+	}
+	return pod
+}
+
+func newHarness(t *testing.T) (*controllertest.Harness[*coreV1.Pod], *lifecycle.Tracker, *clocktesting.FakeClock) {
+	t.Helper()
+	clock := clocktesting.NewFakeClock(created.Add(time.Minute))
+	tracker := lifecycle.NewTracker(lifecycle.Options{Retention: time.Hour, Clock: clock})
+	h, err := controllertest.NewHarness(controller.Options[*coreV1.Pod]{Name: "lifecycle", Reconcile: tracker.Reconcile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(h.Close)
+	return h, tracker, clock
+}
+
+func TestTimeline(t *testing.T) {
+	h, tracker, _ := newHarness(t)
+
+	pod := newPod("default", "web", "web")
+	if err := h.Add(pod); err != nil {
+		t.Fatal(err)
+	}
+	h.RunUntilIdle()
+	running := progress(pod, 2*time.Second, 10*time.Second)
+	running.Status.ContainerStatuses = []coreV1.ContainerStatus{{
+		Name:         "app",
+		RestartCount: 1,
+		LastTerminationState: coreV1.ContainerState{Terminated: &coreV1.ContainerStateTerminated{
+			Reason: "OOMKilled", ExitCode: 137, FinishedAt: metaV1.NewTime(created.Add(5 * time.Second)),
+		}},
+	}}
+	if err := h.Update(running); err != nil {
+		t.Fatal(err)
+	}
+	h.RunUntilIdle()
+	// 同一次终止再次出现不会重复计数
+	if err := h.Update(running.DeepCopy()); err != nil {
+		t.Fatal(err)
+	}
+	h.RunUntilIdle()
+
+	timeline, ok := tracker.Get("default/web")
+	if !ok {
+		t.Fatal("应记录 default/web")
+	}
+	if d, ok := timeline.TimeToSchedule(); !ok || d != 2*time.Second {
+		t.Errorf("调度耗时应为 2s: %v", d)
+	}
+	if d, ok := timeline.TimeToReady(); !ok || d != 10*time.Second {
+		t.Errorf("Ready 耗时应为 10s: %v", d)
+	}
+	if timeline.Owner != "Deployment/web" {
+		t.Errorf("ReplicaSet 应还原为 Deployment: %s", timeline.Owner)
+	}
+	if timeline.Restarts != 1 || len(timeline.Terminations) != 1 || timeline.Terminations[0].Reason != "OOMKilled" {
+		t.Errorf("重启和终止原因错误: %d %+v", timeline.Restarts, timeline.Terminations)
+	}
+
+	var got []string
+	for _, transition := range timeline.Transitions {
+		got = append(got, transition.Name+"="+transition.Status)
+	}
+	want := "Phase=Pending PodScheduled=True Initialized=True ContainersReady=True Ready=True Phase=Running"
+	if strings.Join(got, " ") != want {
+		t.Errorf("时间线错误:\n got: %s\nwant: %s", strings.Join(got, " "), want)
+	}
+}
+
+func TestRecreatedPod(t *testing.T) {
+	h, tracker, _ := newHarness(t)
+	pod := progress(newPod("default", "db-0", ""), time.Second, time.Second)
+	if err := h.Add(pod); err != nil {
+		t.Fatal(err)
+	}
+	h.RunUntilIdle()
+
+	recreated := newPod("default", "db-0", "")
+	recreated.UID = "another"
+	if err := h.Update(recreated); err != nil {
+		t.Fatal(err)
+	}
+	h.RunUntilIdle()
+	timeline, _ := tracker.Get("default/db-0")
+	if timeline.UID != "another" || !timeline.Ready.IsZero() {
+		t.Errorf("重建的 Pod 应重新记录: %+v", timeline)
+	}
+}
+
+func TestStats(t *testing.T) {
+	h, tracker, clock := newHarness(t)
+	for i, offsets := range [][2]time.Duration{{time.Second, 4 * time.Second}, {2 * time.Second, 6 * time.Second}, {3 * time.Second, 20 * time.Second}} {
+		pod := progress(newPod("default", "web-"+string(rune('a'+i)), "web"), offsets[0], offsets[1])
+		if err := h.Add(pod); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pending := newPod("jobs", "batch", "")
+	if err := h.Add(pending); err != nil {
+		t.Fatal(err)
+	}
+	h.RunUntilIdle()
+
+	namespaces := tracker.ByNamespace()
+	if len(namespaces) != 2 || namespaces[0].Group != "default" || namespaces[1].Group != "jobs" {
+		t.Fatalf("按命名空间统计错误: %+v", namespaces)
+	}
+	ready := namespaces[0].TimeToReady
+	if ready.Count != 3 || ready.P50 != 6*time.Second || ready.P90 != 20*time.Second || ready.Max != 20*time.Second {
+		t.Errorf("Ready 分位数错误: %+v", ready)
+	}
+	if namespaces[1].Pods != 1 || namespaces[1].TimeToSchedule.Count != 0 {
+		t.Errorf("未调度的 Pod 不参与耗时统计: %+v", namespaces[1])
+	}
+	owners := tracker.ByOwner()
+	if len(owners) != 1 || owners[0].Group != "default/Deployment/web" || owners[0].Pods != 3 {
+		t.Errorf("按控制器统计错误: %+v", owners)
+	}
+	if total := tracker.Total(); total.Pods != 4 || total.TimeToSchedule.P50 != 2*time.Second {
+		t.Errorf("汇总错误: %+v", total)
+	}
+
+	var out bytes.Buffer
+	if err := lifecycle.WriteReport(&out, tracker.Report(), lifecycle.FormatText); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"4 pods", "default/Deployment/web", "2s/3s/3s", "6s/20s/20s"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("报告中应包含 %q:\n%s", want, out.String())
+		}
+	}
+
+	// 删除的 Pod 在保留时长内仍参与统计
+	if err := h.Delete(pending); err != nil {
+		t.Fatal(err)
+	}
+	h.RunUntilIdle()
+	if tracker.Prune() != 0 || tracker.Total().Pods != 4 {
+		t.Error("保留时长内不应清理")
+	}
+	clock.Step(2 * time.Hour)
+	if tracker.Prune() != 1 || tracker.Total().Pods != 3 {
+		t.Error("超过保留时长后应清理")
+	}
+}
@@ -0,0 +1,122 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"k8s-dev/pkg/sink"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// receiver 记录收到的 CloudEvents 请求
+func receiver(t *testing.T, status int) (*httptest.Server, <-chan *http.Request, <-chan []byte) {
+	requests, bodies := make(chan *http.Request, 10), make(chan []byte, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		requests <- r
+		bodies <- body
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, requests, bodies
+}
+
+func TestCloudEventsBinary(t *testing.T) {
+	server, requests, bodies := receiver(t, http.StatusAccepted)
+	s, err := sink.NewCloudEvents(sink.CloudEventsOptions{
+		URL:     server.URL,
+		Source:  "/my cluster",
+		Headers: map[string]string{"Authorization": "Bearer token"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	record := newRecord("web")
+	record.UID, record.Cluster, record.Key = "uid-1", "east", "east/default/web"
+	if err := s.Send(context.Background(), record); err != nil {
+		t.Fatal(err)
+	}
+
+	r, body := <-requests, <-bodies
+	for header, want := range map[string]string{
+		"Content-Type":   "application/json",
+		"Authorization":  "Bearer token",
+		"Ce-Specversion": "1.0",
+		"Ce-Id":          "uid-1/1/added",
+		"Ce-Type":        "k8s-dev.pod.added",
+		"Ce-Source":      "/my%20cluster/east",
+		"Ce-Subject":     "default/web",
+		"Ce-Time":        "2023-03-01T08:00:00Z",
+		"Ce-Cluster":     "east",
+	} {
+		if got := r.Header.Get(header); got != want {
+			t.Errorf("%s 应为 %q，实际为 %q", header, want, got)
+		}
+	}
+	if r.Header.Get("Ce-Datacontenttype") != "" {
+		t.Error("二进制模式中 datacontenttype 应使用 Content-Type")
+	}
+	var got sink.Record
+	if err := json.Unmarshal(body, &got); err != nil || got.Key != "east/default/web" || got.Name != "web" {
+		t.Errorf("请求体应为记录的 JSON: %s %v", body, err)
+	}
+}
+
+func TestCloudEventsStructured(t *testing.T) {
+	server, requests, bodies := receiver(t, http.StatusOK)
+	s, err := sink.NewCloudEvents(sink.CloudEventsOptions{URL: server.URL, Mode: sink.CloudEventsStructured, TypePrefix: "com.example"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := newRecord("web")
+	record.Type = "Deleted"
+	if err := s.Send(context.Background(), record); err != nil {
+		t.Fatal(err)
+	}
+
+	r, body := <-requests, <-bodies
+	if r.Header.Get("Content-Type") != "application/cloudevents+json" || r.Header.Get("Ce-Id") != "" {
+		t.Errorf("结构化模式的属性应在请求体中: %v", r.Header)
+	}
+	var event struct {
+		SpecVersion     string      `json:"specversion"`
+		ID              string      `json:"id"`
+		Source          string      `json:"source"`
+		Type            string      `json:"type"`
+		Subject         string      `json:"subject"`
+		Time            string      `json:"time"`
+		DataContentType string      `json:"datacontenttype"`
+		Data            sink.Record `json:"data"`
+	}
+	if err := json.Unmarshal(body, &event); err != nil {
+		t.Fatal(err)
+	}
+	if event.SpecVersion != "1.0" || event.Source != sink.DefaultCloudEventsSource || event.Type != "com.example.pod.deleted" ||
+		event.Subject != "default/web" || event.Time != "2023-03-01T08:00:00Z" || event.DataContentType != "application/json" {
+		t.Errorf("事件属性不正确: %s", body)
+	}
+	// 没有 uid 时随机生成 id
+	if event.ID == "" || event.Data.Key != "default/web" {
+		t.Errorf("事件应包含 id 和记录: %s", body)
+	}
+}
+
+func TestCloudEventsErrors(t *testing.T) {
+	server, _, _ := receiver(t, http.StatusInternalServerError)
+	s, _ := sink.NewCloudEvents(sink.CloudEventsOptions{URL: server.URL})
+	if err := s.Send(context.Background(), newRecord("web")); err == nil {
+		t.Error("接收方返回错误时 Send 应返回错误，由控制器重试")
+	}
+	if _, err := sink.NewCloudEvents(sink.CloudEventsOptions{URL: server.URL, Mode: "batch"}); err == nil {
+		t.Error("未知的模式应返回错误")
+	}
+	if _, err := sink.NewCloudEvents(sink.CloudEventsOptions{}); err == nil {
+		t.Error("缺少 URL 应返回错误")
+	}
+}
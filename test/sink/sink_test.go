@@ -0,0 +1,323 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"k8s-dev/pkg/sink"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newRecord(name string) sink.Record {
+	return sink.Record{
+		Time:            time.Date(2023, 3, 1, 8, 0, 0, 0, time.UTC),
+		Type:            "Added",
+		Kind:            "Pod",
+		Key:             "default/" + name,
+		Namespace:       "default",
+		Name:            name,
+		ResourceVersion: "1",
+	}
+}
+
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+	s, err := sink.NewWriter(&buf, sink.FormatText)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Send(context.Background(), newRecord("web")); err != nil {
+		t.Fatal(err)
+	}
+	want := "time=2023-03-01T08:00:00Z type=Added kind=Pod key=default/web resourceVersion=1\n"
+	if buf.String() != want {
+		t.Errorf("text 格式不正确:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	s, _ = sink.NewWriter(&buf, sink.FormatJSON)
+	_ = s.Send(context.Background(), newRecord("web"))
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil || decoded["key"] != "default/web" {
+		t.Errorf("json 格式不正确: %s %v", buf.String(), err)
+	}
+
+	if _, err := sink.NewWriter(&buf, "yaml"); err == nil {
+		t.Error("未知格式应返回错误")
+	}
+}
+
+func TestFileRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	line, _ := json.Marshal(newRecord("web"))
+	s, err := sink.NewFile(sink.FileOptions{Path: path, MaxSize: int64(len(line)+1) * 2, MaxBackups: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 7; i++ {
+		if err := s.Send(context.Background(), newRecord("web")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 每个文件2行：.2 和 .1 各2行，当前文件1行，最早的2行被丢弃
+	for file, lines := range map[string]int{path: 1, path + ".1": 2, path + ".2": 2} {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := strings.Count(string(data), "\n"); n != lines {
+			t.Errorf("%s 应有 %d 行，实际为 %d", filepath.Base(file), lines, n)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Error("超出 MaxBackups 的文件应被删除")
+	}
+}
+
+func TestFileRotationFailure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	line, _ := json.Marshal(newRecord("web"))
+	s, err := sink.NewFile(sink.FileOptions{Path: path, MaxSize: int64(len(line) + 1), MaxBackups: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err := s.Send(context.Background(), newRecord("web")); err != nil {
+		t.Fatal(err)
+	}
+
+	// 轮转的目标是非空目录，改名失败
+	if err := os.MkdirAll(filepath.Join(path+".1", "busy"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Send(context.Background(), newRecord("web")); err == nil {
+		t.Fatal("轮转失败时应返回错误")
+	}
+	if err := os.RemoveAll(path + ".1"); err != nil {
+		t.Fatal(err)
+	}
+	// 重新打开文件并再次轮转
+	if err := s.Send(context.Background(), newRecord("web")); err != nil {
+		t.Fatalf("轮转失败后应能继续写入: %v", err)
+	}
+	for file, lines := range map[string]int{path: 1, path + ".1": 1} {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := strings.Count(string(data), "\n"); n != lines {
+			t.Errorf("%s 应有 %d 行，实际为 %d", filepath.Base(file), lines, n)
+		}
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	var (
+		lock     sync.Mutex
+		batches  [][]sink.Record
+		requests int
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		requests++
+		// 第一次请求失败，验证重试
+		if requests == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer token" {
+			t.Errorf("缺少请求头: %v", r.Header)
+		}
+		var batch []sink.Record
+		if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+			t.Error(err)
+		}
+		batches = append(batches, batch)
+	}))
+	defer server.Close()
+
+	s, err := sink.NewWebhook(sink.WebhookOptions{
+		URL:           server.URL,
+		Headers:       map[string]string{"Authorization": "Bearer token"},
+		BatchSize:     2,
+		FlushInterval: time.Hour,
+		RetryBackoff:  time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a", "b", "c"} {
+		if err := s.Send(context.Background(), newRecord(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Close 发送剩余的记录
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Send(context.Background(), newRecord("d")); err == nil {
+		t.Error("关闭后发送应返回错误")
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	var keys []string
+	for _, batch := range batches {
+		if len(batch) > 2 {
+			t.Errorf("批次超过 BatchSize: %d", len(batch))
+		}
+		for _, record := range batch {
+			keys = append(keys, record.Key)
+		}
+	}
+	if strings.Join(keys, ",") != "default/a,default/b,default/c" {
+		t.Errorf("webhook 收到的记录不正确: %v", keys)
+	}
+}
+
+func TestWebhookGivesUp(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	s, _ := sink.NewWebhook(sink.WebhookOptions{URL: server.URL, MaxRetries: 1, RetryBackoff: time.Millisecond, FlushInterval: time.Hour})
+	_ = s.Send(context.Background(), newRecord("web"))
+	if err := s.Close(); err == nil {
+		t.Error("重试后仍失败应返回错误")
+	}
+}
+
+func TestWebhookBufferFull(t *testing.T) {
+	received, release := make(chan string, 10), make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var batch []sink.Record
+		if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+			t.Error(err)
+		}
+		for _, record := range batch {
+			received <- record.Name
+		}
+		<-release
+	}))
+	defer server.Close()
+
+	s, _ := sink.NewWebhook(sink.WebhookOptions{URL: server.URL, BatchSize: 1, MaxBuffered: 1, FlushInterval: time.Hour})
+	if err := s.Send(context.Background(), newRecord("a")); err != nil {
+		t.Fatal(err)
+	}
+	// 等待后台协程取出 a 并阻塞在请求中
+	if name := <-received; name != "a" {
+		t.Fatalf("应先发送 a: %s", name)
+	}
+	if err := s.Send(context.Background(), newRecord("b")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Send(context.Background(), newRecord("c")); err == nil {
+		t.Error("缓冲已满时 Send 应返回错误")
+	}
+	close(release)
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if name := <-received; name != "b" {
+		t.Errorf("缓冲中的 b 应在 Close 时发送: %s", name)
+	}
+}
+
+func TestWebhookCloseDuringBackoff(t *testing.T) {
+	requests := make(chan struct{}, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests <- struct{}{}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	s, _ := sink.NewWebhook(sink.WebhookOptions{URL: server.URL, BatchSize: 1, MaxRetries: 2, RetryBackoff: time.Hour, FlushInterval: time.Hour})
+	_ = s.Send(context.Background(), newRecord("web"))
+	<-requests
+	start := time.Now()
+	if err := s.Close(); err == nil {
+		t.Error("重试后仍失败应返回错误")
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("Close 不应等待退避时间: %s", elapsed)
+	}
+	if len(requests) != 2 {
+		t.Errorf("Close 后剩余的重试应立即进行: %d", len(requests)+1)
+	}
+}
+
+type failing struct{}
+
+func (failing) Send(context.Context, sink.Record) error { return errors.New("failed") }
+func (failing) Close() error                            { return nil }
+
+// flakySink 前 failures 次发送失败
+type flakySink struct {
+	failures int
+	sent     int
+}
+
+func (f *flakySink) Send(context.Context, sink.Record) error {
+	if f.failures > 0 {
+		f.failures--
+		return errors.New("failed")
+	}
+	f.sent++
+	return nil
+}
+func (f *flakySink) Close() error { return nil }
+
+func TestMultiAndChannel(t *testing.T) {
+	ch := make(chan sink.Record, 1)
+	s := sink.Multi(failing{}, sink.Channel(ch))
+	if err := s.Send(context.Background(), newRecord("web")); err == nil {
+		t.Error("任意 sink 失败都应返回错误")
+	}
+	if record := <-ch; record.Key != "default/web" {
+		t.Errorf("其他 sink 仍应收到记录: %+v", record)
+	}
+
+	// 重试时只发送给上次失败的 sink
+	flaky := &flakySink{failures: 1}
+	s = sink.Multi(sink.Channel(ch), flaky)
+	if err := s.Send(context.Background(), newRecord("db")); err == nil {
+		t.Error("任意 sink 失败都应返回错误")
+	}
+	if err := s.Send(context.Background(), newRecord("db")); err != nil {
+		t.Fatal(err)
+	}
+	if len(ch) != 1 || flaky.sent != 1 {
+		t.Errorf("已成功的 sink 不应重复收到记录: channel=%d flaky=%d", len(ch), flaky.sent)
+	}
+	<-ch
+	// 新版本的记录发送给所有 sink
+	record := newRecord("db")
+	record.ResourceVersion = "2"
+	if err := s.Send(context.Background(), record); err != nil || len(ch) != 1 || flaky.sent != 2 {
+		t.Errorf("新记录应发送给所有 sink: %v channel=%d flaky=%d", err, len(ch), flaky.sent)
+	}
+	<-ch
+
+	// channel 已满时在 ctx 取消后返回
+	ch <- newRecord("full")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := sink.Channel(ch).Send(ctx, newRecord("web")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("应返回 ctx 的错误: %v", err)
+	}
+}